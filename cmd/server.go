@@ -2,10 +2,15 @@ package cmd
 
 import (
 	"context"
+	"crypto/tls"
+	"errors"
+	"expvar"
 	"fmt"
 	"net/http"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"syscall"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
@@ -14,10 +19,15 @@ import (
 	"github.com/faiyaz032/gobox/internal/box"
 	"github.com/faiyaz032/gobox/internal/config"
 	"github.com/faiyaz032/gobox/internal/docker"
+	"github.com/faiyaz032/gobox/internal/infra/certs"
 	"github.com/faiyaz032/gobox/internal/infra/db/postgres"
 	"github.com/faiyaz032/gobox/internal/infra/logger"
+	"github.com/faiyaz032/gobox/internal/lab"
+	"github.com/faiyaz032/gobox/internal/ratelimit"
 	"github.com/faiyaz032/gobox/internal/repo"
 	boxhandler "github.com/faiyaz032/gobox/internal/rest/handler/box"
+	labhandler "github.com/faiyaz032/gobox/internal/rest/handler/lab"
+	restmiddleware "github.com/faiyaz032/gobox/internal/rest/middleware"
 )
 
 func RunServer(cfg *config.Config) {
@@ -30,7 +40,9 @@ func RunServer(cfg *config.Config) {
 		_ = log.Sync()
 	}()
 
-	log.Info("Starting GoBox server", zap.String("environment", cfg.Environment))
+	log.Info("Starting GoBox server",
+		zap.String("environment", cfg.Environment),
+		zap.String("replica", cfg.Box.ReplicaID))
 
 	dsn := fmt.Sprintf(
 		"host=%s port=%s user=%s password=%s dbname=%s sslmode=%s",
@@ -55,23 +67,48 @@ func RunServer(cfg *config.Config) {
 		log.Fatal("Failed to initialize docker client", zap.Error(err))
 	}
 	defer dockerSvc.Close()
+	dockerSvc.UseHostProc(cfg.Box.Abuse.HostProc)
+	if cfg.Box.Security.UsernsRemap {
+		enabled, err := dockerSvc.UsernsRemapEnabled(context.Background())
+		if err != nil {
+			log.Fatal("Failed to inspect docker daemon", zap.Error(err))
+		}
+		if !enabled {
+			log.Fatal("BOX_USERNS_REMAP is set but the docker daemon does not remap user namespaces")
+		}
+	}
+	runtimes, err := dockerSvc.Runtimes(context.Background())
+	if err != nil {
+		log.Fatal("Failed to list docker runtimes", zap.Error(err))
+	}
+	fellBack, err := cfg.Box.ResolveRuntimes(runtimes)
+	if err != nil {
+		log.Fatal("Box template runtime unavailable", zap.Error(err))
+	}
+	if len(fellBack) > 0 {
+		log.Warn("Templates fall back to the default docker runtime", zap.Strings("templates", fellBack))
+	}
+	dockerSvc.UseIPAM(docker.NewIPAM(repo.NewSubnetRepo(queries), cfg.Network.SubnetPools, cfg.Network.SubnetBits))
 
+	// advisory locks keep the background sweeps to one replica at a time
+	locker := repo.NewLockRepo(db)
 	boxRepo := repo.NewBoxRepo(queries)
-	boxSvc := box.NewSvc(boxRepo, dockerSvc, log)
-	boxHandler := boxhandler.NewHandler(boxSvc, log)
+	snapshotRepo := repo.NewBoxSnapshotRepo(queries)
+	dotfilesRepo := repo.NewDotfilesRepo(queries)
+	envVarRepo := repo.NewEnvVarRepo(queries)
+	sidecarRepo := repo.NewBoxSidecarRepo(queries)
+	boxSvc := box.NewSvc(boxRepo, snapshotRepo, dotfilesRepo, envVarRepo, sidecarRepo, repo.NewAbuseRepo(queries), repo.NewLeaseRepo(queries), repo.NewPowRepo(queries), locker, dockerSvc, cfg.Box, log)
+	origins := restmiddleware.NewOrigins(cfg.Server.AllowedOrigins, cfg.Server.AllowLocalhost, log)
+	boxHandler := boxhandler.NewHandler(boxSvc, origins.CheckOrigin, log)
+	labSvc := lab.NewSvc(repo.NewLabRepo(queries), locker, boxSvc, dockerSvc, cfg.Lab, cfg.Box, log)
+	labHandler := labhandler.NewHandler(labSvc, origins.CheckOrigin, log)
 
-	ctx := context.Background()
+	// SIGINT and SIGTERM begin a graceful shutdown; a second one kills
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	imageName := "gobox-base:latest"
+	imageName := cfg.Box.Image
 	dockerfilePath := "./base-image"
-	networkName := "gobox-c-network"
-	subnet := "172.25.0.0/16"
-
-	// Ensure network
-	_, err = dockerSvc.EnsureNetwork(ctx, networkName, subnet)
-	if err != nil {
-		log.Fatal("Failed to ensure network", zap.Error(err))
-	}
 
 	if err := dockerSvc.EnsureImage(ctx, imageName, dockerfilePath); err != nil {
 		log.Fatal("Failed to ensure docker image", zap.Error(err))
@@ -79,11 +116,25 @@ func RunServer(cfg *config.Config) {
 
 	log.Info("Docker base image ensured")
 
+	released, err := dockerSvc.ReleaseStaleSubnets(ctx)
+	if err != nil {
+		log.Warn("Failed to release stale subnets", zap.Error(err))
+	} else if released > 0 {
+		log.Info("Released stale subnets", zap.Int("count", released))
+	}
+
 	r := chi.NewRouter()
 
+	if cfg.Server.TrustProxy {
+		r.Use(middleware.RealIP)
+	}
 	r.Use(middleware.Logger)
 	r.Use(middleware.Recoverer)
 	r.Use(middleware.RequestID)
+	r.Use(origins.CORS)
+	if cfg.Server.TLS.CertFile != "" && cfg.Server.TLS.HSTSMaxAge > 0 {
+		r.Use(restmiddleware.HSTS(cfg.Server.TLS.HSTSMaxAge))
+	}
 
 	// Routes
 	r.Get("/health", func(w http.ResponseWriter, r *http.Request) {
@@ -95,7 +146,17 @@ func RunServer(cfg *config.Config) {
 		}
 	})
 
-	boxhandler.RegisterRoutes(r, boxHandler)
+	var ipLimiter, ownerLimiter *ratelimit.Limiter
+	if limits := cfg.Server.RateLimit; limits.IPPerMinute > 0 {
+		ipLimiter = ratelimit.New(limits.IPPerMinute, limits.IPBurst)
+	}
+	if limits := cfg.Server.RateLimit; limits.OwnerPerMinute > 0 {
+		ownerLimiter = ratelimit.New(limits.OwnerPerMinute, limits.OwnerBurst)
+	}
+	admit := restmiddleware.Admission(ipLimiter, ownerLimiter, boxHandler.WriteError, log)
+
+	boxhandler.RegisterRoutes(r, boxHandler, admit)
+	labhandler.RegisterRoutes(r, labHandler, admit)
 
 	// Serve static files from the frontend build directory
 	staticPath := "./frontend/build"
@@ -115,9 +176,103 @@ func RunServer(cfg *config.Config) {
 	}))
 
 	addr := fmt.Sprintf(":%s", cfg.Server.Port)
+	srv := &http.Server{Addr: addr, Handler: r}
+	var redirectSrv *http.Server
+
+	if tlsCfg := cfg.Server.TLS; tlsCfg.CertFile != "" {
+		if tlsCfg.SelfSigned {
+			generated, err := certs.EnsureSelfSigned(tlsCfg.CertFile, tlsCfg.KeyFile, tlsCfg.SelfSignedHosts)
+			if err != nil {
+				log.Fatal("Failed to generate self-signed certificate", zap.Error(err))
+			}
+			if generated {
+				log.Warn("Generated a self-signed development certificate",
+					zap.String("cert_file", tlsCfg.CertFile),
+					zap.Strings("hosts", tlsCfg.SelfSignedHosts))
+			}
+		}
+		reloader, err := certs.NewReloader(tlsCfg.CertFile, tlsCfg.KeyFile, log)
+		if err != nil {
+			log.Fatal("Failed to load TLS certificate", zap.Error(err))
+		}
+		go func() {
+			if err := reloader.Watch(ctx); err != nil {
+				log.Error("Stopped watching TLS certificate", zap.Error(err))
+			}
+		}()
 
-	log.Info("Starting server", zap.String("address", addr))
-	if err := http.ListenAndServe(addr, r); err != nil {
+		srv.TLSConfig = &tls.Config{
+			MinVersion:     tls.VersionTLS12,
+			GetCertificate: reloader.GetCertificate,
+		}
+		srv.Protocols = new(http.Protocols)
+		srv.Protocols.SetHTTP1(true)
+		srv.Protocols.SetHTTP2(true)
+
+		if port := tlsCfg.RedirectPort; port != "" {
+			redirectSrv = &http.Server{Addr: ":" + port, Handler: restmiddleware.RedirectHTTPS(cfg.Server.Port)}
+			go func() {
+				log.Info("Redirecting HTTP to HTTPS", zap.String("address", redirectSrv.Addr))
+				if err := redirectSrv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+					log.Fatal("HTTP redirect server failed", zap.Error(err))
+				}
+			}()
+		}
+	}
+
+	// waiting room and runtime metrics, kept off the public port as they
+	// expose the command line and memory statistics
+	var adminSrv *http.Server
+	if cfg.Server.AdminAddr != "" {
+		admin := http.NewServeMux()
+		admin.Handle("/debug/vars", expvar.Handler())
+		adminSrv = &http.Server{Addr: cfg.Server.AdminAddr, Handler: admin}
+		go func() {
+			log.Info("Serving metrics", zap.String("address", adminSrv.Addr))
+			if err := adminSrv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				log.Fatal("Admin server failed", zap.Error(err))
+			}
+		}()
+	}
+
+	serveErr := make(chan error, 1)
+	go func() {
+		if srv.TLSConfig != nil {
+			log.Info("Starting server with TLS", zap.String("address", addr))
+			serveErr <- srv.ListenAndServeTLS("", "")
+			return
+		}
+		log.Info("Starting server", zap.String("address", addr))
+		serveErr <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serveErr:
 		log.Fatal("Server failed", zap.Error(err))
+	case <-ctx.Done():
+		stop()
+	}
+
+	log.Info("Shutting down", zap.Duration("timeout", cfg.Server.ShutdownTimeout))
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+	defer cancel()
+
+	// Shutdown waits for plain requests; terminals are hijacked
+	// connections, drained by the services below
+	if redirectSrv != nil {
+		_ = redirectSrv.Shutdown(shutdownCtx)
+	}
+	if adminSrv != nil {
+		_ = adminSrv.Shutdown(shutdownCtx)
+	}
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Warn("HTTP server did not shut down cleanly", zap.Error(err))
+	}
+	if err := boxSvc.Shutdown(shutdownCtx); err != nil {
+		log.Warn("Box service did not shut down cleanly", zap.Error(err))
+	}
+	if err := labSvc.Shutdown(shutdownCtx); err != nil {
+		log.Warn("Lab service did not shut down cleanly", zap.Error(err))
 	}
+	log.Info("Server stopped")
 }
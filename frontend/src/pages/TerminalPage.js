@@ -3,9 +3,18 @@ import { Terminal } from '@xterm/xterm';
 import { FitAddon } from '@xterm/addon-fit';
 import { WebLinksAddon } from '@xterm/addon-web-links';
 import { getFingerprint } from '../utils/fingerprint';
+import { solveChallenge } from '../utils/pow';
 import '@xterm/xterm/css/xterm.css';
 import './TerminalPage.css';
 
+const getApiUrl = () => {
+  const host = window.location.hostname;
+  const port = window.location.port || (window.location.protocol === 'https:' ? '' : '8010');
+  const finalPort = port ? `:${port}` : '';
+
+  return `${window.location.protocol}//${host}${finalPort}/api/v1/box`;
+};
+
 const getWsUrl = () => {
   const protocol = window.location.protocol === 'https:' ? 'wss:' : 'ws:';
   const host = window.location.hostname;
@@ -20,6 +29,9 @@ const getWsUrl = () => {
 };
 
 const WS_BASE_URL = getWsUrl();
+// Delay before reconnecting after the server restarts
+const RECONNECT_DELAY_MS = 3000;
+const API_BASE_URL = getApiUrl();
 
 // GoBox brand-themed terminal colors
 // Ubuntu-themed terminal colors (Hyper.js style)
@@ -62,6 +74,7 @@ const TerminalPage = () => {
 
   useEffect(() => {
     let isMounted = true;
+    let reconnectTimer = null;
 
     // Prevent double initialization in StrictMode
     if (initialized.current) return;
@@ -113,7 +126,22 @@ const TerminalPage = () => {
 
         console.log('[GoBox] Using Fingerprint:', fingerprint);
         
-        const wsUrl = `${WS_BASE_URL}?fingerprint=${encodeURIComponent(fingerprint)}`;
+        let wsUrl = `${WS_BASE_URL}?fingerprint=${encodeURIComponent(fingerprint)}`;
+
+        // Creating a box requires a solved proof-of-work challenge, which
+        // the server issues to every caller
+        const res = await fetch(`${API_BASE_URL}/challenge?fingerprint=${encodeURIComponent(fingerprint)}`);
+        if (!res.ok) {
+          throw new Error(`Challenge request failed (${res.status})`);
+        }
+        const { challenge, difficulty } = await res.json();
+        if (difficulty > 0) {
+          term.writeln('  \x1b[33m🔐 Solving challenge...\x1b[0m');
+          const solution = await solveChallenge(challenge, difficulty);
+          if (!isMounted) return;
+          wsUrl += `&challenge=${encodeURIComponent(challenge)}&solution=${encodeURIComponent(solution)}`;
+        }
+
         console.log('[GoBox] Opening WebSocket:', wsUrl);
         
         const ws = new WebSocket(wsUrl);
@@ -147,9 +175,15 @@ const TerminalPage = () => {
         ws.onclose = (event) => {
           if (!isMounted) return;
           console.log(`[GoBox] WebSocket Closed (Code: ${event.code})`);
+          wsRef.current = null;
+          // 1012 (service restart): the box keeps running, so reconnect
+          if (event.code === 1012) {
+            term.writeln('\r\n\x1b[33m⏳ Reconnecting...\x1b[0m');
+            reconnectTimer = setTimeout(connect, RECONNECT_DELAY_MS);
+            return;
+          }
           setConnectionStatus('disconnected');
           term.writeln('\r\n\x1b[1;33m⚠ Disconnected from server.\x1b[0m');
-          wsRef.current = null;
         };
 
         ws.onerror = (error) => {
@@ -184,6 +218,7 @@ const TerminalPage = () => {
     return () => {
       console.log('[GoBox] Cleaning up TerminalPage...');
       isMounted = false; // Prevent async callbacks
+      clearTimeout(reconnectTimer);
       initialized.current = false; 
       
       window.removeEventListener('resize', handleResize);
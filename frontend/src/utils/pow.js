@@ -0,0 +1,19 @@
+/**
+ * Solve a GoBox proof-of-work challenge in a Web Worker so the page stays
+ * responsive. Resolves with the solution to present alongside the
+ * challenge when a box is created.
+ */
+export function solveChallenge(challenge, difficulty) {
+  return new Promise((resolve, reject) => {
+    const worker = new Worker(new URL('./pow.worker.js', import.meta.url));
+    worker.onmessage = (event) => {
+      worker.terminate();
+      resolve(event.data.solution);
+    };
+    worker.onerror = (error) => {
+      worker.terminate();
+      reject(error);
+    };
+    worker.postMessage({ challenge, difficulty });
+  });
+}
@@ -0,0 +1,73 @@
+package box
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// activityTracker records terminal traffic for a single session. Any traffic
+// keeps the box from expiring, but only keystrokes count towards the idle
+// timeout so a chatty process cannot keep an abandoned tab alive.
+type activityTracker struct {
+	repo        Repo
+	logger      *zap.Logger
+	fingerprint string
+	interval    time.Duration
+
+	mu        sync.Mutex
+	lastInput time.Time
+	lastTouch time.Time
+}
+
+func newActivityTracker(repo Repo, logger *zap.Logger, fingerprint string, interval time.Duration) *activityTracker {
+	now := time.Now()
+	return &activityTracker{
+		repo:        repo,
+		logger:      logger,
+		fingerprint: fingerprint,
+		interval:    interval,
+		lastInput:   now,
+		lastTouch:   now,
+	}
+}
+
+// input records a keystroke from the user.
+func (a *activityTracker) input(ctx context.Context) {
+	a.mu.Lock()
+	a.lastInput = time.Now()
+	a.mu.Unlock()
+
+	a.touch(ctx)
+}
+
+// output records data written by the container.
+func (a *activityTracker) output(ctx context.Context) {
+	a.touch(ctx)
+}
+
+// lastInputAt returns the time of the last keystroke.
+func (a *activityTracker) lastInputAt() time.Time {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.lastInput
+}
+
+// touch persists last_active at most once per interval.
+func (a *activityTracker) touch(ctx context.Context) {
+	a.mu.Lock()
+	if time.Since(a.lastTouch) < a.interval {
+		a.mu.Unlock()
+		return
+	}
+	a.lastTouch = time.Now()
+	a.mu.Unlock()
+
+	if _, err := a.repo.Touch(ctx, a.fingerprint); err != nil {
+		a.logger.Warn("Failed to record box activity",
+			zap.String("fingerprint", a.fingerprint),
+			zap.Error(err))
+	}
+}
@@ -0,0 +1,129 @@
+package box
+
+import (
+	"context"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/faiyaz032/gobox/internal/domain"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+const (
+	kindImported = "imported"
+	kindExported = "exported"
+)
+
+// checkReplace refuses to overwrite the owner's existing box unless
+// replace is set.
+func (s *Svc) checkReplace(ctx context.Context, fingerprint string, replace bool) error {
+	if replace {
+		return nil
+	}
+	existing, err := s.findBox(ctx, fingerprint)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		return domain.NewConflictError("owner already has a box; set replace to overwrite it")
+	}
+	return nil
+}
+
+// ExportBox writes the owner's box, home directory included, to w as a
+// portable tar.gz archive.
+func (s *Svc) ExportBox(ctx context.Context, fingerprint string, w io.Writer) error {
+	if strings.TrimSpace(fingerprint) == "" {
+		return domain.NewValidationError("fingerprint cannot be empty")
+	}
+
+	b, err := s.repo.GetByFingerprint(ctx, fingerprint)
+	if err != nil {
+		return err
+	}
+	if b.ContainerID == "" {
+		return domain.NewConflictError("box is hibernated; connect to it before exporting")
+	}
+
+	manifest := domain.ArchiveManifest{
+		Version:   domain.ArchiveVersion,
+		Template:  b.Image,
+		Tier:      b.Tier,
+		Resources: domain.ResourceProfiles[s.resolveSpec(b.Spec).Resources],
+		Metadata: map[string]string{
+			"source_box_id": b.ID.String(),
+			"created_at":    b.CreatedAt.UTC().Format(time.RFC3339),
+		},
+		ExportedAt: time.Now().UTC(),
+	}
+
+	if err := s.withHome(ctx, b.ContainerID, map[string]string{
+		labelKind:  kindExported,
+		labelBox:   b.ID.String(),
+		labelOwner: fingerprint,
+	}, func(containerID string) error {
+		return s.dockerSvc.ExportArchive(ctx, containerID, manifest, w, s.cfg.ArchiveMaxBytes)
+	}); err != nil {
+		return err
+	}
+
+	s.logger.Info("Exported box", zap.String("fingerprint", fingerprint))
+	return nil
+}
+
+// ImportBox creates the owner's box from an archive written by ExportBox.
+// An existing box is only overwritten when replace is set. The tier in the
+// manifest is informational only; the box keeps the owner's existing tier.
+func (s *Svc) ImportBox(ctx context.Context, fingerprint string, r io.Reader, replace bool) (*domain.Box, error) {
+	if strings.TrimSpace(fingerprint) == "" {
+		return nil, domain.NewValidationError("fingerprint cannot be empty")
+	}
+
+	if err := s.checkBanned(ctx, fingerprint); err != nil {
+		return nil, err
+	}
+
+	// checked before reading the upload and again once the box is locked
+	if err := s.checkReplace(ctx, fingerprint, replace); err != nil {
+		return nil, err
+	}
+
+	ref := "gobox-import:" + uuid.New().String()
+	manifest, err := s.dockerSvc.ImportArchive(ctx, r, ref, map[string]string{
+		labelKind:  kindImported,
+		labelOwner: fingerprint,
+	}, s.cfg.ArchiveMaxBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	unlock := s.lockBox(fingerprint)
+	err = s.checkReplace(ctx, fingerprint, replace)
+	if err == nil {
+		err = s.checkServedElsewhere(fingerprint)
+	}
+	var imported *domain.Box
+	if err == nil {
+		s.endSessions(fingerprint, "box replaced by an imported archive")
+		imported, err = s.replaceContainer(ctx, fingerprint, ref, nil)
+	}
+	if err != nil {
+		unlock()
+		s.discardImage(ref)
+		return nil, err
+	}
+	// the box now runs on the image, which must stay even if this fails
+	err = s.restoreHome(ctx, ref, imported.ContainerID)
+	unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("Imported box",
+		zap.String("fingerprint", fingerprint),
+		zap.String("template", manifest.Template),
+		zap.Int64("rootfs_size", manifest.RootfsSize))
+	return imported, nil
+}
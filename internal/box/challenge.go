@@ -0,0 +1,65 @@
+package box
+
+import (
+	"context"
+	"strings"
+
+	"github.com/faiyaz032/gobox/internal/domain"
+)
+
+// Challenge issues the proof-of-work challenge the owner solves before a
+// box or a lab is created for it. Servers with challenges disabled give a
+// zero difficulty. Owners with a box get a challenge too: the fingerprint
+// is the client's to choose, so it cannot exempt anyone from the work.
+func (s *Svc) Challenge(ctx context.Context, fingerprint, ip string) (*domain.PowChallenge, error) {
+	if strings.TrimSpace(fingerprint) == "" {
+		return nil, domain.NewValidationError("fingerprint cannot be empty")
+	}
+	if s.pow == nil {
+		return &domain.PowChallenge{}, nil
+	}
+
+	load, err := s.load(ctx)
+	if err != nil {
+		return nil, err
+	}
+	challenge, err := s.pow.Issue(ctx, fingerprint, ip, load)
+	if err != nil {
+		return nil, err
+	}
+	return &challenge, nil
+}
+
+// verifyProof checks the proof presented to create a box. A nil proof
+// means the caller already verified one, e.g. when a connect recreates a
+// box whose container vanished.
+func (s *Svc) verifyProof(ctx context.Context, fingerprint string, proof *domain.ProofOfWork) error {
+	if s.pow == nil || proof == nil {
+		return nil
+	}
+	return s.pow.Verify(ctx, fingerprint, *proof)
+}
+
+// VerifyProof checks the proof presented to create something other than a
+// box, e.g. a lab.
+func (s *Svc) VerifyProof(ctx context.Context, fingerprint string, proof domain.ProofOfWork) error {
+	return s.verifyProof(ctx, fingerprint, &proof)
+}
+
+// load is the share of container capacity in use, counting containers
+// starting and connects waiting for one. It is zero when running
+// containers are not capped.
+func (s *Svc) load(ctx context.Context) (float64, error) {
+	if s.cfg.MaxRunning <= 0 {
+		return 0, nil
+	}
+	running, err := s.dockerSvc.CountRunning(ctx, labelOwner)
+	if err != nil {
+		return 0, err
+	}
+
+	q := s.queue
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return float64(running+q.starting+len(q.waiting)) / float64(s.cfg.MaxRunning), nil
+}
@@ -0,0 +1,130 @@
+package box
+
+import (
+	"context"
+	"strings"
+
+	"github.com/faiyaz032/gobox/internal/domain"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+const kindCloned = "cloned"
+
+// CloneBox copies the caller's box into a new box for target. The source
+// is committed while it keeps running, so the clone starts from the same
+// files, template and resources in a container of its own.
+//
+// For another owner, who must not have a box yet, the clone leaves the
+// spec's variables behind, since they may hold the caller's secrets: it
+// gets the target's own variables instead, and starts on the default tier.
+// An owner has a single box, so a clone onto the caller replaces their box
+// with the copy, keeping its variables and tier. Nothing another owner
+// already has is ever overwritten.
+func (s *Svc) CloneBox(ctx context.Context, fingerprint string, id uuid.UUID, target string) (*domain.Box, error) {
+	if strings.TrimSpace(fingerprint) == "" {
+		return nil, domain.NewValidationError("fingerprint cannot be empty")
+	}
+	target = strings.TrimSpace(target)
+	if target == "" {
+		return nil, domain.NewValidationError("target owner is required")
+	}
+	if err := s.checkBanned(ctx, fingerprint); err != nil {
+		return nil, err
+	}
+
+	// the target is locked before the source is committed, so it cannot
+	// gain a box from this replica in between
+	unlock := s.lockBox(target)
+	defer unlock()
+
+	src, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	// boxes of other owners are reported as missing rather than forbidden
+	if src.FingerprintID != fingerprint {
+		return nil, domain.NewNotFoundError("box", id.String())
+	}
+	if src.ContainerID == "" {
+		return nil, domain.NewConflictError("box is hibernated; connect to it before cloning")
+	}
+
+	var spec *domain.BoxSpec
+	if target == fingerprint {
+		// terminals on this replica are ended below, once the copy is
+		// taken; those elsewhere would lose their container
+		if err := s.checkServedElsewhere(target); err != nil {
+			return nil, err
+		}
+	} else {
+		if err := s.checkCloneTarget(ctx, target); err != nil {
+			return nil, err
+		}
+		// the clone starts on the default tier without the caller's
+		// variables
+		cloneSpec := src.Spec
+		cloneSpec.Lifecycle.Tier = ""
+		cloneSpec.Env = nil
+		spec = &cloneSpec
+	}
+
+	ref := "gobox-clone:" + uuid.NewString()
+	if _, err := s.dockerSvc.CommitContainer(ctx, src.ContainerID, ref, map[string]string{
+		labelKind:  kindCloned,
+		labelBox:   src.ID.String(),
+		labelOwner: target,
+	}); err != nil {
+		return nil, err
+	}
+
+	if target == fingerprint {
+		s.endSessions(target, "box replaced by a clone of itself")
+	}
+	// a nil spec keeps the caller's box's own
+	cloned, err := s.replaceContainer(ctx, target, ref, spec)
+	if err != nil {
+		s.discardImage(ref)
+		return nil, err
+	}
+	// the home volume is not part of the committed image; a clone onto
+	// the caller keeps using theirs
+	if s.cfg.HomeVolumes && target != fingerprint {
+		if err := s.dockerSvc.CopyDirectory(ctx, src.ContainerID, cloned.ContainerID, "/box"); err != nil {
+			return nil, err
+		}
+	}
+
+	s.logger.Info("Cloned box",
+		zap.String("source_box_id", src.ID.String()),
+		zap.String("fingerprint", target),
+		zap.String("container_id", cloned.ContainerID))
+	return cloned, nil
+}
+
+// checkCloneTarget refuses a target that already has a box, or a home
+// volume the clone would write into.
+func (s *Svc) checkCloneTarget(ctx context.Context, target string) error {
+	// a connect on another replica may be creating the target's box
+	if err := s.checkServedElsewhere(target); err != nil {
+		return err
+	}
+	existing, err := s.findBox(ctx, target)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		return domain.NewConflictError("target owner already has a box")
+	}
+	if !s.cfg.HomeVolumes {
+		return nil
+	}
+	_, err = s.dockerSvc.VolumeSize(ctx, homeVolumeName(target))
+	if err == nil {
+		return domain.NewConflictError("target owner already has home data")
+	}
+	if appErr, ok := domain.IsAppError(err); ok && appErr.IsType(domain.ErrorTypeNotFound) {
+		return nil
+	}
+	return err
+}
@@ -11,83 +11,105 @@ import (
 	"go.uber.org/zap"
 )
 
-func (s *Svc) Connect(ctx context.Context, conn *websocket.Conn, fingerprint string) error {
+// Connect attaches conn to the owner's box, creating it if the owner has
+// none. Creating a box requires proof, a solved challenge from Challenge.
+func (s *Svc) Connect(ctx context.Context, conn *websocket.Conn, fingerprint string, proof domain.ProofOfWork) error {
+	if err := s.trackConnect(); err != nil {
+		return err
+	}
+	defer s.connects.Done()
+
+	return s.connect(ctx, conn, fingerprint, &proof)
+}
+
+func (s *Svc) connect(ctx context.Context, conn *websocket.Conn, fingerprint string, proof *domain.ProofOfWork) error {
 	if strings.TrimSpace(fingerprint) == "" {
 		return domain.NewValidationError("fingerprint cannot be empty")
 	}
 
-	s.incrementConnection(fingerprint)
+	if err := s.checkBanned(ctx, fingerprint); err != nil {
+		return err
+	}
 
-	box, err := s.repo.GetByFingerprint(ctx, fingerprint)
-	if err != nil {
+	s.openConnection(fingerprint)
 
-		if appErr, ok := domain.IsAppError(err); !ok || !appErr.IsType(domain.ErrorTypeNotFound) {
-			s.decrementConnection(fingerprint, "")
-			return err
-		}
-		box = nil
+	box, err := s.findBox(ctx, fingerprint)
+	if err != nil {
+		s.closeConnection(fingerprint, nil)
+		return err
 	}
 
 	if box == nil {
-		containerID, err := s.dockerSvc.CreateContainer(ctx)
-		if err != nil {
-			s.decrementConnection(fingerprint, "")
-			return err
-		}
-		if err := s.dockerSvc.StartIfNotRunning(ctx, containerID); err != nil {
-			s.decrementConnection(fingerprint, containerID)
+		if err := s.verifyProof(ctx, fingerprint, proof); err != nil {
+			s.closeConnection(fingerprint, nil)
 			return err
 		}
-		newBox := domain.Box{
-			FingerprintID: fingerprint,
-			ContainerID:   containerID,
-			Status:        domain.StatusRunning,
-			LastActive:    time.Now(),
+	}
+
+	release := func() {}
+	if box == nil || box.Status != domain.StatusRunning {
+		tier := s.cfg.DefaultTier
+		if box != nil {
+			tier = box.Tier
 		}
-		box, err = s.repo.Create(ctx, newBox)
+		release, err = s.admit(ctx, conn, fingerprint, tier)
 		if err != nil {
-			s.decrementConnection(fingerprint, containerID)
-			return err
-		}
-		s.logger.Info("Created new box with container",
-			zap.String("container_id", containerID),
-			zap.String("fingerprint", fingerprint))
-	} else {
-		if err := s.dockerSvc.StartIfNotRunning(ctx, box.ContainerID); err != nil {
-			if appErr, ok := domain.IsAppError(err); ok && appErr.IsType(domain.ErrorTypeNotFound) {
-				s.logger.Warn("Container not found, cleaning up db record and recreating", zap.String("container_id", box.ContainerID))
-				_ = s.repo.Delete(ctx, fingerprint)
-				s.decrementConnection(fingerprint, box.ContainerID)
-				return s.Connect(ctx, conn, fingerprint)
-			}
-			s.decrementConnection(fingerprint, box.ContainerID)
+			s.closeConnection(fingerprint, nil)
 			return err
 		}
+	}
+	// until the box is recorded as running it holds a start slot
+	defer release()
 
-		_, err := s.repo.UpdateStatus(ctx, fingerprint, string(domain.StatusRunning))
-		if err != nil {
-			s.logger.Warn("Failed to update box status to running",
-				zap.String("fingerprint", fingerprint),
-				zap.Error(err))
-		}
-		s.logger.Info("Reconnected to existing box",
-			zap.String("container_id", box.ContainerID),
-			zap.String("fingerprint", fingerprint))
+	// sidecars start under the lock too, so two connects never create
+	// the same one
+	var failed []string
+	unlock := s.lockBox(fingerprint)
+	box, err = s.startBox(ctx, fingerprint)
+	if err == nil {
+		failed = s.startSidecars(ctx, box)
+	}
+	unlock()
+	if err != nil {
+		s.closeConnection(fingerprint, nil)
+		return err
+	}
+
+	release()
+
+	for _, name := range failed {
+		terminalWriter{conn: conn}.status("Service " + name + " failed to start")
+	}
+
+	if box.ProvisionStatus == domain.ProvisionPending {
+		s.provision(ctx, conn, box)
 	}
 
 	attachResp, err := s.dockerSvc.AttachContainer(ctx, box.ContainerID)
 	if err != nil {
 		if appErr, ok := domain.IsAppError(err); ok && appErr.IsType(domain.ErrorTypeNotFound) {
 			s.logger.Warn("Container attached failed (not found), cleaning up db record and recreating", zap.String("container_id", box.ContainerID))
-			_ = s.repo.Delete(ctx, fingerprint)
-			s.decrementConnection(fingerprint, box.ContainerID)
-			return s.Connect(ctx, conn, fingerprint)
+			unlock := s.lockBox(fingerprint)
+			s.discardBox(ctx, *box)
+			unlock()
+			s.closeConnection(fingerprint, nil)
+			return s.connect(ctx, conn, fingerprint, nil)
 		}
-		s.decrementConnection(fingerprint, box.ContainerID)
+		s.closeConnection(fingerprint, nil)
 		return err
 	}
 	defer attachResp.Close()
 
+	activity := newActivityTracker(s.repo, s.logger, fingerprint, s.cfg.ActivityInterval)
+	if _, err := s.repo.Touch(ctx, fingerprint); err != nil {
+		s.logger.Warn("Failed to record box activity",
+			zap.String("fingerprint", fingerprint),
+			zap.Error(err))
+	}
+
+	sess := newSession(*box, conn, activity)
+	s.attachSession(sess)
+
 	done := make(chan struct{})
 
 	// container → websocket
@@ -106,10 +128,11 @@ func (s *Svc) Connect(ctx context.Context, conn *websocket.Conn, fingerprint str
 					return
 				}
 				if n > 0 {
-					if err := conn.WriteMessage(websocket.BinaryMessage, buf[:n]); err != nil {
+					if err := sess.write(buf[:n]); err != nil {
 						s.logger.Error("Error writing to websocket", zap.Error(err))
 						return
 					}
+					activity.output(ctx)
 				}
 			}
 		}
@@ -117,13 +140,20 @@ func (s *Svc) Connect(ctx context.Context, conn *websocket.Conn, fingerprint str
 
 	defer func() {
 		close(done)
-		s.decrementConnection(fingerprint, box.ContainerID)
+		sess.detach()
+		s.closeConnection(fingerprint, sess)
 	}()
 
 	// websocket input → container stdin
 	for {
 		_, msg, err := conn.ReadMessage()
 		if err != nil {
+			if sess.isEnded() {
+				s.logger.Info("WebSocket closed by lifecycle policy",
+					zap.String("fingerprint", fingerprint),
+					zap.String("reason", sess.endReason))
+				return nil
+			}
 			if websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
 				s.logger.Info("WebSocket closed normally")
 				return nil
@@ -133,6 +163,7 @@ func (s *Svc) Connect(ctx context.Context, conn *websocket.Conn, fingerprint str
 		}
 
 		if len(msg) > 0 {
+			activity.input(ctx)
 			_, err := attachResp.Conn.Write(msg)
 			if err != nil {
 				s.logger.Error("Error writing to container stdin", zap.Error(err))
@@ -141,3 +172,126 @@ func (s *Svc) Connect(ctx context.Context, conn *websocket.Conn, fingerprint str
 		}
 	}
 }
+
+// findBox returns the owner's box, or nil when they have none.
+func (s *Svc) findBox(ctx context.Context, fingerprint string) (*domain.Box, error) {
+	b, err := s.repo.GetByFingerprint(ctx, fingerprint)
+	if appErr, ok := domain.IsAppError(err); ok && appErr.IsType(domain.ErrorTypeNotFound) {
+		return nil, nil
+	}
+	return b, err
+}
+
+// startBox brings the owner's box up, creating it if they have none and
+// restoring it if it is hibernated. A box whose container or image is gone
+// is replaced by a new one. The caller holds the box lock.
+func (s *Svc) startBox(ctx context.Context, fingerprint string) (*domain.Box, error) {
+	box, err := s.findBox(ctx, fingerprint)
+	if err != nil {
+		return nil, err
+	}
+	if box == nil {
+		return s.createDefaultBox(ctx, fingerprint)
+	}
+
+	if box.EnvStale {
+		box, err = s.applyStaleEnv(ctx, box)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if box.Status == domain.StatusHibernated {
+		restored, err := s.restore(ctx, box)
+		if appErr, ok := domain.IsAppError(err); ok && appErr.IsType(domain.ErrorTypeNotFound) {
+			s.logger.Warn("Hibernated image not found, cleaning up db record and recreating", zap.String("fingerprint", fingerprint))
+			return s.recreateBox(ctx, *box)
+		}
+		if err != nil {
+			return nil, err
+		}
+		box = restored
+	}
+
+	if err := s.dockerSvc.StartIfNotRunning(ctx, box.ContainerID); err != nil {
+		if appErr, ok := domain.IsAppError(err); ok && appErr.IsType(domain.ErrorTypeNotFound) {
+			s.logger.Warn("Container not found, cleaning up db record and recreating", zap.String("container_id", box.ContainerID))
+			return s.recreateBox(ctx, *box)
+		}
+		return nil, err
+	}
+
+	if _, err := s.repo.UpdateStatus(ctx, fingerprint, string(domain.StatusRunning)); err != nil {
+		s.logger.Warn("Failed to update box status to running",
+			zap.String("fingerprint", fingerprint),
+			zap.Error(err))
+	}
+	box.Status = domain.StatusRunning
+	s.logger.Info("Reconnected to existing box",
+		zap.String("container_id", box.ContainerID),
+		zap.String("fingerprint", fingerprint))
+	return box, nil
+}
+
+// createDefaultBox creates and starts a box with the default spec. The
+// caller holds the box lock.
+func (s *Svc) createDefaultBox(ctx context.Context, fingerprint string) (*domain.Box, error) {
+	spec := s.resolveSpec(domain.BoxSpec{})
+	image := s.cfg.Templates[spec.Template].Image
+	containerID, err := s.createContainer(ctx, fingerprint, image, spec)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.dockerSvc.StartIfNotRunning(ctx, containerID); err != nil {
+		_ = s.dockerSvc.RemoveContainer(ctx, containerID)
+		return nil, err
+	}
+	box, err := s.repo.Create(ctx, domain.Box{
+		FingerprintID: fingerprint,
+		ContainerID:   containerID,
+		Status:        domain.StatusRunning,
+		LastActive:    time.Now(),
+		Tier:          spec.Lifecycle.Tier,
+		Image:         image,
+		Spec:          spec,
+
+		ProvisionStatus: s.initialProvisionStatus(spec),
+	})
+	if err != nil {
+		_ = s.dockerSvc.RemoveContainer(ctx, containerID)
+		return nil, err
+	}
+	s.logger.Info("Created new box with container",
+		zap.String("container_id", containerID),
+		zap.String("fingerprint", fingerprint))
+	return box, nil
+}
+
+// recreateBox replaces a box whose container or image disappeared with a
+// new default box. When another replica changed the box since it was read,
+// e.g. hibernated it and removed its container, the box is started again
+// as it now is instead. The caller holds the box lock.
+func (s *Svc) recreateBox(ctx context.Context, b domain.Box) (*domain.Box, error) {
+	if !s.discardBox(ctx, b) {
+		return s.startBox(ctx, b.FingerprintID)
+	}
+	return s.createDefaultBox(ctx, b.FingerprintID)
+}
+
+// discardBox forgets a box whose container or image disappeared, and
+// reports whether it did. The record is only deleted while it still holds
+// what b was read with: this lock is per process, so the database decides
+// between replicas. The caller holds the box lock.
+func (s *Svc) discardBox(ctx context.Context, b domain.Box) bool {
+	discarded, err := s.repo.DeleteIfUnchanged(ctx, b)
+	if err != nil {
+		s.logger.Error("failed to delete box from db",
+			zap.String("fingerprint", b.FingerprintID),
+			zap.Error(err))
+	}
+	if !discarded {
+		return false
+	}
+	s.removeSidecars(ctx, b.FingerprintID)
+	return true
+}
@@ -0,0 +1,231 @@
+package box
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/faiyaz032/gobox/internal/domain"
+	"go.uber.org/zap"
+)
+
+const maxDotfiles = 256
+
+// UploadDotfiles replaces the owner's dotfiles with the contents of a tar
+// or tar.gz archive whose paths are relative to /box.
+func (s *Svc) UploadDotfiles(ctx context.Context, fingerprint string, r io.Reader) (*domain.Dotfiles, error) {
+	if strings.TrimSpace(fingerprint) == "" {
+		return nil, domain.NewValidationError("fingerprint cannot be empty")
+	}
+
+	br := bufio.NewReader(r)
+	var src io.Reader = br
+	if magic, err := br.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, domain.NewValidationError("dotfiles archive is not a valid gzip stream")
+		}
+		defer gz.Close()
+		src = gz
+	}
+
+	b := newDotfilesBuilder(s.cfg.DotfilesMaxBytes)
+	tr := tar.NewReader(src)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, domain.NewValidationError("dotfiles archive is not a valid tar archive")
+		}
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			// parents are recreated for every file
+		case tar.TypeReg:
+			if err := b.add(hdr.Name, hdr.Mode, tr, hdr.Size); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, domain.NewValidationError(fmt.Sprintf("dotfiles: %s: only regular files and directories are supported", hdr.Name))
+		}
+	}
+
+	return s.saveDotfiles(ctx, fingerprint, b)
+}
+
+// SetDotfiles replaces the owner's dotfiles with individual files whose
+// paths are relative to /box.
+func (s *Svc) SetDotfiles(ctx context.Context, fingerprint string, files []domain.SpecFile) (*domain.Dotfiles, error) {
+	if strings.TrimSpace(fingerprint) == "" {
+		return nil, domain.NewValidationError("fingerprint cannot be empty")
+	}
+
+	b := newDotfilesBuilder(s.cfg.DotfilesMaxBytes)
+	for _, f := range files {
+		mode := int64(0644)
+		if f.Mode != "" {
+			m, err := strconv.ParseInt(f.Mode, 8, 32)
+			if err != nil || m > 0777 {
+				return nil, domain.NewValidationError(fmt.Sprintf("dotfiles: %s: invalid mode %q", f.Path, f.Mode))
+			}
+			mode = m
+		}
+		if err := b.add(f.Path, mode, strings.NewReader(f.Content), int64(len(f.Content))); err != nil {
+			return nil, err
+		}
+	}
+
+	return s.saveDotfiles(ctx, fingerprint, b)
+}
+
+func (s *Svc) GetDotfiles(ctx context.Context, fingerprint string) (*domain.Dotfiles, error) {
+	if strings.TrimSpace(fingerprint) == "" {
+		return nil, domain.NewValidationError("fingerprint cannot be empty")
+	}
+	return s.dotfiles.Get(ctx, fingerprint)
+}
+
+func (s *Svc) DeleteDotfiles(ctx context.Context, fingerprint string) error {
+	if strings.TrimSpace(fingerprint) == "" {
+		return domain.NewValidationError("fingerprint cannot be empty")
+	}
+	return s.dotfiles.Delete(ctx, fingerprint)
+}
+
+func (s *Svc) saveDotfiles(ctx context.Context, fingerprint string, b *dotfilesBuilder) (*domain.Dotfiles, error) {
+	if len(b.files) == 0 {
+		return nil, domain.NewValidationError("dotfiles cannot be empty")
+	}
+	if err := b.tw.Close(); err != nil {
+		return nil, domain.NewInternalError("failed to write dotfiles archive", err)
+	}
+
+	dotfiles, err := s.dotfiles.Upsert(ctx, domain.Dotfiles{
+		FingerprintID: fingerprint,
+		Archive:       b.buf.Bytes(),
+		Files:         b.files,
+		SizeBytes:     b.size,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("Saved dotfiles",
+		zap.String("fingerprint", fingerprint),
+		zap.Int("files", len(b.files)),
+		zap.Int64("size_bytes", b.size))
+	return dotfiles, nil
+}
+
+// injectDotfiles copies the owner's dotfiles into /box of a new container
+// unless the box opted out. Failures are logged rather than returned so a
+// broken archive never keeps a user out of their box.
+func (s *Svc) injectDotfiles(ctx context.Context, fingerprint, containerID string, spec domain.BoxSpec) {
+	if spec.SkipDotfiles {
+		return
+	}
+
+	dotfiles, err := s.dotfiles.Get(ctx, fingerprint)
+	if err != nil {
+		if appErr, ok := domain.IsAppError(err); !ok || !appErr.IsType(domain.ErrorTypeNotFound) {
+			s.logger.Warn("Failed to load dotfiles",
+				zap.String("fingerprint", fingerprint),
+				zap.Error(err))
+		}
+		return
+	}
+
+	if err := s.dockerSvc.CopyArchive(ctx, containerID, "/box", bytes.NewReader(dotfiles.Archive)); err != nil {
+		s.logger.Warn("Failed to copy dotfiles into container",
+			zap.String("fingerprint", fingerprint),
+			zap.String("container_id", containerID),
+			zap.Error(err))
+	}
+}
+
+// dotfilesBuilder normalizes uploaded dotfiles into a tar archive of
+// regular files and their parent directories, enforcing the size limits.
+type dotfilesBuilder struct {
+	buf      bytes.Buffer
+	tw       *tar.Writer
+	maxBytes int64
+	dirs     map[string]bool
+	names    map[string]bool
+	files    []string
+	size     int64
+}
+
+func newDotfilesBuilder(maxBytes int64) *dotfilesBuilder {
+	b := &dotfilesBuilder{
+		maxBytes: maxBytes,
+		dirs:     make(map[string]bool),
+		names:    make(map[string]bool),
+	}
+	b.tw = tar.NewWriter(&b.buf)
+	return b
+}
+
+func (b *dotfilesBuilder) add(name string, mode int64, r io.Reader, size int64) error {
+	name = path.Clean(strings.TrimPrefix(name, "./"))
+	if path.IsAbs(name) || name == "." || name == ".." || strings.HasPrefix(name, "../") {
+		return domain.NewValidationError(fmt.Sprintf("dotfiles: %s: path must be relative to /box", name))
+	}
+	if b.names[name] || b.dirs[name] {
+		return domain.NewValidationError(fmt.Sprintf("dotfiles: %s: duplicate path", name))
+	}
+	if len(b.files) >= maxDotfiles {
+		return domain.NewValidationError(fmt.Sprintf("dotfiles cannot contain more than %d files", maxDotfiles))
+	}
+	b.size += size
+	if b.maxBytes > 0 && b.size > b.maxBytes {
+		return domain.NewValidationError(fmt.Sprintf("dotfiles cannot exceed %d bytes", b.maxBytes))
+	}
+
+	var parents []string
+	for dir := path.Dir(name); dir != "." && !b.dirs[dir]; dir = path.Dir(dir) {
+		if b.names[dir] {
+			return domain.NewValidationError(fmt.Sprintf("dotfiles: %s: parent %s is a file", name, dir))
+		}
+		b.dirs[dir] = true
+		parents = append(parents, dir)
+	}
+	now := time.Now()
+	for i := len(parents) - 1; i >= 0; i-- {
+		if err := b.tw.WriteHeader(&tar.Header{
+			Typeflag: tar.TypeDir,
+			Name:     parents[i] + "/",
+			Mode:     0755,
+			ModTime:  now,
+		}); err != nil {
+			return domain.NewInternalError("failed to write dotfiles archive", err)
+		}
+	}
+
+	if err := b.tw.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     name,
+		Mode:     mode & 0777,
+		Size:     size,
+		ModTime:  now,
+	}); err != nil {
+		return domain.NewInternalError("failed to write dotfiles archive", err)
+	}
+	if _, err := io.CopyN(b.tw, r, size); err != nil {
+		return domain.NewValidationError(fmt.Sprintf("dotfiles: %s: truncated file", name))
+	}
+
+	b.names[name] = true
+	b.files = append(b.files, name)
+	return nil
+}
@@ -0,0 +1,377 @@
+package box
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/faiyaz032/gobox/internal/domain"
+	"go.uber.org/zap"
+)
+
+// ListEnv returns the owner's variables. Secret values are never included.
+func (s *Svc) ListEnv(ctx context.Context, fingerprint string) ([]domain.EnvVar, error) {
+	if strings.TrimSpace(fingerprint) == "" {
+		return nil, domain.NewValidationError("fingerprint cannot be empty")
+	}
+
+	vars, err := s.envVars.List(ctx, fingerprint)
+	if err != nil {
+		return nil, err
+	}
+	for i := range vars {
+		vars[i].Value = redact(vars[i])
+	}
+
+	b, err := s.repo.GetByFingerprint(ctx, fingerprint)
+	if err != nil {
+		if appErr, ok := domain.IsAppError(err); ok && appErr.IsType(domain.ErrorTypeNotFound) {
+			return vars, nil
+		}
+		return nil, err
+	}
+	for _, kv := range specEnv(b.Spec.Env) {
+		name, value, _ := strings.Cut(kv, "=")
+		vars = append(vars, domain.EnvVar{
+			Name:      name,
+			Scope:     domain.EnvScopeBox,
+			Value:     value,
+			Injection: domain.EnvAtCreate,
+		})
+	}
+	return vars, nil
+}
+
+// SetEnv creates or replaces one of the owner's variables, sealing it when
+// it is a secret. A change to a variable injected at creation reaches the
+// existing container as apply says.
+func (s *Svc) SetEnv(ctx context.Context, fingerprint string, v domain.EnvVar, apply string) (*domain.EnvVar, error) {
+	if strings.TrimSpace(fingerprint) == "" {
+		return nil, domain.NewValidationError("fingerprint cannot be empty")
+	}
+	if v.Scope == "" {
+		v.Scope = domain.EnvScopeUser
+	}
+	if v.Injection == "" {
+		v.Injection = domain.EnvAtCreate
+	}
+	if err := validateEnvVar(v, apply); err != nil {
+		return nil, err
+	}
+	if v.Scope == domain.EnvScopeBox {
+		return s.setBoxEnv(ctx, fingerprint, v, apply)
+	}
+
+	v.FingerprintID = fingerprint
+	if v.Secret {
+		if len(s.cfg.SecretKey) == 0 {
+			return nil, domain.NewConflictError("secrets are disabled on this server")
+		}
+		sealed, err := s.seal(fingerprint, v.Name, v.Value)
+		if err != nil {
+			return nil, err
+		}
+		v.Ciphertext = sealed
+		v.Value = ""
+	} else {
+		existing, err := s.envVars.List(ctx, fingerprint)
+		if err != nil {
+			return nil, err
+		}
+		if len(existing) >= maxSpecEnv && !slices.ContainsFunc(existing, func(e domain.EnvVar) bool { return e.Name == v.Name }) {
+			return nil, domain.NewValidationError(fmt.Sprintf("cannot set more than %d variables", maxSpecEnv))
+		}
+	}
+
+	saved, err := s.envVars.Upsert(ctx, v)
+	if err != nil {
+		return nil, err
+	}
+	saved.Value = redact(*saved)
+
+	if v.Injection == domain.EnvAtCreate {
+		if err := s.applyEnv(ctx, fingerprint, apply); err != nil {
+			return nil, err
+		}
+	}
+
+	s.logger.Info("Set env var",
+		zap.String("fingerprint", fingerprint),
+		zap.String("name", v.Name),
+		zap.Bool("secret", v.Secret))
+	return saved, nil
+}
+
+// DeleteEnv removes one of the owner's or their box's variables.
+func (s *Svc) DeleteEnv(ctx context.Context, fingerprint, scope, name, apply string) error {
+	if strings.TrimSpace(fingerprint) == "" {
+		return domain.NewValidationError("fingerprint cannot be empty")
+	}
+	if err := validateApply(apply); err != nil {
+		return err
+	}
+
+	if scope == domain.EnvScopeBox {
+		b, err := s.repo.GetByFingerprint(ctx, fingerprint)
+		if err != nil {
+			return err
+		}
+		if _, ok := b.Spec.Env[name]; !ok {
+			return domain.NewNotFoundError("env var", name)
+		}
+		spec := b.Spec
+		spec.Env = maps.Clone(b.Spec.Env)
+		delete(spec.Env, name)
+		if _, err := s.repo.UpdateSpec(ctx, fingerprint, spec); err != nil {
+			return err
+		}
+	} else if err := s.envVars.Delete(ctx, fingerprint, name); err != nil {
+		return err
+	}
+	return s.applyEnv(ctx, fingerprint, apply)
+}
+
+// setBoxEnv sets a variable in the spec of the owner's box.
+func (s *Svc) setBoxEnv(ctx context.Context, fingerprint string, v domain.EnvVar, apply string) (*domain.EnvVar, error) {
+	b, err := s.repo.GetByFingerprint(ctx, fingerprint)
+	if err != nil {
+		return nil, err
+	}
+
+	spec := b.Spec
+	spec.Env = maps.Clone(b.Spec.Env)
+	if spec.Env == nil {
+		spec.Env = make(map[string]string)
+	}
+	spec.Env[v.Name] = v.Value
+	if len(spec.Env) > maxSpecEnv {
+		return nil, domain.NewValidationError(fmt.Sprintf("cannot set more than %d variables", maxSpecEnv))
+	}
+	if _, err := s.repo.UpdateSpec(ctx, fingerprint, spec); err != nil {
+		return nil, err
+	}
+
+	if err := s.applyEnv(ctx, fingerprint, apply); err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+// applyEnv brings the owner's existing container in line with their
+// variables, either now or on the next shell.
+func (s *Svc) applyEnv(ctx context.Context, fingerprint, apply string) error {
+	if apply != domain.EnvApplyRecreate {
+		err := s.repo.SetEnvStale(ctx, fingerprint, true)
+		if appErr, ok := domain.IsAppError(err); ok && appErr.IsType(domain.ErrorTypeNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	unlock := s.lockBox(fingerprint)
+	defer unlock()
+
+	b, err := s.findBox(ctx, fingerprint)
+	if err != nil || b == nil {
+		return err
+	}
+	if err := s.checkServedElsewhere(fingerprint); err != nil {
+		return err
+	}
+	s.endSessions(fingerprint, "box restarted to apply environment changes")
+	_, err = s.recreateContainer(ctx, b)
+	return err
+}
+
+// applyStaleEnv recreates the container of a box whose variables changed,
+// unless another terminal, on any replica, is still attached to it. The
+// caller holds the box lock.
+func (s *Svc) applyStaleEnv(ctx context.Context, b *domain.Box) (*domain.Box, error) {
+	if s.hasSessions(b.FingerprintID) || s.servedElsewhere(b.FingerprintID, time.Now()) {
+		return b, nil
+	}
+	return s.recreateContainer(ctx, b)
+}
+
+// recreateContainer commits the box's container and replaces it with one
+// created from the commit, so configuration changes take effect without
+// losing files. The caller holds the box lock.
+func (s *Svc) recreateContainer(ctx context.Context, b *domain.Box) (*domain.Box, error) {
+	// a hibernated box picks up the change when it is restored
+	if b.ContainerID == "" {
+		if err := s.repo.SetEnvStale(ctx, b.FingerprintID, false); err != nil {
+			return nil, err
+		}
+		b.EnvStale = false
+		return b, nil
+	}
+
+	// the saved image keeps none of the owner's variables; the new
+	// container gets their current ones
+	ref := hibernatedRef(*b)
+	if err := s.saveBox(ctx, *b, ref); err != nil {
+		return nil, err
+	}
+
+	containerID, err := s.createContainer(ctx, b.FingerprintID, ref, b.Spec)
+	if err != nil {
+		return nil, err
+	}
+	updated, err := s.repo.UpdateContainer(ctx, b.FingerprintID, containerID, ref, domain.StatusStopped)
+	if err != nil {
+		_ = s.dockerSvc.RemoveContainer(ctx, containerID)
+		return nil, err
+	}
+	if err := s.dockerSvc.RemoveContainer(ctx, b.ContainerID); err != nil {
+		s.logger.Warn("failed to remove recreated container",
+			zap.String("container_id", b.ContainerID),
+			zap.Error(err))
+	}
+	if err := s.repo.SetEnvStale(ctx, b.FingerprintID, false); err != nil {
+		return nil, err
+	}
+	updated.EnvStale = false
+
+	s.logger.Info("Recreated container to apply environment changes",
+		zap.String("fingerprint", b.FingerprintID),
+		zap.String("container_id", containerID))
+	return updated, nil
+}
+
+// ownerEnv returns the owner's variables injected the given way, with
+// secrets unsealed. Secrets that fail to unseal are skipped.
+func (s *Svc) ownerEnv(ctx context.Context, fingerprint string, injection domain.EnvInjection) (map[string]string, error) {
+	vars, err := s.envVars.List(ctx, fingerprint)
+	if err != nil {
+		return nil, err
+	}
+
+	env := make(map[string]string, len(vars))
+	for _, v := range vars {
+		if v.Injection != injection {
+			continue
+		}
+		if !v.Secret {
+			env[v.Name] = v.Value
+			continue
+		}
+		value, err := s.open(fingerprint, v.Name, v.Ciphertext)
+		if err != nil {
+			s.logger.Warn("Failed to unseal secret",
+				zap.String("fingerprint", fingerprint),
+				zap.String("name", v.Name),
+				zap.Error(err))
+			continue
+		}
+		env[v.Name] = value
+	}
+	return env, nil
+}
+
+// execEnv is the environment of commands GoBox executes in the owner's
+// container.
+func (s *Svc) execEnv(ctx context.Context, fingerprint string) []string {
+	env, err := s.ownerEnv(ctx, fingerprint, domain.EnvInExec)
+	if err != nil {
+		s.logger.Warn("Failed to load exec env",
+			zap.String("fingerprint", fingerprint),
+			zap.Error(err))
+		return nil
+	}
+	return specEnv(env)
+}
+
+// seal encrypts a secret with AES-256-GCM, binding it to its owner and
+// name. The nonce is prepended to the ciphertext.
+func (s *Svc) seal(fingerprint, name, value string) ([]byte, error) {
+	gcm, err := s.secretCipher()
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, domain.NewInternalError("failed to generate nonce", err)
+	}
+	return gcm.Seal(nonce, nonce, []byte(value), secretAAD(fingerprint, name)), nil
+}
+
+func (s *Svc) open(fingerprint, name string, sealed []byte) (string, error) {
+	gcm, err := s.secretCipher()
+	if err != nil {
+		return "", err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return "", domain.NewInternalError("sealed secret is truncated", nil)
+	}
+	nonce, ciphertext := sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():]
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, secretAAD(fingerprint, name))
+	if err != nil {
+		return "", domain.NewInternalError("failed to unseal secret", err)
+	}
+	return string(plaintext), nil
+}
+
+func (s *Svc) secretCipher() (cipher.AEAD, error) {
+	if len(s.cfg.SecretKey) == 0 {
+		return nil, domain.NewConflictError("secrets are disabled on this server")
+	}
+	block, err := aes.NewCipher(s.cfg.SecretKey)
+	if err != nil {
+		return nil, domain.NewInternalError("invalid secret key", err)
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, domain.NewInternalError("invalid secret key", err)
+	}
+	return gcm, nil
+}
+
+func secretAAD(fingerprint, name string) []byte {
+	return []byte(fingerprint + "\x00" + name)
+}
+
+func validateEnvVar(v domain.EnvVar, apply string) error {
+	switch v.Scope {
+	case domain.EnvScopeUser:
+	case domain.EnvScopeBox:
+		if v.Secret || v.Injection != domain.EnvAtCreate {
+			return domain.NewValidationError("scope: box variables cannot be secrets or exec-only")
+		}
+	default:
+		return domain.NewValidationError(fmt.Sprintf("scope: must be %q or %q", domain.EnvScopeUser, domain.EnvScopeBox))
+	}
+	if !envNamePattern.MatchString(v.Name) {
+		return domain.NewValidationError("name: invalid variable name")
+	}
+	if len(v.Value) > maxSpecEnvValue {
+		return domain.NewValidationError(fmt.Sprintf("value: cannot be longer than %d bytes", maxSpecEnvValue))
+	}
+	if strings.ContainsRune(v.Value, 0) {
+		return domain.NewValidationError("value: cannot contain NUL bytes")
+	}
+	if v.Injection != domain.EnvAtCreate && v.Injection != domain.EnvInExec {
+		return domain.NewValidationError(fmt.Sprintf("injection: must be %q or %q", domain.EnvAtCreate, domain.EnvInExec))
+	}
+	return validateApply(apply)
+}
+
+func validateApply(apply string) error {
+	switch apply {
+	case "", domain.EnvApplyNextShell, domain.EnvApplyRecreate:
+		return nil
+	}
+	return domain.NewValidationError(fmt.Sprintf("apply: must be %q or %q", domain.EnvApplyNextShell, domain.EnvApplyRecreate))
+}
+
+func redact(v domain.EnvVar) string {
+	if v.Secret {
+		return ""
+	}
+	return v.Value
+}
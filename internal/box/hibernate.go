@@ -0,0 +1,187 @@
+package box
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"github.com/faiyaz032/gobox/internal/domain"
+	"go.uber.org/zap"
+)
+
+// Labels put on the containers, images and volumes GoBox creates so they
+// can be found and collected.
+const (
+	labelKind  = "gobox.kind"
+	labelBox   = "gobox.box"
+	labelOwner = "gobox.owner"
+
+	kindHibernated = "hibernated"
+
+	// maxBoxLayers is the layer depth past which a box's container is
+	// flattened rather than committed on top of its image.
+	maxBoxLayers = 32
+)
+
+func hibernatedRef(b domain.Box) string {
+	return "gobox-hibernated:" + b.ID.String()
+}
+
+// hibernateStopped commits boxes that stayed stopped past their
+// HibernateAfter to an image and removes their containers, freeing disk on
+// the Docker host without losing user files. With home volumes /box is
+// not in the image but stays on the owner's volume until the box is
+// restored.
+func (s *Svc) hibernateStopped(now time.Time) {
+	boxes, err := s.repo.ListByStatus(context.Background(), domain.StatusStopped)
+	if err != nil {
+		s.logger.Error("failed to list stopped boxes", zap.Error(err))
+		return
+	}
+
+	for _, b := range boxes {
+		policy := s.policy(b.Tier, b.Spec.Lifecycle)
+		if policy.HibernateAfter <= 0 || now.Sub(b.LastActive) < policy.HibernateAfter {
+			continue
+		}
+		claimed, unlock := s.claim(b, now)
+		if claimed == nil {
+			continue
+		}
+		s.hibernate(*claimed)
+		unlock()
+	}
+}
+
+// hibernate commits a stopped box and removes its container. The caller
+// holds the box lock.
+func (s *Svc) hibernate(b domain.Box) {
+	ctx := context.Background()
+	ref := hibernatedRef(b)
+
+	if err := s.saveBox(ctx, b, ref); err != nil {
+		s.logger.Error("failed to commit container for hibernation",
+			zap.String("container_id", b.ContainerID),
+			zap.Error(err))
+		return
+	}
+
+	// the record moves off the container before it is removed, so a
+	// connect on another replica that finds the container gone sees the
+	// box changed rather than discarding it
+	if _, err := s.repo.UpdateContainer(ctx, b.FingerprintID, "", ref, domain.StatusHibernated); err != nil {
+		s.logger.Error("failed to mark box hibernated",
+			zap.String("fingerprint", b.FingerprintID),
+			zap.Error(err))
+		return
+	}
+
+	if err := s.dockerSvc.RemoveContainer(ctx, b.ContainerID); err != nil {
+		s.logger.Error("failed to remove hibernated container",
+			zap.String("container_id", b.ContainerID),
+			zap.Error(err))
+	}
+
+	s.logger.Info("Box hibernated",
+		zap.String("fingerprint", b.FingerprintID),
+		zap.String("image", ref))
+}
+
+// saveBox saves the box's container as its hibernated image ref. Each save
+// stacks a layer on the image the container came from, so once the chain
+// gets deep the filesystem is flattened into a single layer instead.
+func (s *Svc) saveBox(ctx context.Context, b domain.Box, ref string) error {
+	labels := map[string]string{
+		labelKind:  kindHibernated,
+		labelBox:   b.ID.String(),
+		labelOwner: b.FingerprintID,
+	}
+
+	layers, err := s.dockerSvc.ImageLayers(ctx, b.Image)
+	if err != nil {
+		return err
+	}
+	if layers >= maxBoxLayers {
+		_, err = s.dockerSvc.FlattenContainer(ctx, b.ContainerID, ref, labels)
+		return err
+	}
+	_, err = s.dockerSvc.CommitContainer(ctx, b.ContainerID, ref, labels)
+	return err
+}
+
+// restore recreates the container of a hibernated box from its image with
+// the same settings as the original. The box is left stopped for Connect
+// to start. The caller holds the box lock.
+func (s *Svc) restore(ctx context.Context, b *domain.Box) (*domain.Box, error) {
+	containerID, err := s.createContainer(ctx, b.FingerprintID, b.Image, b.Spec)
+	if err != nil {
+		return nil, err
+	}
+
+	restored, err := s.repo.UpdateContainer(ctx, b.FingerprintID, containerID, b.Image, domain.StatusStopped)
+	if err != nil {
+		_ = s.dockerSvc.RemoveContainer(ctx, containerID)
+		return nil, err
+	}
+
+	s.logger.Info("Box restored from hibernation",
+		zap.String("fingerprint", b.FingerprintID),
+		zap.String("container_id", containerID))
+	return restored, nil
+}
+
+// collectImages removes hibernated, imported and cloned images whose box
+// no longer uses them and prunes the dangling layers left behind when a box
+// is hibernated again.
+func (s *Svc) collectImages() {
+	for _, kind := range []string{kindHibernated, kindImported, kindCloned} {
+		s.collectImagesOfKind(kind)
+	}
+}
+
+func (s *Svc) collectImagesOfKind(kind string) {
+	ctx := context.Background()
+	label := labelKind + "=" + kind
+
+	images, err := s.dockerSvc.ListImages(ctx, label)
+	if err != nil {
+		s.logger.Error("failed to list images", zap.String("kind", kind), zap.Error(err))
+		return
+	}
+
+	for _, img := range images {
+		if len(img.RepoTags) == 0 {
+			continue
+		}
+
+		b, err := s.repo.GetByFingerprint(ctx, img.Labels[labelOwner])
+		if err == nil && slices.Contains(img.RepoTags, b.Image) {
+			continue
+		}
+		if appErr, ok := domain.IsAppError(err); err != nil && (!ok || !appErr.IsType(domain.ErrorTypeNotFound)) {
+			continue
+		}
+
+		if err := s.dockerSvc.RemoveImage(ctx, img.ID); err != nil {
+			// still the base of a container or a newer image
+			if appErr, ok := domain.IsAppError(err); ok && appErr.IsType(domain.ErrorTypeConflict) {
+				continue
+			}
+			s.logger.Warn("failed to remove orphaned image",
+				zap.String("kind", kind),
+				zap.String("image_id", img.ID),
+				zap.Error(err))
+			continue
+		}
+		s.logger.Info("removed orphaned image", zap.String("kind", kind), zap.String("image_id", img.ID))
+	}
+
+	reclaimed, err := s.dockerSvc.PruneImages(ctx, label)
+	if err != nil {
+		s.logger.Error("failed to prune images", zap.String("kind", kind), zap.Error(err))
+		return
+	}
+	if reclaimed > 0 {
+		s.logger.Info("pruned images", zap.String("kind", kind), zap.Uint64("bytes_reclaimed", reclaimed))
+	}
+}
@@ -0,0 +1,156 @@
+package box
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/faiyaz032/gobox/internal/domain"
+	"go.uber.org/zap"
+)
+
+// leaseSet is the boxes this replica has terminals open to. The
+// scheduler only records changes here; runLeases is the one writing them
+// to the database, so the scheduler never waits on it and a renewal cannot
+// resurrect a lease that was just released.
+type leaseSet struct {
+	mu   sync.Mutex
+	held map[string]struct{}
+	// pending maps the boxes whose lease changed since the last sync to
+	// whether it is held.
+	pending map[string]bool
+	kick    chan struct{}
+}
+
+func newLeaseSet() *leaseSet {
+	return &leaseSet{
+		held:    make(map[string]struct{}),
+		pending: make(map[string]bool),
+		kick:    make(chan struct{}, 1),
+	}
+}
+
+// holdLease tells other replicas this one serves the box, keeping them
+// from pausing or removing it.
+func (s *Svc) holdLease(fingerprint string) {
+	l := s.leaseSet
+	l.mu.Lock()
+	l.held[fingerprint] = struct{}{}
+	l.pending[fingerprint] = true
+	l.mu.Unlock()
+	l.signal()
+}
+
+func (s *Svc) dropLease(fingerprint string) {
+	l := s.leaseSet
+	l.mu.Lock()
+	delete(l.held, fingerprint)
+	l.pending[fingerprint] = false
+	l.mu.Unlock()
+	l.signal()
+}
+
+func (l *leaseSet) signal() {
+	select {
+	case l.kick <- struct{}{}:
+	default:
+	}
+}
+
+// runLeases writes lease changes as they happen, renews the held leases
+// well before they expire and drops the leases of replicas that stopped
+// renewing theirs. Each renewal also passes the watchdog's notices on to
+// the terminals attached here.
+func (s *Svc) runLeases() {
+	ticker := time.NewTicker(s.cfg.LeaseTTL / 3)
+	defer ticker.Stop()
+
+	noticesSince := time.Now()
+
+	for {
+		select {
+		case <-s.leaseSet.kick:
+			s.syncLeases(time.Now())
+			continue
+		case <-ticker.C:
+		case <-s.schedulerStop:
+			return
+		}
+
+		ctx := context.Background()
+		now := time.Now()
+		s.syncLeases(now)
+
+		l := s.leaseSet
+		l.mu.Lock()
+		fingerprints := slices.Collect(maps.Keys(l.held))
+		l.mu.Unlock()
+		if len(fingerprints) > 0 {
+			if err := s.leases.Renew(ctx, s.cfg.ReplicaID, fingerprints, now.Add(s.cfg.LeaseTTL)); err != nil {
+				s.logger.Error("failed to renew box connection leases", zap.Error(err))
+			}
+			if s.cfg.Abuse.Interval > 0 {
+				noticesSince = s.deliverAbuseNotices(fingerprints, noticesSince, now)
+			}
+		}
+
+		if _, err := s.leases.DeleteExpired(ctx, now); err != nil {
+			s.logger.Error("failed to delete expired box connection leases", zap.Error(err))
+		}
+	}
+}
+
+// syncLeases writes the leases taken and released since the last sync.
+func (s *Svc) syncLeases(now time.Time) {
+	l := s.leaseSet
+	l.mu.Lock()
+	pending := l.pending
+	l.pending = make(map[string]bool)
+	l.mu.Unlock()
+
+	ctx := context.Background()
+	for fingerprint, held := range pending {
+		var err error
+		if held {
+			err = s.leases.Renew(ctx, s.cfg.ReplicaID, []string{fingerprint}, now.Add(s.cfg.LeaseTTL))
+		} else {
+			err = s.leases.Release(ctx, s.cfg.ReplicaID, fingerprint)
+		}
+		if err != nil {
+			s.logger.Error("failed to update box connection lease",
+				zap.String("fingerprint", fingerprint),
+				zap.Bool("held", held),
+				zap.Error(err))
+		}
+	}
+}
+
+// servedElsewhere reports whether another replica holds a live lease on
+// the box. When the leases cannot be read the box is assumed served, so
+// it is left alone until the next pass.
+func (s *Svc) servedElsewhere(fingerprint string, now time.Time) bool {
+	count, err := s.leases.CountOthers(context.Background(), s.cfg.ReplicaID, fingerprint, now)
+	if err != nil {
+		s.logger.Error("failed to count box connection leases",
+			zap.String("fingerprint", fingerprint),
+			zap.Error(err))
+		return true
+	}
+	return count > 0
+}
+
+// checkServedElsewhere refuses to replace or remove a box a terminal on
+// another replica is attached to, as only that replica can end it.
+func (s *Svc) checkServedElsewhere(fingerprint string) error {
+	if s.servedElsewhere(fingerprint, time.Now()) {
+		return domain.NewConflictError("a terminal on another server is attached to this box; close it and try again")
+	}
+	return nil
+}
+
+// inUse reports whether a terminal on any replica is attached to the box.
+func (s *Svc) inUse(fingerprint string, now time.Time) bool {
+	return s.attached(fingerprint) || s.servedElsewhere(fingerprint, now)
+}
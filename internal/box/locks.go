@@ -0,0 +1,48 @@
+package box
+
+import "sync"
+
+// boxLocks serializes the container operations on each box: a connect
+// starting it, a lifecycle job pausing, hibernating or removing it, and
+// requests replacing its container. The scheduler only decides what
+// should happen; whoever carries it out holds the box's lock.
+type boxLocks struct {
+	mu    sync.Mutex
+	locks map[string]*boxLock
+}
+
+type boxLock struct {
+	mu sync.Mutex
+	// refs counts the holders and waiters, so unused locks are dropped.
+	refs int
+}
+
+func newBoxLocks() *boxLocks {
+	return &boxLocks{locks: make(map[string]*boxLock)}
+}
+
+// lockBox takes the lock of the owner's box and returns the func releasing
+// it.
+func (s *Svc) lockBox(fingerprint string) func() {
+	l := s.boxLocks
+	l.mu.Lock()
+	bl, ok := l.locks[fingerprint]
+	if !ok {
+		bl = &boxLock{}
+		l.locks[fingerprint] = bl
+	}
+	bl.refs++
+	l.mu.Unlock()
+
+	bl.mu.Lock()
+	return func() {
+		bl.mu.Unlock()
+
+		l.mu.Lock()
+		bl.refs--
+		if bl.refs == 0 {
+			delete(l.locks, fingerprint)
+		}
+		l.mu.Unlock()
+	}
+}
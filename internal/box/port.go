@@ -2,26 +2,114 @@ package box
 
 import (
 	"context"
+	"io"
 	"time"
 
 	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/image"
 	"github.com/faiyaz032/gobox/internal/domain"
+	"github.com/google/uuid"
 )
 
 type Repo interface {
 	Create(context.Context, domain.Box) (*domain.Box, error)
 	GetByFingerprint(context.Context, string) (*domain.Box, error)
+	GetByID(context.Context, uuid.UUID) (*domain.Box, error)
 	GetByContainerID(context.Context, string) (*domain.Box, error)
 	GetExpiredBoxes(context.Context, time.Time) ([]domain.Box, error)
+	GetBoxesCreatedBefore(context.Context, time.Time) ([]domain.Box, error)
 	Touch(context.Context, string) (*domain.Box, error)
+	UpdateContainer(context.Context, string, string, string, domain.BoxStatus) (*domain.Box, error)
+	UpdateSpec(context.Context, string, domain.BoxSpec) (*domain.Box, error)
+	ReplaceContainer(context.Context, domain.Box) (*domain.Box, error)
+	UpdateProvisionStatus(context.Context, string, domain.ProvisionStatus) error
+	ClaimProvision(context.Context, string) (bool, error)
+	SetEnvStale(context.Context, string, bool) error
+	ListByStatus(context.Context, domain.BoxStatus) ([]domain.Box, error)
+	CountByStatus(context.Context, domain.BoxStatus) (int64, error)
 	UpdateStatus(context.Context, string, string) (*domain.Box, error)
 	Delete(context.Context, string) error
+	DeleteIfUnchanged(context.Context, domain.Box) (bool, error)
+}
+
+type SnapshotRepo interface {
+	Create(context.Context, domain.Snapshot) (*domain.Snapshot, error)
+	Get(context.Context, string, uuid.UUID) (*domain.Snapshot, error)
+	ListByFingerprint(context.Context, string) ([]domain.Snapshot, error)
+	Usage(context.Context, string) (*domain.SnapshotUsage, error)
+	Delete(context.Context, uuid.UUID) error
+}
+
+type DotfilesRepo interface {
+	Upsert(context.Context, domain.Dotfiles) (*domain.Dotfiles, error)
+	Get(context.Context, string) (*domain.Dotfiles, error)
+	Delete(context.Context, string) error
+}
+
+type EnvVarRepo interface {
+	Upsert(context.Context, domain.EnvVar) (*domain.EnvVar, error)
+	List(context.Context, string) ([]domain.EnvVar, error)
+	Delete(context.Context, string, string) error
+}
+
+type SidecarRepo interface {
+	Create(context.Context, domain.Sidecar) (*domain.Sidecar, error)
+	ListByFingerprint(context.Context, string) ([]domain.Sidecar, error)
+	Delete(context.Context, uuid.UUID) error
+}
+
+type AbuseRepo interface {
+	Create(context.Context, domain.AbuseEvent) (*domain.AbuseEvent, error)
+	CountSince(context.Context, string, time.Time) (int64, error)
+	LatestBan(context.Context, string) (*domain.AbuseEvent, error)
+	ListSince(context.Context, []string, time.Time) ([]domain.AbuseEvent, error)
+}
+
+// LeaseRepo stores which replicas have terminals open to which boxes.
+type LeaseRepo interface {
+	Renew(ctx context.Context, replicaID string, fingerprintIDs []string, expiresAt time.Time) error
+	Release(ctx context.Context, replicaID, fingerprintID string) error
+	ReleaseAll(ctx context.Context, replicaID string) error
+	CountOthers(ctx context.Context, replicaID, fingerprintID string, now time.Time) (int64, error)
+	DeleteExpired(ctx context.Context, now time.Time) (int64, error)
+}
+
+// Locker runs jobs that must not run on several replicas at once.
+type Locker interface {
+	TryWithLock(ctx context.Context, key domain.LockKey, fn func()) (bool, error)
 }
 
 type DockerSvc interface {
-	CreateContainer(ctx context.Context) (string, error)
+	CreateContainer(ctx context.Context, opts domain.ContainerOptions) (string, error)
 	AttachContainer(ctx context.Context, containerID string) (types.HijackedResponse, error)
 	StartIfNotRunning(ctx context.Context, containerID string) error
+	ContainerRuntime(ctx context.Context, containerID string) (string, error)
+	CPUUsage(ctx context.Context, containerID string) (float64, error)
+	ContainerProcesses(ctx context.Context, containerID string) ([]string, error)
+	CountRunning(ctx context.Context, label string) (int, error)
+	OutboundConnections(ctx context.Context, containerID string) (int, error)
+	ThrottleContainer(ctx context.Context, containerID string, nanoCPUs int64) error
+	Exec(ctx context.Context, containerID, user string, cmd, env []string, w io.Writer) (int, error)
+	PauseContainer(ctx context.Context, containerID string) error
 	StopContainer(ctx context.Context, containerID string) error
 	RemoveContainer(ctx context.Context, containerID string) error
+	CommitContainer(ctx context.Context, containerID, ref string, labels map[string]string) (string, error)
+	FlattenContainer(ctx context.Context, containerID, ref string, labels map[string]string) (string, error)
+	ImageLayers(ctx context.Context, ref string) (int, error)
+	RemoveImage(ctx context.Context, ref string) error
+	ImageSize(ctx context.Context, ref string) (int64, error)
+	ListImages(ctx context.Context, label string) ([]image.Summary, error)
+	PruneImages(ctx context.Context, label string) (uint64, error)
+	ExportArchive(ctx context.Context, containerID string, manifest domain.ArchiveManifest, w io.Writer, maxBytes int64) error
+	ImportArchive(ctx context.Context, r io.Reader, ref string, labels map[string]string, maxBytes int64) (*domain.ArchiveManifest, error)
+	CopyArchive(ctx context.Context, containerID, dir string, r io.Reader) error
+	CopyFiles(ctx context.Context, containerID string, files []domain.SpecFile) error
+	CopyDirectory(ctx context.Context, srcContainerID, dstContainerID, dir string) error
+	EnsurePrivateNetwork(ctx context.Context, name string, labels map[string]string) error
+	EnsureEgressNetwork(ctx context.Context, name string, labels map[string]string) error
+	RemoveNetwork(ctx context.Context, name string) error
+	CreateSidecar(ctx context.Context, opts domain.SidecarOptions) (string, error)
+	EnsureVolume(ctx context.Context, name string, labels map[string]string) error
+	VolumeSize(ctx context.Context, name string) (int64, error)
+	RemoveVolume(ctx context.Context, name string) error
 }
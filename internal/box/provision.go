@@ -0,0 +1,132 @@
+package box
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/faiyaz032/gobox/internal/domain"
+	"github.com/gorilla/websocket"
+	"go.uber.org/zap"
+)
+
+// provisionScript is a provisioning script and the user it runs as; an
+// empty user is the image's default one.
+type provisionScript struct {
+	user   string
+	script string
+}
+
+// provisionScripts lists the scripts run on a box's first start: the
+// template's as root, then the spec's as the box user.
+func (s *Svc) provisionScripts(spec domain.BoxSpec) []provisionScript {
+	spec = s.resolveSpec(spec)
+
+	var scripts []provisionScript
+	for _, script := range s.cfg.Templates[spec.Template].Provision {
+		scripts = append(scripts, provisionScript{user: "root", script: script})
+	}
+	for _, script := range spec.Provision {
+		scripts = append(scripts, provisionScript{script: script})
+	}
+	return scripts
+}
+
+// initialProvisionStatus is the provisioning status of a newly created box.
+func (s *Svc) initialProvisionStatus(spec domain.BoxSpec) domain.ProvisionStatus {
+	if len(s.provisionScripts(spec)) > 0 {
+		return domain.ProvisionPending
+	}
+	return domain.ProvisionReady
+}
+
+// provision runs the scripts of a pending box in its started container,
+// streaming their output to the terminal. A script that keeps failing
+// marks the box failed; the user still gets a shell to investigate.
+func (s *Svc) provision(ctx context.Context, conn *websocket.Conn, b *domain.Box) {
+	claimed, err := s.repo.ClaimProvision(ctx, b.FingerprintID)
+	if err != nil {
+		s.logger.Error("Failed to claim box provisioning",
+			zap.String("fingerprint", b.FingerprintID),
+			zap.Error(err))
+		return
+	}
+	out := terminalWriter{conn: conn}
+	if !claimed {
+		out.status("Your box is still being set up in another terminal")
+		return
+	}
+
+	out.status("Setting up your box...")
+	status := domain.ProvisionReady
+	scripts := s.provisionScripts(b.Spec)
+	for i, script := range scripts {
+		if !s.runProvisionScript(ctx, b, script, out) {
+			out.status(fmt.Sprintf("Setup step %d of %d failed; your box may be incomplete", i+1, len(scripts)))
+			status = domain.ProvisionFailed
+			break
+		}
+	}
+	if status == domain.ProvisionReady {
+		out.status("Your box is ready")
+	}
+
+	// recorded even if the request was cancelled mid-way
+	if err := s.repo.UpdateProvisionStatus(context.Background(), b.FingerprintID, status); err != nil {
+		s.logger.Error("Failed to record box provisioning status",
+			zap.String("fingerprint", b.FingerprintID),
+			zap.Error(err))
+	}
+	b.ProvisionStatus = status
+
+	s.logger.Info("Provisioned box",
+		zap.String("fingerprint", b.FingerprintID),
+		zap.String("status", string(status)))
+}
+
+// runProvisionScript runs a script up to ProvisionAttempts times and
+// reports whether it eventually succeeded.
+func (s *Svc) runProvisionScript(ctx context.Context, b *domain.Box, script provisionScript, out terminalWriter) bool {
+	attempts := max(s.cfg.ProvisionAttempts, 1)
+	for attempt := 1; attempt <= attempts; attempt++ {
+		code, err := s.execScript(ctx, b, script, out)
+		if err == nil && code == 0 {
+			return true
+		}
+
+		s.logger.Warn("Provisioning script failed",
+			zap.String("fingerprint", b.FingerprintID),
+			zap.Int("attempt", attempt),
+			zap.Int("exit_code", code),
+			zap.Error(err))
+		if attempt < attempts {
+			out.status(fmt.Sprintf("Setup step failed, retrying (%d/%d)", attempt+1, attempts))
+		}
+	}
+	return false
+}
+
+func (s *Svc) execScript(ctx context.Context, b *domain.Box, script provisionScript, out terminalWriter) (int, error) {
+	if s.cfg.ProvisionTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.cfg.ProvisionTimeout)
+		defer cancel()
+	}
+	return s.dockerSvc.Exec(ctx, b.ContainerID, script.user, []string{"/bin/sh", "-c", script.script}, s.execEnv(ctx, b.FingerprintID), out)
+}
+
+// terminalWriter streams provisioning output to a terminal that has no
+// session yet. Write errors are dropped so a closed tab does not abort
+// provisioning.
+type terminalWriter struct {
+	conn *websocket.Conn
+}
+
+func (w terminalWriter) Write(p []byte) (int, error) {
+	_ = w.conn.WriteMessage(websocket.BinaryMessage, p)
+	return len(p), nil
+}
+
+// status prints a GoBox progress message.
+func (w terminalWriter) status(msg string) {
+	_ = w.conn.WriteMessage(websocket.TextMessage, []byte("\r\n\x1b[1;36m⚙ GoBox: "+msg+"\x1b[0m\r\n"))
+}
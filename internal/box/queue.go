@@ -0,0 +1,302 @@
+package box
+
+import (
+	"context"
+	"expvar"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/faiyaz032/gobox/internal/domain"
+	"github.com/gorilla/websocket"
+	"go.uber.org/zap"
+)
+
+const (
+	// capacityRetryAfter is the retry hint given when the waiting room is
+	// full or a connect waited too long.
+	capacityRetryAfter = 30 * time.Second
+	// queueInterval is how often the waiting room rechecks capacity and
+	// waiting connects are told their position.
+	queueInterval = 5 * time.Second
+)
+
+// Waiting room metrics, served by expvar.
+var (
+	queueDepth       = expvar.NewInt("gobox_queue_depth")
+	queueAdmitted    = expvar.NewInt("gobox_queue_admitted_total")
+	queueAbandoned   = expvar.NewInt("gobox_queue_abandoned_total")
+	queueWaitSeconds = expvar.NewFloat("gobox_queue_wait_seconds_total")
+)
+
+// ticket is a connect waiting for a box to stop.
+type ticket struct {
+	fingerprint string
+	priority    int
+	enqueuedAt  time.Time
+	admitted    chan struct{}
+}
+
+// waitingRoom holds connects that would start a box while MaxRunning
+// containers run. Tickets are ordered by tier priority, then arrival.
+type waitingRoom struct {
+	// admitMu serializes the decisions to start containers, which count
+	// the running ones through Docker. It is taken before mu, which only
+	// guards the fields below and is never held across that call.
+	admitMu sync.Mutex
+	mu      sync.Mutex
+	waiting []*ticket
+	// starting counts admitted connects whose box is not yet recorded as
+	// running.
+	starting int
+	kick     chan struct{}
+}
+
+func newWaitingRoom() *waitingRoom {
+	return &waitingRoom{kick: make(chan struct{}, 1)}
+}
+
+// admit waits until the box may start. It returns a release func to call
+// once the box is recorded as running or failed to start. Position updates
+// are written to conn while the connect waits.
+func (s *Svc) admit(ctx context.Context, conn *websocket.Conn, fingerprint, tier string) (func(), error) {
+	if s.cfg.MaxRunning <= 0 {
+		return func() {}, nil
+	}
+	q := s.queue
+	t := &ticket{
+		fingerprint: fingerprint,
+		priority:    s.cfg.Policy(tier).QueuePriority,
+		enqueuedAt:  time.Now(),
+		admitted:    make(chan struct{}),
+	}
+	started, err := s.enter(ctx, t)
+	if err != nil {
+		return nil, err
+	}
+	if started {
+		return s.releaseSlots(1), nil
+	}
+
+	s.logger.Info("Connect queued for capacity",
+		zap.String("fingerprint", fingerprint),
+		zap.Int("priority", t.priority))
+
+	timeout := time.NewTimer(s.cfg.QueueTimeout)
+	defer timeout.Stop()
+	ticker := time.NewTicker(queueInterval)
+	defer ticker.Stop()
+
+	lastPosition := 0
+	for {
+		if position := q.position(t); position > 0 && position != lastPosition {
+			lastPosition = position
+			msg := fmt.Sprintf("all boxes are busy; you are number %d in the queue", position)
+			// a failed write means the client left
+			if err := conn.WriteMessage(websocket.TextMessage, notice(msg)); err != nil {
+				return nil, s.abandon(t, domain.NewInternalError("websocket write error", err))
+			}
+		}
+
+		select {
+		case <-t.admitted:
+			_ = conn.WriteMessage(websocket.TextMessage, notice("your box is starting"))
+			return s.releaseSlots(1), nil
+		case <-ticker.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(time.Second)); err != nil {
+				return nil, s.abandon(t, domain.NewInternalError("websocket write error", err))
+			}
+		case <-timeout.C:
+			return nil, s.abandon(t, domain.NewRateLimitError("waited too long for a free box; try again shortly", capacityRetryAfter))
+		case <-ctx.Done():
+			return nil, s.abandon(t, domain.NewInternalError("connect canceled while queued", ctx.Err()))
+		case <-s.stopping:
+			return nil, s.abandon(t, errRestarting())
+		}
+	}
+}
+
+// enter takes a slot for t when one is free and nobody is queued, and
+// reports whether it did. Otherwise t is queued, unless the queue is full.
+func (s *Svc) enter(ctx context.Context, t *ticket) (bool, error) {
+	q := s.queue
+	q.admitMu.Lock()
+	defer q.admitMu.Unlock()
+
+	q.mu.Lock()
+	queued := len(q.waiting)
+	q.mu.Unlock()
+	if queued == 0 {
+		free, err := s.freeSlots(ctx)
+		if err != nil {
+			return false, err
+		}
+		if free > 0 {
+			q.mu.Lock()
+			q.starting++
+			q.mu.Unlock()
+			return true, nil
+		}
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.waiting) >= s.cfg.QueueMax {
+		return false, domain.NewRateLimitError("the server is at capacity and its queue is full; try again shortly", capacityRetryAfter)
+	}
+	// behind every ticket of the same or a higher priority
+	i := slices.IndexFunc(q.waiting, func(o *ticket) bool { return o.priority < t.priority })
+	if i < 0 {
+		i = len(q.waiting)
+	}
+	q.waiting = slices.Insert(q.waiting, i, t)
+	queueDepth.Set(int64(len(q.waiting)))
+	return false, nil
+}
+
+// abandon takes a ticket out of the queue and returns err. A ticket
+// admitted meanwhile gives its slot back.
+func (s *Svc) abandon(t *ticket, err error) error {
+	q := s.queue
+	q.mu.Lock()
+	if i := slices.Index(q.waiting, t); i >= 0 {
+		q.waiting = slices.Delete(q.waiting, i, i+1)
+		queueDepth.Set(int64(len(q.waiting)))
+		queueAbandoned.Add(1)
+		q.mu.Unlock()
+		return err
+	}
+	q.mu.Unlock()
+
+	// admitted between the select and the lock
+	s.releaseSlots(1)()
+	return err
+}
+
+// position is the ticket's 1-based place in the queue, or zero once it
+// left.
+func (q *waitingRoom) position(t *ticket) int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return slices.Index(q.waiting, t) + 1
+}
+
+// releaseSlots returns a func ending an admitted start of n containers,
+// letting the next ticket in once they are counted as running.
+func (s *Svc) releaseSlots(n int) func() {
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			s.queue.mu.Lock()
+			s.queue.starting -= n
+			s.queue.mu.Unlock()
+			s.kickQueue()
+		})
+	}
+}
+
+// Reserve takes slots for containers about to start outside a box
+// connect, e.g. the nodes of a lab. Unlike a connect it does not wait: it
+// fails while connects are queued or too few slots are free. The returned
+// func gives the slots back once the containers run or failed to start.
+func (s *Svc) Reserve(ctx context.Context, containers int) (func(), error) {
+	if s.cfg.MaxRunning <= 0 {
+		return func() {}, nil
+	}
+	q := s.queue
+
+	q.admitMu.Lock()
+	defer q.admitMu.Unlock()
+
+	free, err := s.freeSlots(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.waiting) > 0 || free < containers {
+		return nil, domain.NewRateLimitError("the server is at capacity; try again shortly", capacityRetryAfter)
+	}
+	q.starting += containers
+	return s.releaseSlots(containers), nil
+}
+
+// kickQueue asks the waiting room to recheck capacity, e.g. after a box
+// stopped.
+func (s *Svc) kickQueue() {
+	select {
+	case s.queue.kick <- struct{}{}:
+	default:
+	}
+}
+
+// freeSlots is how many more containers may start. Every container GoBox
+// runs counts: boxes, their sidecars and lab nodes. The caller holds
+// admitMu, so no start is admitted during the count; one released during
+// it was either running or still starting when starting was read, so it is
+// never missed.
+func (s *Svc) freeSlots(ctx context.Context) (int, error) {
+	q := s.queue
+	q.mu.Lock()
+	starting := q.starting
+	q.mu.Unlock()
+
+	running, err := s.dockerSvc.CountRunning(ctx, labelOwner)
+	if err != nil {
+		return 0, err
+	}
+	return s.cfg.MaxRunning - running - starting, nil
+}
+
+// runQueue admits waiting connects as capacity frees up.
+func (s *Svc) runQueue() {
+	ticker := time.NewTicker(queueInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-s.queue.kick:
+		case <-s.stopping:
+			return
+		}
+		s.admitWaiting()
+	}
+}
+
+func (s *Svc) admitWaiting() {
+	q := s.queue
+	q.admitMu.Lock()
+	defer q.admitMu.Unlock()
+
+	q.mu.Lock()
+	queued := len(q.waiting)
+	q.mu.Unlock()
+	if queued == 0 {
+		return
+	}
+	free, err := s.freeSlots(context.Background())
+	if err != nil {
+		s.logger.Error("failed to count running containers", zap.Error(err))
+		return
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for ; free > 0 && len(q.waiting) > 0; free-- {
+		t := q.waiting[0]
+		q.waiting = q.waiting[1:]
+		q.starting++
+		close(t.admitted)
+
+		queueAdmitted.Add(1)
+		queueWaitSeconds.Add(time.Since(t.enqueuedAt).Seconds())
+		s.logger.Info("Admitted queued connect",
+			zap.String("fingerprint", t.fingerprint),
+			zap.Duration("waited", time.Since(t.enqueuedAt)))
+	}
+	queueDepth.Set(int64(len(q.waiting)))
+}
@@ -0,0 +1,584 @@
+package box
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/faiyaz032/gobox/internal/config"
+	"github.com/faiyaz032/gobox/internal/domain"
+	"go.uber.org/zap"
+)
+
+type connEventKind int
+
+const (
+	connOpened connEventKind = iota
+	connAttached
+	connClosed
+	// connExclusive runs fn on the scheduler goroutine so it can read or
+	// update the state of boxes without racing connections.
+	connExclusive
+)
+
+type connEvent struct {
+	kind        connEventKind
+	fingerprint string
+	session     *session
+	fn          func(map[string]*boxState)
+	responseCh  chan struct{}
+}
+
+// boxState is the scheduler's view of a box with open or recently closed
+// connections.
+type boxState struct {
+	tier           string
+	lifecycle      domain.LifecycleSpec
+	conns          int
+	sessions       map[*session]struct{}
+	disconnectedAt time.Time
+	// busy means a lifecycle job for the box is running; the box is not
+	// evaluated again until the job reports back.
+	busy bool
+}
+
+// lifecycleAction is what the scheduler does when a deadline passes.
+type lifecycleAction int
+
+const (
+	actionEndSession lifecycleAction = iota
+	actionRemoveBox
+)
+
+type deadline struct {
+	at     time.Time
+	action lifecycleAction
+	reason string
+}
+
+// runScheduler is the single owner of box lifecycle decisions. It tracks
+// connections, ends sessions that break the tier's policy and decides when
+// a box is frozen or removed. It never waits on Docker or the database:
+// connects and requests act on boxes themselves, and the lifecycle jobs it
+// decides on run apart from it and report back.
+func (s *Svc) runScheduler(states map[string]*boxState) {
+	ticker := time.NewTicker(s.cfg.SchedulerInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case event := <-s.connEventCh:
+			s.handleConnEvent(states, event)
+			close(event.responseCh)
+
+		case <-ticker.C:
+			s.evaluate(states, time.Now())
+
+		case <-s.schedulerStop:
+			return
+		}
+	}
+}
+
+// runSweeper periodically stops boxes that stay frozen too long,
+// hibernates long-stopped boxes and deletes expired ones. Replicas take
+// turns: the one holding the sweep lock runs the jobs and the others skip
+// the round.
+func (s *Svc) runSweeper() {
+	ticker := time.NewTicker(s.cfg.SweepInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-s.stopping:
+			return
+		}
+
+		_, err := s.locker.TryWithLock(context.Background(), domain.LockBoxSweep, func() {
+			now := time.Now()
+			s.stopFrozen(now)
+			s.hibernateStopped(now)
+			s.sweepExpired(now)
+			s.collectImages()
+		})
+		if err != nil {
+			s.logger.Error("failed to take the sweep lock", zap.Error(err))
+		}
+	}
+}
+
+func (s *Svc) handleConnEvent(states map[string]*boxState, event connEvent) {
+	state, exists := states[event.fingerprint]
+
+	switch event.kind {
+	case connExclusive:
+		event.fn(states)
+
+	case connOpened:
+		if !exists {
+			state = &boxState{
+				tier:     s.cfg.DefaultTier,
+				sessions: make(map[*session]struct{}),
+			}
+			states[event.fingerprint] = state
+		}
+		state.conns++
+		state.disconnectedAt = time.Time{}
+		if state.conns == 1 {
+			s.holdLease(event.fingerprint)
+		}
+
+		s.logger.Info("Connection established",
+			zap.String("fingerprint", event.fingerprint),
+			zap.Int("active_connections", state.conns))
+
+	case connAttached:
+		if !exists {
+			return
+		}
+		state.tier = event.session.box.Tier
+		state.lifecycle = event.session.box.Spec.Lifecycle
+		state.sessions[event.session] = struct{}{}
+		// a connect that finished starting its box after Shutdown ended
+		// the other sessions
+		if s.isStopping() {
+			event.session.restart()
+		}
+
+	case connClosed:
+		if !exists {
+			return
+		}
+		if event.session != nil {
+			delete(state.sessions, event.session)
+		}
+		state.conns--
+
+		if state.conns <= 0 {
+			state.conns = 0
+			state.disconnectedAt = time.Now()
+			s.dropLease(event.fingerprint)
+			s.logger.Info("Last connection closed, waiting for grace period",
+				zap.String("fingerprint", event.fingerprint),
+				zap.Duration("grace_period", s.policy(state.tier, state.lifecycle).GracePeriod))
+		} else {
+			s.logger.Info("Connection closed",
+				zap.String("fingerprint", event.fingerprint),
+				zap.Int("remaining_connections", state.conns))
+		}
+	}
+}
+
+// evaluate applies the lifecycle policy to every tracked box.
+func (s *Svc) evaluate(states map[string]*boxState, now time.Time) {
+	for fingerprint, state := range states {
+		if state.busy {
+			continue
+		}
+		policy := s.policy(state.tier, state.lifecycle)
+
+		if state.conns > 0 {
+			s.evaluateSessions(fingerprint, state, policy, now)
+			continue
+		}
+
+		if now.Sub(state.disconnectedAt) < policy.GracePeriod {
+			continue
+		}
+		state.busy = true
+		s.background.Go(func() { s.pauseIdle(fingerprint) })
+	}
+}
+
+func (s *Svc) evaluateSessions(fingerprint string, state *boxState, policy config.LifecyclePolicy, now time.Time) {
+	for sess := range state.sessions {
+		if sess.isEnded() {
+			continue
+		}
+
+		d, ok := nextDeadline(sess, policy)
+		if !ok {
+			continue
+		}
+
+		if !now.Before(d.at) {
+			if d.action == actionRemoveBox {
+				for other := range state.sessions {
+					other.end(d.reason)
+				}
+				state.busy = true
+				b := sess.box
+				s.background.Go(func() { s.expireBox(b) })
+				return
+			}
+			s.logger.Info("Ending session",
+				zap.String("fingerprint", fingerprint),
+				zap.String("reason", d.reason))
+			sess.end(d.reason)
+			continue
+		}
+
+		if policy.WarnBefore > 0 && now.After(d.at.Add(-policy.WarnBefore)) && !sess.warned.Equal(d.at) {
+			sess.warned = d.at
+			verb := "stopped"
+			if d.action == actionRemoveBox {
+				verb = "deleted"
+			}
+			sess.notify("this box will be " + verb + " in " + formatDuration(d.at.Sub(now)) + " (" + d.reason + ")")
+		}
+	}
+}
+
+// nextDeadline returns the earliest rule the session will break.
+func nextDeadline(sess *session, policy config.LifecyclePolicy) (deadline, bool) {
+	var candidates []deadline
+
+	if policy.IdleTimeout > 0 {
+		candidates = append(candidates, deadline{
+			at:     sess.activity.lastInputAt().Add(policy.IdleTimeout),
+			action: actionEndSession,
+			reason: "session closed after " + formatDuration(policy.IdleTimeout) + " without input",
+		})
+	}
+	if policy.MaxSession > 0 {
+		candidates = append(candidates, deadline{
+			at:     sess.startedAt.Add(policy.MaxSession),
+			action: actionEndSession,
+			reason: "session reached the " + formatDuration(policy.MaxSession) + " limit",
+		})
+	}
+	if policy.HardExpiry > 0 && !sess.box.CreatedAt.IsZero() {
+		candidates = append(candidates, deadline{
+			at:     sess.box.CreatedAt.Add(policy.HardExpiry),
+			action: actionRemoveBox,
+			reason: "box reached its " + formatDuration(policy.HardExpiry) + " lifetime",
+		})
+	}
+
+	if len(candidates) == 0 {
+		return deadline{}, false
+	}
+	earliest := candidates[0]
+	for _, c := range candidates[1:] {
+		if c.at.Before(earliest.at) {
+			earliest = c
+		}
+	}
+	return earliest, true
+}
+
+// pauseIdle freezes a box whose grace period passed, unless a terminal
+// reconnected meanwhile or another replica serves it, in which case that
+// replica pauses it once its own terminals close.
+func (s *Svc) pauseIdle(fingerprint string) {
+	unlock := s.lockBox(fingerprint)
+	defer unlock()
+	defer s.finishJob(fingerprint)
+
+	// a connect opened since the job started waits for the lock
+	if s.inUse(fingerprint, time.Now()) {
+		return
+	}
+	b, err := s.findBox(context.Background(), fingerprint)
+	if err != nil {
+		s.logger.Error("failed to get idle box",
+			zap.String("fingerprint", fingerprint),
+			zap.Error(err))
+		return
+	}
+	if b == nil || b.Status != domain.StatusRunning {
+		return
+	}
+	s.pauseBox(fingerprint, b.ContainerID)
+}
+
+// expireBox removes a box that reached its hard expiry while attached.
+// Its sessions were ended by the scheduler.
+func (s *Svc) expireBox(expired domain.Box) {
+	unlock := s.lockBox(expired.FingerprintID)
+	defer unlock()
+	defer s.finishJob(expired.FingerprintID)
+
+	b, err := s.findBox(context.Background(), expired.FingerprintID)
+	if err != nil {
+		s.logger.Error("failed to get expired box",
+			zap.String("fingerprint", expired.FingerprintID),
+			zap.Error(err))
+		return
+	}
+	// already removed, or replaced by a box with its own lifetime
+	if b == nil || b.ID != expired.ID {
+		return
+	}
+	s.removeBox(*b)
+}
+
+// finishJob tells the scheduler a lifecycle job for the box is done. The
+// box is forgotten unless a terminal attached meanwhile.
+func (s *Svc) finishJob(fingerprint string) {
+	s.inScheduler(func(states map[string]*boxState) {
+		state, ok := states[fingerprint]
+		if !ok {
+			return
+		}
+		state.busy = false
+		if state.conns == 0 {
+			delete(states, fingerprint)
+		}
+	})
+}
+
+// claim takes the lock of a box listed by the sweeper and reads it again.
+// It returns nil, releasing the lock, when the box changed since it was
+// listed or a terminal on any replica is attached to it.
+func (s *Svc) claim(listed domain.Box, now time.Time) (*domain.Box, func()) {
+	unlock := s.lockBox(listed.FingerprintID)
+
+	b, err := s.findBox(context.Background(), listed.FingerprintID)
+	if err != nil {
+		s.logger.Error("failed to get box",
+			zap.String("fingerprint", listed.FingerprintID),
+			zap.Error(err))
+	}
+	if b == nil || b.ID != listed.ID || b.Status != listed.Status || b.ContainerID != listed.ContainerID ||
+		s.inUse(listed.FingerprintID, now) {
+		unlock()
+		return nil, nil
+	}
+	return b, unlock
+}
+
+// pauseBox freezes a box once its grace period has passed. Processes keep
+// their memory so a quick reconnect resumes exactly where the user left.
+// A container that cannot be frozen (e.g. its shell already exited) is
+// stopped instead so its status stays accurate.
+func (s *Svc) pauseBox(fingerprint, containerID string) {
+	if err := s.transition(fingerprint, containerID, domain.StatusPaused, s.dockerSvc.PauseContainer); err != nil {
+		_ = s.stopBox(fingerprint, containerID)
+	}
+}
+
+// stopBox terminates the processes of a box that stayed frozen too long.
+func (s *Svc) stopBox(fingerprint, containerID string) error {
+	return s.transition(fingerprint, containerID, domain.StatusStopped, s.dockerSvc.StopContainer)
+}
+
+func (s *Svc) transition(fingerprint, containerID string, status domain.BoxStatus, op func(context.Context, string) error) error {
+	s.logger.Info("Changing box state",
+		zap.String("container_id", containerID),
+		zap.String("status", string(status)))
+
+	if err := op(context.Background(), containerID); err != nil {
+		s.logger.Error("Error changing container state",
+			zap.String("container_id", containerID),
+			zap.String("status", string(status)),
+			zap.Error(err))
+		return err
+	}
+	s.sidecarsDo(context.Background(), fingerprint, op)
+	s.kickQueue()
+
+	_, err := s.repo.UpdateStatus(context.Background(), fingerprint, string(status))
+	if err != nil {
+		s.logger.Error("Error updating box status",
+			zap.String("fingerprint", fingerprint),
+			zap.String("status", string(status)),
+			zap.Error(err))
+	} else {
+		s.logger.Info("Box status updated",
+			zap.String("fingerprint", fingerprint),
+			zap.String("status", string(status)))
+	}
+
+	return nil
+}
+
+// stopFrozen escalates boxes that have been paused for longer than their
+// tier allows to a real stop, releasing their memory.
+func (s *Svc) stopFrozen(now time.Time) {
+	boxes, err := s.repo.ListByStatus(context.Background(), domain.StatusPaused)
+	if err != nil {
+		s.logger.Error("failed to list paused boxes", zap.Error(err))
+		return
+	}
+
+	for _, b := range boxes {
+		policy := s.policy(b.Tier, b.Spec.Lifecycle)
+		if policy.StopAfter <= 0 || now.Sub(b.LastActive) < policy.GracePeriod+policy.StopAfter {
+			continue
+		}
+		claimed, unlock := s.claim(b, now)
+		if claimed == nil {
+			continue
+		}
+		_ = s.stopBox(claimed.FingerprintID, claimed.ContainerID)
+		unlock()
+	}
+}
+
+// sweepExpired deletes boxes that outlived their tier's expiry rules.
+// Attached boxes are left to evaluateSessions so their users get warned.
+func (s *Svc) sweepExpired(now time.Time) {
+	// the shortest rule of any tier bounds the queries; each box is then
+	// checked against its own tier
+	var minIdle, minAge time.Duration
+	for _, p := range s.cfg.Policies {
+		for _, d := range []time.Duration{p.ExpireAfter, p.HibernateRetention} {
+			if d > 0 && (minIdle == 0 || d < minIdle) {
+				minIdle = d
+			}
+		}
+		if p.HardExpiry > 0 && (minAge == 0 || p.HardExpiry < minAge) {
+			minAge = p.HardExpiry
+		}
+	}
+
+	candidates := make(map[string]domain.Box)
+	if minIdle > 0 {
+		boxes, err := s.repo.GetExpiredBoxes(context.Background(), now.Add(-minIdle))
+		if err != nil {
+			s.logger.Error("failed to get expired boxes", zap.Error(err))
+		}
+		for _, b := range boxes {
+			policy := s.policy(b.Tier, b.Spec.Lifecycle)
+			expireAfter := policy.ExpireAfter
+			if b.Status == domain.StatusHibernated {
+				expireAfter = policy.HibernateRetention
+			}
+			if expireAfter > 0 && b.LastActive.Before(now.Add(-expireAfter)) {
+				candidates[b.FingerprintID] = b
+			}
+		}
+	}
+	if minAge > 0 {
+		boxes, err := s.repo.GetBoxesCreatedBefore(context.Background(), now.Add(-minAge))
+		if err != nil {
+			s.logger.Error("failed to get boxes past hard expiry", zap.Error(err))
+		}
+		for _, b := range boxes {
+			policy := s.policy(b.Tier, b.Spec.Lifecycle)
+			if policy.HardExpiry > 0 && b.CreatedAt.Before(now.Add(-policy.HardExpiry)) {
+				candidates[b.FingerprintID] = b
+			}
+		}
+	}
+
+	for _, b := range candidates {
+		claimed, unlock := s.claim(b, now)
+		if claimed == nil {
+			continue
+		}
+		s.removeBox(*claimed)
+		unlock()
+	}
+}
+
+// removeBox deletes a box with its container, sidecars and hibernated
+// image. The caller holds the box lock.
+func (s *Svc) removeBox(b domain.Box) {
+	if b.ContainerID != "" {
+		err := s.dockerSvc.RemoveContainer(context.Background(), b.ContainerID)
+		if err != nil {
+			s.logger.Error("failed to remove container", zap.String("container_id", b.ContainerID), zap.Error(err))
+		}
+	}
+
+	s.removeSidecars(context.Background(), b.FingerprintID)
+
+	if b.Image == hibernatedRef(b) {
+		err := s.dockerSvc.RemoveImage(context.Background(), b.Image)
+		if err != nil {
+			s.logger.Error("failed to remove hibernated image", zap.String("image", b.Image), zap.Error(err))
+		}
+	}
+
+	err := s.repo.Delete(context.Background(), b.FingerprintID)
+	if err != nil {
+		s.logger.Error("failed to delete box from db", zap.String("fingerprint", b.FingerprintID), zap.Error(err))
+	}
+
+	s.logger.Info("removed box", zap.String("container_id", b.ContainerID), zap.String("fingerprint", b.FingerprintID))
+	s.kickQueue()
+}
+
+func (s *Svc) openConnection(fingerprint string) {
+	s.sendConnEvent(connEvent{kind: connOpened, fingerprint: fingerprint})
+}
+
+func (s *Svc) attachSession(sess *session) {
+	s.sendConnEvent(connEvent{kind: connAttached, fingerprint: sess.box.FingerprintID, session: sess})
+}
+
+// closeConnection releases a connection opened with openConnection. sess
+// is nil when the connection failed before a session was attached.
+func (s *Svc) closeConnection(fingerprint string, sess *session) {
+	s.sendConnEvent(connEvent{kind: connClosed, fingerprint: fingerprint, session: sess})
+}
+
+// inScheduler runs fn on the scheduler goroutine and waits for it. fn only
+// reads and updates the scheduler's state; Docker and database work is
+// left to the caller. It reports false, without running fn, once the
+// scheduler has stopped.
+func (s *Svc) inScheduler(fn func(states map[string]*boxState)) bool {
+	return s.sendConnEvent(connEvent{kind: connExclusive, fn: fn})
+}
+
+func (s *Svc) sendConnEvent(event connEvent) bool {
+	event.responseCh = make(chan struct{})
+	select {
+	case s.connEventCh <- event:
+	case <-s.schedulerStop:
+		return false
+	}
+	<-event.responseCh
+	return true
+}
+
+// attached reports whether a terminal on this replica is connected to the
+// owner's box. Once the scheduler has stopped every box counts as
+// attached, so nothing acts on it.
+func (s *Svc) attached(fingerprint string) bool {
+	attached := true
+	s.inScheduler(func(states map[string]*boxState) {
+		state, ok := states[fingerprint]
+		attached = ok && state.conns > 0
+	})
+	return attached
+}
+
+// hasSessions reports whether a terminal on this replica has a session
+// on the owner's box, as opposed to a connect still starting it.
+func (s *Svc) hasSessions(fingerprint string) bool {
+	has := true
+	s.inScheduler(func(states map[string]*boxState) {
+		state, ok := states[fingerprint]
+		has = ok && len(state.sessions) > 0
+	})
+	return has
+}
+
+// endSessions ends the terminals attached to the owner's box on this
+// replica, e.g. before its container is replaced.
+func (s *Svc) endSessions(fingerprint, reason string) {
+	s.inScheduler(func(states map[string]*boxState) {
+		if state, ok := states[fingerprint]; ok {
+			for sess := range state.sessions {
+				sess.end(reason)
+			}
+		}
+	})
+}
+
+// formatDuration renders a policy duration without trailing zero units,
+// e.g. "30m" instead of "30m0s".
+func formatDuration(d time.Duration) string {
+	str := d.Round(time.Second).String()
+	if strings.HasSuffix(str, "m0s") {
+		str = str[:len(str)-2]
+	}
+	if strings.HasSuffix(str, "h0m") {
+		str = str[:len(str)-2]
+	}
+	return str
+}
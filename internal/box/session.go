@@ -0,0 +1,143 @@
+package box
+
+import (
+	"sync"
+	"time"
+
+	"github.com/faiyaz032/gobox/internal/domain"
+	"github.com/gorilla/websocket"
+)
+
+// Limits keeping a slow client from holding anything up.
+const (
+	// noticeBuffer is how many notices may wait for a client; further
+	// ones are dropped.
+	noticeBuffer = 8
+	// writeTimeout bounds each write to a client.
+	writeTimeout = 10 * time.Second
+)
+
+// session is a single attached terminal. The scheduler uses it to warn the
+// user and to end the session when a lifecycle rule fires. Notices and the
+// end are written by the session's own goroutine, so the scheduler never
+// waits on the client.
+type session struct {
+	box       domain.Box
+	conn      *websocket.Conn
+	activity  *activityTracker
+	startedAt time.Time
+
+	// gorilla/websocket allows a single concurrent writer
+	writeMu sync.Mutex
+	notices chan string
+
+	ended     chan struct{}
+	endOnce   sync.Once
+	endReason string
+	endMsg    string
+	endCode   int
+
+	// detached is closed once Connect is done with the session.
+	detached   chan struct{}
+	detachOnce sync.Once
+
+	// warned is the deadline the user was last warned about
+	warned time.Time
+}
+
+func newSession(box domain.Box, conn *websocket.Conn, activity *activityTracker) *session {
+	s := &session{
+		box:       box,
+		conn:      conn,
+		activity:  activity,
+		startedAt: time.Now(),
+		notices:   make(chan string, noticeBuffer),
+		ended:     make(chan struct{}),
+		detached:  make(chan struct{}),
+	}
+	go s.writeNotices()
+	return s
+}
+
+// writeNotices writes queued notices until the session is ended, then
+// tells the user why and closes the connection, which unblocks the read
+// loop in Connect.
+func (s *session) writeNotices() {
+	for {
+		select {
+		case msg := <-s.notices:
+			_ = s.writeMessage(websocket.TextMessage, notice(msg))
+		case <-s.ended:
+			_ = s.writeMessage(websocket.TextMessage, notice(s.endMsg))
+			_ = s.conn.WriteControl(websocket.CloseMessage,
+				websocket.FormatCloseMessage(s.endCode, s.endReason),
+				time.Now().Add(time.Second))
+			_ = s.conn.Close()
+			return
+		case <-s.detached:
+			return
+		}
+	}
+}
+
+// write sends terminal output to the client.
+func (s *session) write(data []byte) error {
+	return s.writeMessage(websocket.BinaryMessage, data)
+}
+
+func (s *session) writeMessage(messageType int, data []byte) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	_ = s.conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+	return s.conn.WriteMessage(messageType, data)
+}
+
+// notify queues a GoBox message for the user's terminal. It never blocks;
+// a client too slow to take its notices misses some.
+func (s *session) notify(msg string) {
+	select {
+	case s.notices <- msg:
+	default:
+	}
+}
+
+// notice formats a GoBox message for the terminal. Text frames carry these
+// control messages; terminal output is sent as binary frames.
+func notice(msg string) []byte {
+	return []byte("\r\n\x1b[1;33m⚠ GoBox: " + msg + "\x1b[0m\r\n")
+}
+
+// end tells the user why the session is over and closes the connection.
+func (s *session) end(reason string) {
+	s.close(reason, reason+". Reconnect to resume.", websocket.CloseNormalClosure)
+}
+
+// restart ends the session because the server is shutting down. The
+// service restart close code tells the client to reconnect.
+func (s *session) restart() {
+	s.close(restartReason, restartReason+", reconnect shortly", websocket.CloseServiceRestart)
+}
+
+func (s *session) close(reason, msg string, code int) {
+	s.endOnce.Do(func() {
+		s.endReason = reason
+		s.endMsg = msg
+		s.endCode = code
+		close(s.ended)
+	})
+}
+
+// detach stops the session's writer once Connect returns.
+func (s *session) detach() {
+	s.detachOnce.Do(func() { close(s.detached) })
+}
+
+// isEnded reports whether the scheduler ended the session.
+func (s *session) isEnded() bool {
+	select {
+	case <-s.ended:
+		return true
+	default:
+		return false
+	}
+}
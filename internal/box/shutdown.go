@@ -0,0 +1,125 @@
+package box
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/faiyaz032/gobox/internal/domain"
+	"go.uber.org/zap"
+)
+
+const (
+	restartReason = "server restarting"
+	// restartRetryAfter is the retry hint given to connects refused while
+	// the server shuts down.
+	restartRetryAfter = 5 * time.Second
+)
+
+// Shutdown ends every attached terminal with a restart notice, waits for
+// connects to finish their container operations and stops the background
+// goroutines. Boxes are left running so their users can reconnect once
+// the server is back; the scheduler picks them up again on startup. It
+// must be called once.
+func (s *Svc) Shutdown(ctx context.Context) error {
+	s.stopMu.Lock()
+	close(s.stopping)
+	s.stopMu.Unlock()
+
+	var attached []string
+	s.inScheduler(func(states map[string]*boxState) {
+		for fingerprint, state := range states {
+			if len(state.sessions) == 0 {
+				continue
+			}
+			attached = append(attached, fingerprint)
+			for sess := range state.sessions {
+				sess.restart()
+			}
+		}
+	})
+	for _, fingerprint := range attached {
+		if _, err := s.repo.Touch(ctx, fingerprint); err != nil {
+			s.logger.Warn("Failed to record box activity",
+				zap.String("fingerprint", fingerprint),
+				zap.Error(err))
+		}
+	}
+
+	if err := waitGroup(ctx, &s.connects); err != nil {
+		return err
+	}
+	close(s.schedulerStop)
+	if err := waitGroup(ctx, &s.background); err != nil {
+		return err
+	}
+	return s.leases.ReleaseAll(ctx, s.cfg.ReplicaID)
+}
+
+// trackConnect registers a connect for Shutdown to wait for. It fails once
+// shutdown has begun.
+func (s *Svc) trackConnect() error {
+	s.stopMu.RLock()
+	defer s.stopMu.RUnlock()
+
+	if s.isStopping() {
+		return errRestarting()
+	}
+	s.connects.Add(1)
+	return nil
+}
+
+func (s *Svc) isStopping() bool {
+	select {
+	case <-s.stopping:
+		return true
+	default:
+		return false
+	}
+}
+
+// resume tracks the boxes left running by the previous process as freshly
+// disconnected, so they are paused after their grace period unless their
+// users reconnect. It returns the scheduler's initial state.
+func (s *Svc) resume() map[string]*boxState {
+	states := make(map[string]*boxState)
+	boxes, err := s.repo.ListByStatus(context.Background(), domain.StatusRunning)
+	if err != nil {
+		s.logger.Error("failed to list running boxes", zap.Error(err))
+		return states
+	}
+
+	now := time.Now()
+	for _, b := range boxes {
+		states[b.FingerprintID] = &boxState{
+			tier:           b.Tier,
+			lifecycle:      b.Spec.Lifecycle,
+			sessions:       make(map[*session]struct{}),
+			disconnectedAt: now,
+		}
+	}
+	if len(boxes) > 0 {
+		s.logger.Info("Resumed tracking running boxes", zap.Int("count", len(boxes)))
+	}
+	return states
+}
+
+func errRestarting() error {
+	return domain.NewRateLimitError(restartReason+"; reconnect shortly", restartRetryAfter)
+}
+
+// waitGroup waits for wg until ctx is done.
+func waitGroup(ctx context.Context, wg *sync.WaitGroup) error {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
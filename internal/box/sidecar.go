@@ -0,0 +1,255 @@
+package box
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"maps"
+	"strings"
+
+	"github.com/faiyaz032/gobox/internal/domain"
+	"go.uber.org/zap"
+)
+
+const (
+	kindSidecar = "sidecar"
+	kindEgress  = "egress"
+
+	// boxAlias is the main container's hostname on the private network.
+	boxAlias = "box"
+)
+
+// boxNetworkName derives a Docker-safe name for the private network an
+// owner's box shares with its sidecars.
+func boxNetworkName(fingerprint string) string {
+	sum := sha256.Sum256([]byte(fingerprint))
+	return "gobox-box-" + hex.EncodeToString(sum[:8])
+}
+
+// egressNetworkName derives a Docker-safe name for the network through
+// which an owner's box reaches the outside world.
+func egressNetworkName(fingerprint string) string {
+	sum := sha256.Sum256([]byte(fingerprint))
+	return "gobox-egress-" + hex.EncodeToString(sum[:8])
+}
+
+// ensureEgressNetwork creates the box's own network to the outside world
+// and returns its name, or returns an empty name for a box without
+// network access. Each box gets a subnet of its own so boxes cannot reach
+// one another.
+func (s *Svc) ensureEgressNetwork(ctx context.Context, fingerprint string, spec domain.BoxSpec) (string, error) {
+	if spec.Network.Policy == domain.NetworkNone {
+		return "", nil
+	}
+
+	name := egressNetworkName(fingerprint)
+	if err := s.dockerSvc.EnsureEgressNetwork(ctx, name, map[string]string{
+		labelKind:  kindEgress,
+		labelOwner: fingerprint,
+	}); err != nil {
+		return "", err
+	}
+	return name, nil
+}
+
+// ensureBoxNetwork creates the private network of a box with sidecars and
+// returns its name, or returns an empty name for a box without any.
+func (s *Svc) ensureBoxNetwork(ctx context.Context, fingerprint string, spec domain.BoxSpec) (string, error) {
+	if len(spec.Sidecars) == 0 || spec.Network.Policy == domain.NetworkNone {
+		return "", nil
+	}
+
+	name := boxNetworkName(fingerprint)
+	if err := s.dockerSvc.EnsurePrivateNetwork(ctx, name, map[string]string{
+		labelKind:  kindSidecar,
+		labelOwner: fingerprint,
+	}); err != nil {
+		return "", err
+	}
+	return name, nil
+}
+
+// startSidecars brings the box's sidecars in line with its spec and starts
+// them. Sidecars the spec no longer lists, or whose container is gone, are
+// replaced. It returns the names of the services that failed rather than
+// an error, for the caller to report on the terminal once the lock is
+// released, so a broken service never keeps a user out of their box. The
+// caller holds the box lock.
+func (s *Svc) startSidecars(ctx context.Context, b *domain.Box) []string {
+	spec := s.resolveSpec(b.Spec)
+	existing, err := s.sidecars.ListByFingerprint(ctx, b.FingerprintID)
+	if err != nil {
+		s.logger.Error("Failed to list sidecars",
+			zap.String("fingerprint", b.FingerprintID),
+			zap.Error(err))
+		return nil
+	}
+
+	var failed []string
+	wanted := make(map[string]domain.SpecSidecar, len(spec.Sidecars))
+	for _, sc := range spec.Sidecars {
+		wanted[sc.Name] = sc
+	}
+	running := make(map[string]bool)
+	for _, sc := range existing {
+		if w, ok := wanted[sc.Name]; ok && w.Template == sc.Template {
+			err := s.dockerSvc.StartIfNotRunning(ctx, sc.ContainerID)
+			if err == nil {
+				running[sc.Name] = true
+				continue
+			}
+			if appErr, ok := domain.IsAppError(err); !ok || !appErr.IsType(domain.ErrorTypeNotFound) {
+				failed = append(failed, s.sidecarFailed(b, sc.Name, err))
+				running[sc.Name] = true
+				continue
+			}
+		}
+		s.removeSidecar(ctx, sc)
+	}
+
+	if len(spec.Sidecars) == 0 {
+		return failed
+	}
+	network, err := s.ensureBoxNetwork(ctx, b.FingerprintID, spec)
+	if err != nil {
+		return append(failed, s.sidecarFailed(b, "network", err))
+	}
+	for _, sc := range spec.Sidecars {
+		if running[sc.Name] {
+			continue
+		}
+		if err := s.createSidecar(ctx, b, network, sc); err != nil {
+			failed = append(failed, s.sidecarFailed(b, sc.Name, err))
+		}
+	}
+	return failed
+}
+
+func (s *Svc) createSidecar(ctx context.Context, b *domain.Box, network string, sc domain.SpecSidecar) error {
+	tmpl := s.cfg.Sidecars[sc.Template]
+	env := make(map[string]string)
+	for _, kv := range tmpl.Env {
+		name, value, _ := strings.Cut(kv, "=")
+		env[name] = value
+	}
+	maps.Copy(env, sc.Env)
+
+	// service images write to their root filesystem, so sidecars run
+	// root-friendly whatever their box's profile, under its runtime
+	spec := s.resolveSpec(b.Spec)
+	security := s.cfg.SecurityOptions(spec.Template)
+	security.Profile = domain.SecurityRootFriendly
+
+	containerID, err := s.dockerSvc.CreateSidecar(ctx, domain.SidecarOptions{
+		Image:     tmpl.Image,
+		Resources: domain.SidecarResources,
+		Env:       specEnv(env),
+		Network:   network,
+		Alias:     sc.Name,
+		Security:  security,
+		Runtime:   s.cfg.Templates[spec.Template].Runtime,
+		Labels: map[string]string{
+			labelKind:  kindSidecar,
+			labelBox:   b.ID.String(),
+			labelOwner: b.FingerprintID,
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	if _, err := s.sidecars.Create(ctx, domain.Sidecar{
+		BoxID:       b.ID,
+		Name:        sc.Name,
+		Template:    sc.Template,
+		ContainerID: containerID,
+	}); err != nil {
+		_ = s.dockerSvc.RemoveContainer(ctx, containerID)
+		// a connect on another replica created it first
+		if appErr, ok := domain.IsAppError(err); ok && appErr.IsType(domain.ErrorTypeConflict) {
+			return nil
+		}
+		return err
+	}
+	if err := s.dockerSvc.StartIfNotRunning(ctx, containerID); err != nil {
+		return err
+	}
+
+	s.logger.Info("Started sidecar",
+		zap.String("fingerprint", b.FingerprintID),
+		zap.String("name", sc.Name),
+		zap.String("template", sc.Template),
+		zap.String("container_id", containerID))
+	return nil
+}
+
+// sidecarFailed logs a service that failed to start and returns its name.
+func (s *Svc) sidecarFailed(b *domain.Box, name string, err error) string {
+	s.logger.Warn("Failed to start sidecar",
+		zap.String("fingerprint", b.FingerprintID),
+		zap.String("name", name),
+		zap.Error(err))
+	return name
+}
+
+// sidecarsDo applies a container operation, such as pausing or stopping,
+// to every sidecar of the owner's box.
+func (s *Svc) sidecarsDo(ctx context.Context, fingerprint string, op func(context.Context, string) error) {
+	sidecars, err := s.sidecars.ListByFingerprint(ctx, fingerprint)
+	if err != nil {
+		s.logger.Error("Failed to list sidecars",
+			zap.String("fingerprint", fingerprint),
+			zap.Error(err))
+		return
+	}
+
+	for _, sc := range sidecars {
+		if err := op(ctx, sc.ContainerID); err != nil {
+			s.logger.Warn("Error changing sidecar state",
+				zap.String("fingerprint", fingerprint),
+				zap.String("name", sc.Name),
+				zap.Error(err))
+		}
+	}
+}
+
+// removeSidecars removes the sidecars and the private and egress networks
+// of the owner's box. It must run before the box row is deleted, which
+// takes the sidecar rows with it, and after the main container is gone so
+// the networks are free.
+func (s *Svc) removeSidecars(ctx context.Context, fingerprint string) {
+	sidecars, err := s.sidecars.ListByFingerprint(ctx, fingerprint)
+	if err != nil {
+		s.logger.Error("Failed to list sidecars",
+			zap.String("fingerprint", fingerprint),
+			zap.Error(err))
+		return
+	}
+	for _, sc := range sidecars {
+		s.removeSidecar(ctx, sc)
+	}
+
+	for _, name := range []string{boxNetworkName(fingerprint), egressNetworkName(fingerprint)} {
+		err = s.dockerSvc.RemoveNetwork(ctx, name)
+		if appErr, ok := domain.IsAppError(err); err != nil && (!ok || !appErr.IsType(domain.ErrorTypeNotFound)) {
+			s.logger.Warn("Failed to remove box network",
+				zap.String("fingerprint", fingerprint),
+				zap.String("network", name),
+				zap.Error(err))
+		}
+	}
+}
+
+func (s *Svc) removeSidecar(ctx context.Context, sc domain.Sidecar) {
+	if err := s.dockerSvc.RemoveContainer(ctx, sc.ContainerID); err != nil {
+		s.logger.Warn("Failed to remove sidecar container",
+			zap.String("name", sc.Name),
+			zap.String("container_id", sc.ContainerID),
+			zap.Error(err))
+	}
+	if err := s.sidecars.Delete(ctx, sc.ID); err != nil {
+		s.logger.Error("Failed to delete sidecar",
+			zap.String("name", sc.Name),
+			zap.Error(err))
+	}
+}
@@ -0,0 +1,244 @@
+package box
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/faiyaz032/gobox/internal/domain"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+const (
+	kindSnapshot = "snapshot"
+
+	maxSnapshotNameLength = 64
+)
+
+func snapshotRef(id uuid.UUID) string {
+	return "gobox-snapshot:" + id.String()
+}
+
+// CreateSnapshot commits the owner's box to a tagged image, home directory
+// included. Running boxes are briefly frozen while the filesystem is
+// captured.
+func (s *Svc) CreateSnapshot(ctx context.Context, fingerprint, name string) (*domain.Snapshot, error) {
+	if strings.TrimSpace(fingerprint) == "" {
+		return nil, domain.NewValidationError("fingerprint cannot be empty")
+	}
+	name = strings.TrimSpace(name)
+	if name == "" {
+		name = "snapshot-" + time.Now().UTC().Format("20060102-150405")
+	}
+	if len(name) > maxSnapshotNameLength {
+		return nil, domain.NewValidationError(fmt.Sprintf("snapshot name cannot be longer than %d characters", maxSnapshotNameLength))
+	}
+
+	usage, err := s.snapshots.Usage(ctx, fingerprint)
+	if err != nil {
+		return nil, err
+	}
+	if s.cfg.SnapshotMaxCount > 0 && usage.Count >= s.cfg.SnapshotMaxCount {
+		return nil, domain.NewConflictError(fmt.Sprintf("snapshot limit of %d reached; delete a snapshot first", s.cfg.SnapshotMaxCount))
+	}
+
+	b, err := s.repo.GetByFingerprint(ctx, fingerprint)
+	if err != nil {
+		return nil, err
+	}
+	if b.ContainerID == "" {
+		return nil, domain.NewConflictError("box is hibernated; connect to it before taking a snapshot")
+	}
+
+	id := uuid.New()
+	ref := snapshotRef(id)
+	labels := map[string]string{
+		labelKind:  kindSnapshot,
+		labelBox:   b.ID.String(),
+		labelOwner: fingerprint,
+	}
+	if err := s.withHome(ctx, b.ContainerID, labels, func(containerID string) error {
+		_, err := s.dockerSvc.CommitContainer(ctx, containerID, ref, labels)
+		return err
+	}); err != nil {
+		return nil, err
+	}
+
+	size, err := s.dockerSvc.ImageSize(ctx, ref)
+	if err != nil {
+		s.discardImage(ref)
+		return nil, err
+	}
+	if s.cfg.SnapshotMaxBytes > 0 && usage.SizeBytes+size > s.cfg.SnapshotMaxBytes {
+		s.discardImage(ref)
+		return nil, domain.NewConflictError(fmt.Sprintf("snapshot storage limit of %d bytes reached; delete a snapshot first", s.cfg.SnapshotMaxBytes))
+	}
+
+	snapshot, err := s.snapshots.Create(ctx, domain.Snapshot{
+		ID:            id,
+		FingerprintID: fingerprint,
+		BoxID:         b.ID,
+		Name:          name,
+		Image:         ref,
+		SizeBytes:     size,
+	})
+	if err != nil {
+		s.discardImage(ref)
+		return nil, err
+	}
+
+	s.logger.Info("Created snapshot",
+		zap.String("fingerprint", fingerprint),
+		zap.String("snapshot_id", id.String()),
+		zap.Int64("size_bytes", size))
+	return snapshot, nil
+}
+
+func (s *Svc) ListSnapshots(ctx context.Context, fingerprint string) ([]domain.Snapshot, error) {
+	if strings.TrimSpace(fingerprint) == "" {
+		return nil, domain.NewValidationError("fingerprint cannot be empty")
+	}
+	return s.snapshots.ListByFingerprint(ctx, fingerprint)
+}
+
+// RestoreSnapshot recreates the owner's box from a snapshot and writes the
+// snapshot's home directory back. Attached terminals are closed since their
+// container is replaced; a box with terminals on another replica is
+// refused.
+func (s *Svc) RestoreSnapshot(ctx context.Context, fingerprint string, id uuid.UUID) (*domain.Box, error) {
+	if strings.TrimSpace(fingerprint) == "" {
+		return nil, domain.NewValidationError("fingerprint cannot be empty")
+	}
+
+	snapshot, err := s.snapshots.Get(ctx, fingerprint, id)
+	if err != nil {
+		return nil, err
+	}
+
+	unlock := s.lockBox(fingerprint)
+	err = s.checkServedElsewhere(fingerprint)
+	var restored *domain.Box
+	if err == nil {
+		s.endSessions(fingerprint, "box restored from snapshot "+snapshot.Name)
+		restored, err = s.replaceContainer(ctx, fingerprint, snapshot.Image, nil)
+	}
+	if err == nil {
+		err = s.restoreHome(ctx, snapshot.Image, restored.ContainerID)
+	}
+	unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("Restored snapshot",
+		zap.String("fingerprint", fingerprint),
+		zap.String("snapshot_id", id.String()))
+	return restored, nil
+}
+
+func (s *Svc) DeleteSnapshot(ctx context.Context, fingerprint string, id uuid.UUID) error {
+	if strings.TrimSpace(fingerprint) == "" {
+		return domain.NewValidationError("fingerprint cannot be empty")
+	}
+
+	snapshot, err := s.snapshots.Get(ctx, fingerprint, id)
+	if err != nil {
+		return err
+	}
+
+	if err := s.dockerSvc.RemoveImage(ctx, snapshot.Image); err != nil {
+		appErr, ok := domain.IsAppError(err)
+		if ok && appErr.IsType(domain.ErrorTypeConflict) {
+			return domain.NewConflictError("snapshot is in use by the box; restore another snapshot first")
+		}
+		if !ok || !appErr.IsType(domain.ErrorTypeNotFound) {
+			return err
+		}
+	}
+
+	return s.snapshots.Delete(ctx, id)
+}
+
+// replaceContainer swaps the owner's container for a new one created from
+// image, creating the box if it no longer exists. A nil spec keeps the
+// box's current one; a new spec brings its tier along, or keeps the box's
+// tier when it names none. The record is switched to the new container
+// before the old one is removed, so a failure leaves the box on one of
+// them. The new container is left stopped for Connect to start. The caller
+// holds the box lock.
+func (s *Svc) replaceContainer(ctx context.Context, fingerprint, image string, spec *domain.BoxSpec) (*domain.Box, error) {
+	b, err := s.findBox(ctx, fingerprint)
+	if err != nil {
+		return nil, err
+	}
+
+	tier := s.cfg.DefaultTier
+	var newSpec domain.BoxSpec
+	if b != nil {
+		tier = b.Tier
+		newSpec = b.Spec
+	}
+	if spec != nil {
+		newSpec = *spec
+		if newSpec.Lifecycle.Tier != "" {
+			tier = newSpec.Lifecycle.Tier
+		}
+		newSpec.Lifecycle.Tier = tier
+	}
+
+	containerID, err := s.createContainer(ctx, fingerprint, image, newSpec)
+	if err != nil {
+		return nil, err
+	}
+
+	if b == nil {
+		created, err := s.repo.Create(ctx, domain.Box{
+			FingerprintID: fingerprint,
+			ContainerID:   containerID,
+			Status:        domain.StatusStopped,
+			LastActive:    time.Now(),
+			Tier:          tier,
+			Image:         image,
+			Spec:          newSpec,
+
+			// the image already holds the result of provisioning
+			ProvisionStatus: domain.ProvisionReady,
+		})
+		if err != nil {
+			_ = s.dockerSvc.RemoveContainer(ctx, containerID)
+			return nil, err
+		}
+		return created, nil
+	}
+
+	updated, err := s.repo.ReplaceContainer(ctx, domain.Box{
+		FingerprintID: fingerprint,
+		ContainerID:   containerID,
+		Status:        domain.StatusStopped,
+		Tier:          tier,
+		Image:         image,
+		Spec:          newSpec,
+	})
+	if err != nil {
+		_ = s.dockerSvc.RemoveContainer(ctx, containerID)
+		return nil, err
+	}
+
+	if b.ContainerID != "" {
+		if err := s.dockerSvc.RemoveContainer(ctx, b.ContainerID); err != nil {
+			s.logger.Warn("failed to remove replaced container",
+				zap.String("container_id", b.ContainerID),
+				zap.Error(err))
+		}
+	}
+	return updated, nil
+}
+
+// discardImage removes an image created by a request that then failed.
+func (s *Svc) discardImage(ref string) {
+	if err := s.dockerSvc.RemoveImage(context.Background(), ref); err != nil {
+		s.logger.Warn("failed to discard image", zap.String("image", ref), zap.Error(err))
+	}
+}
@@ -0,0 +1,332 @@
+package box
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/faiyaz032/gobox/internal/config"
+	"github.com/faiyaz032/gobox/internal/domain"
+	"go.uber.org/zap"
+)
+
+// Limits on what a single spec may ask for.
+const (
+	maxSpecEnv       = 64
+	maxSpecEnvValue  = 4096
+	maxSpecFiles     = 32
+	maxSpecFileBytes = 1024 * 1024
+	maxSpecStartup   = 16
+	maxSpecCommand   = 4096
+	maxSpecProvision = 16
+	maxSpecScript    = 16 * 1024
+	maxSpecPorts     = 16
+	maxSpecSidecars  = 4
+)
+
+var (
+	envNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	// sidecar names are DNS labels on the box's private network
+	sidecarNamePattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?$`)
+)
+
+// CreateBox creates the owner's box from a declarative spec. The container
+// is seeded with the spec's files and left stopped for Connect to start.
+// Like Connect, it requires a solved proof-of-work challenge. The tier is
+// assigned by the server, so a spec naming one is refused.
+func (s *Svc) CreateBox(ctx context.Context, fingerprint string, spec domain.BoxSpec, proof domain.ProofOfWork) (*domain.Box, error) {
+	if strings.TrimSpace(fingerprint) == "" {
+		return nil, domain.NewValidationError("fingerprint cannot be empty")
+	}
+	if spec.Lifecycle.Tier != "" {
+		return nil, domain.NewValidationError("invalid box spec: lifecycle.tier: is assigned by the server")
+	}
+
+	if err := s.checkBanned(ctx, fingerprint); err != nil {
+		return nil, err
+	}
+
+	spec = s.resolveSpec(spec)
+	if err := s.validateSpec(spec); err != nil {
+		return nil, err
+	}
+	image := s.cfg.Templates[spec.Template].Image
+
+	unlock := s.lockBox(fingerprint)
+	defer unlock()
+
+	existing, err := s.findBox(ctx, fingerprint)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return nil, domain.NewConflictError("owner already has a box; delete its data first")
+	}
+	if err := s.verifyProof(ctx, fingerprint, &proof); err != nil {
+		return nil, err
+	}
+
+	containerID, err := s.createContainer(ctx, fingerprint, image, spec)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.dockerSvc.CopyFiles(ctx, containerID, spec.Files); err != nil {
+		_ = s.dockerSvc.RemoveContainer(ctx, containerID)
+		return nil, err
+	}
+
+	created, err := s.repo.Create(ctx, domain.Box{
+		FingerprintID: fingerprint,
+		ContainerID:   containerID,
+		Status:        domain.StatusStopped,
+		LastActive:    time.Now(),
+		Tier:          spec.Lifecycle.Tier,
+		Image:         image,
+		Spec:          spec,
+
+		ProvisionStatus: s.initialProvisionStatus(spec),
+	})
+	if err != nil {
+		_ = s.dockerSvc.RemoveContainer(ctx, containerID)
+		return nil, err
+	}
+
+	s.logger.Info("Created box from spec",
+		zap.String("fingerprint", fingerprint),
+		zap.String("template", spec.Template),
+		zap.String("container_id", created.ContainerID))
+	return created, nil
+}
+
+// resolveSpec fills the fields a spec leaves empty with the server
+// defaults. Boxes created before specs existed have an empty spec.
+func (s *Svc) resolveSpec(spec domain.BoxSpec) domain.BoxSpec {
+	if spec.Template == "" {
+		spec.Template = s.cfg.DefaultTemplate
+	}
+	if spec.Resources == "" {
+		spec.Resources = domain.DefaultResourceProfile
+	}
+	if spec.Network.Policy == "" {
+		spec.Network.Policy = domain.NetworkDefault
+	}
+	if spec.Lifecycle.Tier == "" {
+		spec.Lifecycle.Tier = s.cfg.DefaultTier
+	}
+	ports := make([]domain.SpecPort, len(spec.Ports))
+	for i, p := range spec.Ports {
+		if p.Protocol == "" {
+			p.Protocol = "tcp"
+		}
+		ports[i] = p
+	}
+	spec.Ports = ports
+	sidecars := make([]domain.SpecSidecar, len(spec.Sidecars))
+	for i, sc := range spec.Sidecars {
+		if sc.Name == "" {
+			sc.Name = sc.Template
+		}
+		sidecars[i] = sc
+	}
+	spec.Sidecars = sidecars
+	return spec
+}
+
+// validateSpec checks a resolved spec, reporting every problem with the
+// path of the offending field.
+func (s *Svc) validateSpec(spec domain.BoxSpec) error {
+	var problems []string
+	addf := func(field, format string, args ...any) {
+		problems = append(problems, field+": "+fmt.Sprintf(format, args...))
+	}
+
+	if _, ok := s.cfg.Templates[spec.Template]; !ok {
+		addf("template", "unknown template %q", spec.Template)
+	}
+	if _, ok := domain.ResourceProfiles[spec.Resources]; !ok {
+		addf("resources", "unknown resource profile %q", spec.Resources)
+	}
+
+	if len(spec.Env) > maxSpecEnv {
+		addf("env", "cannot set more than %d variables", maxSpecEnv)
+	}
+	for name, value := range spec.Env {
+		if !envNamePattern.MatchString(name) {
+			addf("env."+name, "invalid variable name")
+		}
+		if len(value) > maxSpecEnvValue {
+			addf("env."+name, "value cannot be longer than %d bytes", maxSpecEnvValue)
+		}
+		if strings.ContainsRune(value, 0) {
+			addf("env."+name, "value cannot contain NUL bytes")
+		}
+	}
+
+	if len(spec.Files) > maxSpecFiles {
+		addf("files", "cannot seed more than %d files", maxSpecFiles)
+	}
+	var fileBytes int
+	for i, f := range spec.Files {
+		field := fmt.Sprintf("files[%d]", i)
+		fileBytes += len(f.Content)
+		if !path.IsAbs(f.Path) || path.Clean(f.Path) != f.Path {
+			addf(field+".path", "must be a clean absolute path")
+		} else if !strings.HasPrefix(f.Path, "/box/") {
+			addf(field+".path", "must be under /box")
+		}
+		if f.Mode != "" {
+			if mode, err := strconv.ParseUint(f.Mode, 8, 32); err != nil || mode > 0777 {
+				addf(field+".mode", "must be an octal permission such as 0644")
+			}
+		}
+	}
+	if fileBytes > maxSpecFileBytes {
+		addf("files", "contents cannot exceed %d bytes in total", maxSpecFileBytes)
+	}
+
+	if len(spec.Startup) > maxSpecStartup {
+		addf("startup", "cannot have more than %d commands", maxSpecStartup)
+	}
+	for i, cmd := range spec.Startup {
+		field := fmt.Sprintf("startup[%d]", i)
+		if strings.TrimSpace(cmd) == "" {
+			addf(field, "cannot be empty")
+		}
+		if len(cmd) > maxSpecCommand {
+			addf(field, "cannot be longer than %d bytes", maxSpecCommand)
+		}
+	}
+
+	if len(spec.Provision) > maxSpecProvision {
+		addf("provision", "cannot have more than %d scripts", maxSpecProvision)
+	}
+	for i, script := range spec.Provision {
+		field := fmt.Sprintf("provision[%d]", i)
+		if strings.TrimSpace(script) == "" {
+			addf(field, "cannot be empty")
+		}
+		if len(script) > maxSpecScript {
+			addf(field, "cannot be longer than %d bytes", maxSpecScript)
+		}
+	}
+
+	if len(spec.Ports) > maxSpecPorts {
+		addf("ports", "cannot expose more than %d ports", maxSpecPorts)
+	}
+	seen := make(map[domain.SpecPort]bool)
+	for i, p := range spec.Ports {
+		field := fmt.Sprintf("ports[%d]", i)
+		if p.Container < 1 || p.Container > 65535 {
+			addf(field+".container", "must be between 1 and 65535")
+		}
+		if p.Protocol != "tcp" && p.Protocol != "udp" {
+			addf(field+".protocol", "must be tcp or udp")
+		}
+		if seen[p] {
+			addf(field, "duplicate port")
+		}
+		seen[p] = true
+	}
+
+	if len(spec.Sidecars) > maxSpecSidecars {
+		addf("sidecars", "cannot have more than %d entries", maxSpecSidecars)
+	}
+	names := make(map[string]bool)
+	for i, sc := range spec.Sidecars {
+		field := fmt.Sprintf("sidecars[%d]", i)
+		if _, ok := s.cfg.Sidecars[sc.Template]; !ok {
+			addf(field+".template", "unknown sidecar template %q", sc.Template)
+		}
+		if !sidecarNamePattern.MatchString(sc.Name) {
+			addf(field+".name", "must be a lowercase hostname")
+		}
+		if names[sc.Name] {
+			addf(field+".name", "duplicate name %q", sc.Name)
+		}
+		// the main container's hostname on the private network
+		if sc.Name == boxAlias {
+			addf(field+".name", "%q is reserved", boxAlias)
+		}
+		names[sc.Name] = true
+		if len(sc.Env) > maxSpecEnv {
+			addf(field+".env", "cannot set more than %d variables", maxSpecEnv)
+		}
+		for name, value := range sc.Env {
+			if !envNamePattern.MatchString(name) {
+				addf(field+".env."+name, "invalid variable name")
+			}
+			if len(value) > maxSpecEnvValue {
+				addf(field+".env."+name, "cannot be longer than %d bytes", maxSpecEnvValue)
+			}
+		}
+	}
+
+	switch spec.Network.Policy {
+	case domain.NetworkDefault:
+	case domain.NetworkNone:
+		if len(spec.Ports) > 0 {
+			addf("ports", "cannot be exposed with network policy %q", domain.NetworkNone)
+		}
+		if len(spec.Sidecars) > 0 {
+			addf("sidecars", "cannot be used with network policy %q", domain.NetworkNone)
+		}
+	default:
+		addf("network.policy", "must be %q or %q", domain.NetworkDefault, domain.NetworkNone)
+	}
+
+	if policy, ok := s.cfg.Policies[spec.Lifecycle.Tier]; !ok {
+		addf("lifecycle.tier", "unknown tier %q", spec.Lifecycle.Tier)
+	} else {
+		if spec.Lifecycle.IdleTimeout != "" {
+			d, err := time.ParseDuration(spec.Lifecycle.IdleTimeout)
+			switch {
+			case err != nil || d <= 0:
+				addf("lifecycle.idle_timeout", "must be a positive duration such as 15m")
+			case policy.IdleTimeout > 0 && d > policy.IdleTimeout:
+				addf("lifecycle.idle_timeout", "cannot exceed the tier's %s", formatDuration(policy.IdleTimeout))
+			}
+		}
+		// without an expiry a box kept out of hibernation would hold its
+		// container forever
+		if spec.Lifecycle.NoHibernate && policy.HibernateAfter > 0 && policy.ExpireAfter <= 0 {
+			addf("lifecycle.no_hibernate", "tier %q only keeps idle boxes by hibernating them", spec.Lifecycle.Tier)
+		}
+	}
+
+	if len(problems) == 0 {
+		return nil
+	}
+	// map iteration above makes the order of env problems random
+	slices.Sort(problems)
+	return domain.NewValidationError("invalid box spec: " + strings.Join(problems, "; "))
+}
+
+// policy returns the lifecycle policy of a box: its tier's, adjusted by
+// the lifecycle settings of its spec.
+func (s *Svc) policy(tier string, lifecycle domain.LifecycleSpec) config.LifecyclePolicy {
+	policy := s.cfg.Policy(tier)
+	// the spec was validated when the box was created
+	if d, err := time.ParseDuration(lifecycle.IdleTimeout); err == nil && d > 0 &&
+		(policy.IdleTimeout <= 0 || d < policy.IdleTimeout) {
+		policy.IdleTimeout = d
+	}
+	if lifecycle.NoHibernate && policy.ExpireAfter > 0 {
+		policy.HibernateAfter = 0
+	}
+	return policy
+}
+
+// specEnv renders the spec's variables in a stable order.
+func specEnv(env map[string]string) []string {
+	vars := make([]string, 0, len(env))
+	for name, value := range env {
+		vars = append(vars, name+"="+value)
+	}
+	slices.Sort(vars)
+	return vars
+}
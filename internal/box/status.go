@@ -0,0 +1,34 @@
+package box
+
+import (
+	"context"
+	"strings"
+
+	"github.com/faiyaz032/gobox/internal/domain"
+)
+
+// Status reports the owner's box and the runtime its container runs
+// under.
+func (s *Svc) Status(ctx context.Context, fingerprint string) (*domain.BoxStatusReport, error) {
+	if strings.TrimSpace(fingerprint) == "" {
+		return nil, domain.NewValidationError("fingerprint cannot be empty")
+	}
+
+	box, err := s.repo.GetByFingerprint(ctx, fingerprint)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &domain.BoxStatusReport{Box: *box}
+	if box.Status == domain.StatusHibernated {
+		return report, nil
+	}
+
+	report.Runtime, err = s.dockerSvc.ContainerRuntime(ctx, box.ContainerID)
+	if err != nil {
+		if appErr, ok := domain.IsAppError(err); !ok || !appErr.IsType(domain.ErrorTypeNotFound) {
+			return nil, err
+		}
+	}
+	return report, nil
+}
@@ -0,0 +1,208 @@
+package box
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"maps"
+	"strings"
+
+	"github.com/faiyaz032/gobox/internal/domain"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+const kindHome = "home"
+
+// homeVolumeName derives a Docker-safe volume name from the owner's
+// fingerprint.
+func homeVolumeName(fingerprint string) string {
+	sum := sha256.Sum256([]byte(fingerprint))
+	return "gobox-home-" + hex.EncodeToString(sum[:8])
+}
+
+// containerOptions prepares the container settings for a box from its
+// spec, creating the owner's home volume on first use when home volumes are
+// enabled.
+func (s *Svc) containerOptions(ctx context.Context, fingerprint, image string, spec domain.BoxSpec) (domain.ContainerOptions, error) {
+	spec = s.resolveSpec(spec)
+	resources, ok := domain.ResourceProfiles[spec.Resources]
+	if !ok {
+		resources = domain.DefaultResources
+	}
+	// /box is the user's home so dotfiles copied there take effect; the
+	// owner's variables override it and the box's override both
+	env, err := s.ownerEnv(ctx, fingerprint, domain.EnvAtCreate)
+	if err != nil {
+		return domain.ContainerOptions{}, err
+	}
+	if _, ok := env["HOME"]; !ok {
+		env["HOME"] = "/box"
+	}
+	maps.Copy(env, spec.Env)
+	egressNetwork, err := s.ensureEgressNetwork(ctx, fingerprint, spec)
+	if err != nil {
+		return domain.ContainerOptions{}, err
+	}
+	privateNetwork, err := s.ensureBoxNetwork(ctx, fingerprint, spec)
+	if err != nil {
+		return domain.ContainerOptions{}, err
+	}
+	opts := domain.ContainerOptions{
+		Image:     image,
+		Resources: resources,
+		Env:       specEnv(env),
+		Startup:   spec.Startup,
+		Ports:     spec.Ports,
+		Network:   spec.Network.Policy,
+		Labels:    map[string]string{labelOwner: fingerprint},
+
+		EgressNetwork:  egressNetwork,
+		PrivateNetwork: privateNetwork,
+		PrivateAlias:   boxAlias,
+		Security:       s.cfg.SecurityOptions(spec.Template),
+		Runtime:        s.cfg.Templates[spec.Template].Runtime,
+	}
+	if !s.cfg.HomeVolumes {
+		return opts, nil
+	}
+
+	name := homeVolumeName(fingerprint)
+	if err := s.dockerSvc.EnsureVolume(ctx, name, map[string]string{
+		labelKind:  kindHome,
+		labelOwner: fingerprint,
+	}); err != nil {
+		return domain.ContainerOptions{}, err
+	}
+	opts.HomeVolume = name
+	return opts, nil
+}
+
+// createContainer creates a container for the owner's box and copies in
+// their dotfiles. The container is left stopped.
+func (s *Svc) createContainer(ctx context.Context, fingerprint, image string, spec domain.BoxSpec) (string, error) {
+	opts, err := s.containerOptions(ctx, fingerprint, image, spec)
+	if err != nil {
+		return "", err
+	}
+	containerID, err := s.dockerSvc.CreateContainer(ctx, opts)
+	if err != nil {
+		return "", err
+	}
+
+	s.injectDotfiles(ctx, fingerprint, containerID, spec)
+	return containerID, nil
+}
+
+// withHome runs fn on a container holding the box's whole filesystem. A
+// commit or export of the box's own container leaves out /box while it is
+// kept on a home volume, so the container is committed to a temporary
+// image and /box copied into a stopped container of it, the way a clone
+// copies the home of its source. Without home volumes fn gets the box's
+// container itself.
+func (s *Svc) withHome(ctx context.Context, containerID string, labels map[string]string, fn func(containerID string) error) error {
+	if !s.cfg.HomeVolumes {
+		return fn(containerID)
+	}
+
+	ref := "gobox-home:" + uuid.NewString()
+	if _, err := s.dockerSvc.CommitContainer(ctx, containerID, ref, labels); err != nil {
+		return err
+	}
+	defer s.discardImage(ref)
+
+	copyID, err := s.dockerSvc.CreateContainer(ctx, domain.ContainerOptions{
+		Image:     ref,
+		Resources: domain.DefaultResources,
+		Network:   domain.NetworkNone,
+		Labels:    labels,
+	})
+	if err != nil {
+		return err
+	}
+	defer s.discardContainer(copyID)
+
+	if err := s.dockerSvc.CopyDirectory(ctx, containerID, copyID, "/box"); err != nil {
+		return err
+	}
+	return fn(copyID)
+}
+
+// restoreHome writes /box of image, captured by withHome, into the home
+// volume of the box's new container. Files the owner created since the
+// capture are kept. Without home volumes the container already has them.
+func (s *Svc) restoreHome(ctx context.Context, image, containerID string) error {
+	if !s.cfg.HomeVolumes {
+		return nil
+	}
+
+	copyID, err := s.dockerSvc.CreateContainer(ctx, domain.ContainerOptions{
+		Image:     image,
+		Resources: domain.DefaultResources,
+		Network:   domain.NetworkNone,
+	})
+	if err != nil {
+		return err
+	}
+	defer s.discardContainer(copyID)
+
+	return s.dockerSvc.CopyDirectory(ctx, copyID, containerID, "/box")
+}
+
+// discardContainer removes a container created for a single request.
+func (s *Svc) discardContainer(containerID string) {
+	if err := s.dockerSvc.RemoveContainer(context.Background(), containerID); err != nil {
+		s.logger.Warn("failed to discard container", zap.String("container_id", containerID), zap.Error(err))
+	}
+}
+
+// HomeVolumeUsage reports the size of the owner's persistent home volume.
+func (s *Svc) HomeVolumeUsage(ctx context.Context, fingerprint string) (*domain.HomeVolume, error) {
+	if strings.TrimSpace(fingerprint) == "" {
+		return nil, domain.NewValidationError("fingerprint cannot be empty")
+	}
+
+	name := homeVolumeName(fingerprint)
+	size, err := s.dockerSvc.VolumeSize(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	return &domain.HomeVolume{Name: name, SizeBytes: size}, nil
+}
+
+// DeleteHomeData removes the owner's box and home volume. It refuses while
+// a terminal on any replica is attached so files are not pulled from under
+// a running shell.
+func (s *Svc) DeleteHomeData(ctx context.Context, fingerprint string) error {
+	if strings.TrimSpace(fingerprint) == "" {
+		return domain.NewValidationError("fingerprint cannot be empty")
+	}
+
+	unlock := s.lockBox(fingerprint)
+	defer unlock()
+
+	if s.attached(fingerprint) {
+		return domain.NewConflictError("close all terminals for this box before deleting its data")
+	}
+	if err := s.checkServedElsewhere(fingerprint); err != nil {
+		return err
+	}
+
+	b, err := s.findBox(ctx, fingerprint)
+	if err != nil {
+		return err
+	}
+	if b != nil {
+		s.removeBox(*b)
+	}
+	// an owner who never had a volume, or a retried request, has nothing
+	// left to delete
+	if err := s.dockerSvc.RemoveVolume(ctx, homeVolumeName(fingerprint)); err != nil {
+		if appErr, ok := domain.IsAppError(err); !ok || !appErr.IsType(domain.ErrorTypeNotFound) {
+			return err
+		}
+	}
+
+	s.logger.Info("Deleted home data", zap.String("fingerprint", fingerprint))
+	return nil
+}
@@ -0,0 +1,275 @@
+package box
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/faiyaz032/gobox/internal/domain"
+	"go.uber.org/zap"
+)
+
+// abuseLadder is the response to an owner's first, second, third and
+// later offences within the abuse window.
+var abuseLadder = []domain.AbuseAction{
+	domain.AbuseWarn,
+	domain.AbuseThrottle,
+	domain.AbuseStop,
+	domain.AbuseBan,
+}
+
+// minerHead starts mining offences further up the ladder: unlike a busy
+// build, a miner is never run by accident.
+const minerHead = 2
+
+// throttleDivisor is how much a throttled box's CPU limit is cut by.
+const throttleDivisor = 4
+
+// watchedBox is the watchdog's memory of a running box between samples.
+type watchedBox struct {
+	// hotSince is when the box started running at its CPU limit.
+	hotSince time.Time
+	// flaggedAt is when the box was last flagged.
+	flaggedAt time.Time
+}
+
+// runWatchdog samples running boxes for mining, sustained CPU at the limit
+// and excessive outbound connections, escalating against their owners.
+func (s *Svc) runWatchdog() {
+	watched := make(map[string]*watchedBox)
+
+	ticker := time.NewTicker(s.cfg.Abuse.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			// one replica samples all boxes; the others skip the round
+			_, err := s.locker.TryWithLock(context.Background(), domain.LockAbuseWatchdog, func() {
+				s.inspectBoxes(watched, time.Now())
+			})
+			if err != nil {
+				s.logger.Error("failed to take the watchdog lock", zap.Error(err))
+			}
+		case <-s.stopping:
+			return
+		}
+	}
+}
+
+func (s *Svc) inspectBoxes(watched map[string]*watchedBox, now time.Time) {
+	ctx := context.Background()
+	boxes, err := s.repo.ListByStatus(ctx, domain.StatusRunning)
+	if err != nil {
+		s.logger.Error("failed to list running boxes", zap.Error(err))
+		return
+	}
+
+	running := make(map[string]bool, len(boxes))
+	for _, b := range boxes {
+		running[b.ContainerID] = true
+		w, ok := watched[b.ContainerID]
+		if !ok {
+			w = &watchedBox{}
+			watched[b.ContainerID] = w
+		}
+		if now.Sub(w.flaggedAt) < s.cfg.Abuse.Cooldown {
+			continue
+		}
+
+		kind, detail, flagged := s.detectAbuse(ctx, b, w, now)
+		if !flagged {
+			continue
+		}
+		w.flaggedAt = now
+		w.hotSince = time.Time{}
+		s.escalate(ctx, b, kind, detail)
+	}
+
+	for containerID := range watched {
+		if !running[containerID] {
+			delete(watched, containerID)
+		}
+	}
+}
+
+// detectAbuse samples a box and reports the first abuse found. A box
+// whose container cannot be sampled is skipped until the next round.
+func (s *Svc) detectAbuse(ctx context.Context, b domain.Box, w *watchedBox, now time.Time) (domain.AbuseKind, string, bool) {
+	processes, err := s.dockerSvc.ContainerProcesses(ctx, b.ContainerID)
+	if err != nil {
+		return "", "", false
+	}
+	for _, name := range processes {
+		if slices.Contains(s.cfg.Abuse.MinerNames, strings.ToLower(name)) {
+			return domain.AbuseMiner, "process " + name + " is a known miner", true
+		}
+	}
+
+	if s.cfg.Abuse.MaxConnections > 0 && b.Spec.Network.Policy != domain.NetworkNone {
+		conns, err := s.dockerSvc.OutboundConnections(ctx, b.ContainerID)
+		if err == nil && conns > s.cfg.Abuse.MaxConnections {
+			return domain.AbuseConnections, fmt.Sprintf("%d outbound connections", conns), true
+		}
+	}
+
+	usage, err := s.dockerSvc.CPUUsage(ctx, b.ContainerID)
+	if err != nil {
+		return "", "", false
+	}
+	if usage < s.cfg.Abuse.CPUThreshold {
+		w.hotSince = time.Time{}
+		return "", "", false
+	}
+	if w.hotSince.IsZero() {
+		w.hotSince = now
+	}
+	if now.Sub(w.hotSince) < s.cfg.Abuse.CPUSustain {
+		return "", "", false
+	}
+	return domain.AbuseCPU, "CPU at its limit for " + formatDuration(now.Sub(w.hotSince)), true
+}
+
+// escalate records an offence and applies the owner's next step on the
+// abuse ladder.
+func (s *Svc) escalate(ctx context.Context, b domain.Box, kind domain.AbuseKind, detail string) {
+	offences, err := s.abuse.CountSince(ctx, b.FingerprintID, time.Now().Add(-s.cfg.Abuse.Window))
+	if err != nil {
+		s.logger.Error("failed to count abuse events",
+			zap.String("fingerprint", b.FingerprintID),
+			zap.Error(err))
+		return
+	}
+	step := int(offences)
+	if kind == domain.AbuseMiner {
+		step += minerHead
+	}
+	action := abuseLadder[min(step, len(abuseLadder)-1)]
+
+	if _, err := s.abuse.Create(ctx, domain.AbuseEvent{
+		FingerprintID: b.FingerprintID,
+		ContainerID:   b.ContainerID,
+		Kind:          kind,
+		Detail:        detail,
+		Action:        action,
+	}); err != nil {
+		s.logger.Error("failed to record abuse event",
+			zap.String("fingerprint", b.FingerprintID),
+			zap.Error(err))
+	}
+	s.logger.Warn("Box flagged for abuse",
+		zap.String("fingerprint", b.FingerprintID),
+		zap.String("container_id", b.ContainerID),
+		zap.String("kind", string(kind)),
+		zap.String("detail", detail),
+		zap.String("action", string(action)))
+
+	// terminals hear of the action from the event, on whichever replica
+	// they are attached to
+	switch action {
+	case domain.AbuseThrottle:
+		resources, ok := domain.ResourceProfiles[b.Spec.Resources]
+		if !ok {
+			resources = domain.DefaultResources
+		}
+		if err := s.dockerSvc.ThrottleContainer(ctx, b.ContainerID, resources.NanoCPUs/throttleDivisor); err != nil {
+			s.logger.Error("failed to throttle box",
+				zap.String("container_id", b.ContainerID),
+				zap.Error(err))
+		}
+
+	case domain.AbuseStop, domain.AbuseBan:
+		// wait for every replica to pass the notice on before the box
+		// goes away; a replica shutting down stops it at once
+		reason := s.abuseNotice(action, detail)
+		s.background.Go(func() {
+			select {
+			case <-time.After(s.noticeDelay()):
+			case <-s.stopping:
+			}
+			unlock := s.lockBox(b.FingerprintID)
+			defer unlock()
+			s.endSessions(b.FingerprintID, reason)
+			if current, err := s.findBox(context.Background(), b.FingerprintID); err == nil && current != nil && current.ContainerID == b.ContainerID {
+				_ = s.stopBox(b.FingerprintID, b.ContainerID)
+			}
+		})
+	}
+}
+
+// abuseNotice is what the owner's terminals are told of an action.
+func (s *Svc) abuseNotice(action domain.AbuseAction, detail string) string {
+	switch action {
+	case domain.AbuseWarn:
+		return detail + "; this box will be throttled if it continues"
+	case domain.AbuseThrottle:
+		return detail + "; this box's CPU has been throttled and will be stopped if it continues"
+	case domain.AbuseBan:
+		return "box stopped and owner banned for " + formatDuration(s.cfg.Abuse.BanDuration) + " for abuse (" + detail + ")"
+	default:
+		return "box stopped for abuse (" + detail + ")"
+	}
+}
+
+// noticeDelay is how long every replica takes to see a new abuse event:
+// runLeases checks for them each time it renews its leases.
+func (s *Svc) noticeDelay() time.Duration {
+	return s.cfg.LeaseTTL / 2
+}
+
+// deliverAbuseNotices tells the terminals attached here about the abuse
+// events of their boxes after since, whichever replica's watchdog
+// recorded them. It returns the time of the last event delivered. Events
+// older than a lease are never delivered, so a terminal attached later
+// does not hear of them.
+func (s *Svc) deliverAbuseNotices(fingerprints []string, since, now time.Time) time.Time {
+	if oldest := now.Add(-s.cfg.LeaseTTL); since.Before(oldest) {
+		since = oldest
+	}
+	events, err := s.abuse.ListSince(context.Background(), fingerprints, since)
+	if err != nil {
+		s.logger.Error("failed to list abuse events", zap.Error(err))
+		return since
+	}
+	for _, event := range events {
+		s.notifyOwner(event.FingerprintID, s.abuseNotice(event.Action, event.Detail))
+		since = event.CreatedAt
+	}
+	return since
+}
+
+// notifyOwner prints a message in every terminal attached to the owner's
+// box on this replica. The scheduler only hands over the sessions; each
+// one queues the message for its own writer, so a stalled client holds up
+// neither the scheduler nor the watchdog.
+func (s *Svc) notifyOwner(fingerprint, msg string) {
+	var sessions []*session
+	s.inScheduler(func(states map[string]*boxState) {
+		if state, ok := states[fingerprint]; ok {
+			sessions = slices.Collect(maps.Keys(state.sessions))
+		}
+	})
+	for _, sess := range sessions {
+		sess.notify(msg)
+	}
+}
+
+// checkBanned refuses owners banned within the ban duration.
+func (s *Svc) checkBanned(ctx context.Context, fingerprint string) error {
+	ban, err := s.abuse.LatestBan(ctx, fingerprint)
+	if err != nil {
+		if appErr, ok := domain.IsAppError(err); ok && appErr.IsType(domain.ErrorTypeNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	until := ban.CreatedAt.Add(s.cfg.Abuse.BanDuration)
+	if time.Now().Before(until) {
+		return domain.NewForbiddenError("banned for abuse until " + until.UTC().Format(time.RFC3339))
+	}
+	return nil
+}
@@ -1,19 +1,87 @@
 package config
 
 import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
 	"fmt"
+	"net/netip"
+	"net/url"
+	"os"
+	"strings"
+	"time"
 
+	"github.com/faiyaz032/gobox/internal/domain"
 	"github.com/spf13/viper"
 )
 
 type Config struct {
 	Server      ServerConfig
 	Database    DatabaseConfig
+	Box         BoxConfig
+	Lab         LabConfig
+	Network     NetworkConfig
 	Environment string
 }
 
+// NetworkConfig controls the subnets of the networks GoBox creates for
+// boxes with sidecars and for labs.
+type NetworkConfig struct {
+	// SubnetPools are the parent ranges subnets are carved from. They must
+	// not overlap networks managed outside GoBox.
+	SubnetPools []netip.Prefix
+	// SubnetBits is the prefix length of a box's private subnet.
+	SubnetBits int
+}
+
 type ServerConfig struct {
 	Port string
+	// TrustProxy takes the client IP from X-Forwarded-For and X-Real-IP,
+	// for deployments behind a reverse proxy.
+	TrustProxy bool
+	// AllowedOrigins are the browser origins, besides the server's own,
+	// that may call the API and open terminals.
+	AllowedOrigins []string
+	// AllowLocalhost also allows any localhost origin. It is always on in
+	// the development environment.
+	AllowLocalhost bool
+	// RateLimit throttles requests that create or start containers.
+	RateLimit RateLimitConfig
+	// TLS serves HTTPS on Port.
+	TLS TLSConfig
+	// ShutdownTimeout bounds the graceful shutdown on SIGTERM, including
+	// waiting for connects to finish their container operations.
+	ShutdownTimeout time.Duration
+	// AdminAddr is the address serving expvar metrics, kept off the
+	// public port. Empty disables it.
+	AdminAddr string
+}
+
+// TLSConfig enables HTTPS, with HTTP/2, on the server port. TLS is off when
+// CertFile is empty.
+type TLSConfig struct {
+	// CertFile and KeyFile are the PEM certificate chain and key. They are
+	// reloaded when they change on disk.
+	CertFile string
+	KeyFile  string
+	// SelfSigned generates a development certificate for SelfSignedHosts
+	// at CertFile and KeyFile when they do not exist.
+	SelfSigned      bool
+	SelfSignedHosts []string
+	// RedirectPort, when set, serves plain HTTP redirecting to HTTPS.
+	RedirectPort string
+	// HSTSMaxAge is the Strict-Transport-Security max-age sent over HTTPS.
+	// Zero omits the header.
+	HSTSMaxAge time.Duration
+}
+
+// RateLimitConfig sets token buckets refilling PerMinute tokens a minute
+// up to Burst. A zero rate disables the limit.
+type RateLimitConfig struct {
+	IPPerMinute    float64
+	IPBurst        int
+	OwnerPerMinute float64
+	OwnerBurst     int
 }
 
 type DatabaseConfig struct {
@@ -25,6 +93,273 @@ type DatabaseConfig struct {
 	SSLMode  string
 }
 
+type BoxConfig struct {
+	// Image is the base image built at startup. It backs the default
+	// template unless the template file overrides it.
+	Image string
+	// DefaultTemplate is used by boxes whose spec names no template.
+	DefaultTemplate string
+	// Templates maps template names to the settings boxes are created
+	// with.
+	Templates map[string]TemplateConfig
+	// Sidecars maps sidecar template names to the service containers a
+	// box spec can run next to the box.
+	Sidecars map[string]SidecarConfig
+	// Security hardens box containers.
+	Security SecurityConfig
+	// RuntimeFallback is RuntimeFallbackRefuse or RuntimeFallbackDefault,
+	// deciding what happens when a template's runtime is not registered
+	// with the Docker daemon.
+	RuntimeFallback string
+	// HomeVolumes mounts a per-owner named volume at /box so user files
+	// survive container recreation. Snapshots, export and import capture
+	// only the container and are refused while it is set.
+	HomeVolumes bool
+	// SnapshotMaxCount and SnapshotMaxBytes cap the snapshots kept per
+	// owner. Zero disables the limit.
+	SnapshotMaxCount int64
+	SnapshotMaxBytes int64
+	// ProvisionAttempts is how many times a failing provisioning script is
+	// run before the box is marked failed.
+	ProvisionAttempts int
+	// ProvisionTimeout bounds a single run of a provisioning script.
+	ProvisionTimeout time.Duration
+	// SecretKey is the 32-byte AES-256 key sealing owners' secrets.
+	// Secrets cannot be set when it is empty.
+	SecretKey []byte
+	// DotfilesMaxBytes caps the total size of an owner's dotfiles.
+	DotfilesMaxBytes int64
+	// ArchiveMaxBytes caps the filesystem size of exported and imported
+	// boxes.
+	ArchiveMaxBytes int64
+	// ActivityInterval throttles how often last_active is persisted.
+	ActivityInterval time.Duration
+	// SchedulerInterval is how often the lifecycle scheduler evaluates
+	// attached sessions and disconnected boxes.
+	SchedulerInterval time.Duration
+	// SweepInterval is how often the scheduler scans the database for
+	// expired boxes.
+	SweepInterval time.Duration
+	// DefaultTier is assigned to new boxes and used for unknown tiers.
+	DefaultTier string
+	// Policies holds the lifecycle policy for each tier.
+	Policies map[string]LifecyclePolicy
+	// MaxRunning caps the containers running at once: boxes, their
+	// sidecars and lab nodes. Connects that would start a box beyond it
+	// wait in a queue; labs are refused. Zero disables the cap.
+	MaxRunning int
+	// QueueMax is how many connects may wait for capacity; further ones
+	// are refused.
+	QueueMax int
+	// QueueTimeout is how long a connect waits for capacity before it is
+	// refused.
+	QueueTimeout time.Duration
+	// Abuse configures the watchdog looking for miners and resource hogs.
+	Abuse AbuseConfig
+	// Pow configures the proof-of-work challenge solved before a box is
+	// created.
+	Pow PowConfig
+	// ReplicaID names this server among replicas sharing the database. It
+	// defaults to the hostname and must be unique per replica.
+	ReplicaID string
+	// Replicas is how many servers share the database. With more than
+	// one, proof-of-work needs a shared secret.
+	Replicas int
+	// LeaseTTL is how long a replica's claim on the boxes it has terminals
+	// open to outlives its last heartbeat. Other replicas leave those
+	// boxes running until the claim is released or lapses.
+	LeaseTTL time.Duration
+}
+
+// PowConfig controls the proof-of-work challenge. Difficulty is counted in
+// leading zero bits of a SHA-256 hash, so each bit doubles the work.
+type PowConfig struct {
+	// Difficulty is the difficulty on an idle server. Zero disables the
+	// challenge.
+	Difficulty int
+	// MaxDifficulty caps the difficulty as load and an IP's creation rate
+	// raise it.
+	MaxDifficulty int
+	// TTL is how long a challenge may be solved and presented.
+	TTL time.Duration
+	// Secret is the HMAC key signing challenges. It is random per process
+	// unless configured, which replicas behind one address must share.
+	Secret []byte
+}
+
+// AbuseConfig controls the abuse watchdog. Each offence an owner commits
+// within Window escalates the response: a warning in the terminal, a CPU
+// throttle, a stop, then a ban.
+type AbuseConfig struct {
+	// Interval is how often running boxes are sampled. Zero disables the
+	// watchdog.
+	Interval time.Duration
+	// CPUThreshold is the fraction of a box's CPU limit counted as
+	// running at the limit.
+	CPUThreshold float64
+	// CPUSustain is how long a box may run at the limit before it is
+	// flagged.
+	CPUSustain time.Duration
+	// MaxConnections is the most outbound TCP connections a box may hold.
+	MaxConnections int
+	// MinerNames are process names of known cryptocurrency miners.
+	MinerNames []string
+	// Cooldown is how long a flagged box is left alone before it can be
+	// flagged again.
+	Cooldown time.Duration
+	// Window is how long an offence counts towards escalation.
+	Window time.Duration
+	// BanDuration is how long a banned owner cannot use boxes.
+	BanDuration time.Duration
+	// HostProc is where the host's /proc is mounted. The watchdog reads
+	// box processes and connections there rather than trusting anything
+	// run inside the box.
+	HostProc string
+}
+
+// Policies for templates whose runtime the Docker daemon does not have.
+const (
+	// RuntimeFallbackRefuse stops the server from starting.
+	RuntimeFallbackRefuse = "refuse"
+	// RuntimeFallbackDefault runs the template's boxes under the daemon's
+	// default runtime instead.
+	RuntimeFallbackDefault = "default"
+)
+
+// ResolveRuntimes checks the runtime of every template against the ones
+// registered with the Docker daemon. Under RuntimeFallbackDefault missing
+// runtimes are cleared and the affected templates returned; otherwise the
+// first missing runtime is an error.
+func (c *BoxConfig) ResolveRuntimes(available map[string]bool) ([]string, error) {
+	var fellBack []string
+	for name, template := range c.Templates {
+		if template.Runtime == "" || available[template.Runtime] {
+			continue
+		}
+		if c.RuntimeFallback != RuntimeFallbackDefault {
+			return nil, fmt.Errorf("template %s: runtime %s is not registered with the docker daemon", name, template.Runtime)
+		}
+		template.Runtime = ""
+		c.Templates[name] = template
+		fellBack = append(fellBack, name)
+	}
+	return fellBack, nil
+}
+
+// SecurityConfig controls how box containers are hardened.
+type SecurityConfig struct {
+	// Profile is used by templates that do not pick one.
+	Profile string
+	// Seccomp is the seccomp profile read from BOX_SECCOMP_PROFILE, or
+	// empty for Docker's default one.
+	Seccomp string
+	// PidsLimit caps the processes in a box. Zero leaves it unlimited.
+	PidsLimit int64
+	// UsernsRemap requires the Docker daemon to run containers in a
+	// remapped user namespace, so root in a box is unprivileged on the
+	// host.
+	UsernsRemap bool
+}
+
+// LabConfig controls network labs: sets of interconnected boxes on their
+// own subnets.
+type LabConfig struct {
+	// MaxPerOwner caps the labs an owner may run at once.
+	MaxPerOwner int
+	// TTL deletes a lab this long after it was created.
+	TTL time.Duration
+}
+
+// TemplateConfig describes a template boxes can be created from.
+type TemplateConfig struct {
+	Image string `mapstructure:"image"`
+	// Security is the domain.SecurityStrict or domain.SecurityRootFriendly
+	// profile boxes run under; empty takes the server default.
+	Security string `mapstructure:"security"`
+	// Runtime is the OCI runtime registered with the Docker daemon that
+	// boxes run under, e.g. runsc for gVisor or kata for Kata Containers.
+	// Empty uses the daemon's default runtime.
+	Runtime string `mapstructure:"runtime"`
+	// Provision scripts run as root when a box first starts, before the
+	// scripts of the box's own spec.
+	Provision []string `mapstructure:"provision"`
+}
+
+// SidecarConfig describes a service container boxes can run as a sidecar.
+type SidecarConfig struct {
+	Image string `mapstructure:"image"`
+	// Env holds KEY=value pairs, e.g. the service's default credentials.
+	Env []string `mapstructure:"env"`
+}
+
+// defaultSidecars are available unless the template file redefines them.
+var defaultSidecars = map[string]SidecarConfig{
+	"postgres": {
+		Image: "postgres:16-alpine",
+		Env:   []string{"POSTGRES_USER=gobox", "POSTGRES_PASSWORD=gobox", "POSTGRES_DB=gobox"},
+	},
+	"redis": {
+		Image: "redis:7-alpine",
+	},
+	"mysql": {
+		Image: "mysql:8.4",
+		Env:   []string{"MYSQL_ROOT_PASSWORD=gobox", "MYSQL_USER=gobox", "MYSQL_PASSWORD=gobox", "MYSQL_DATABASE=gobox"},
+	},
+}
+
+// LifecyclePolicy controls when a box is stopped or deleted. A zero
+// duration disables the corresponding rule.
+type LifecyclePolicy struct {
+	// GracePeriod is how long a box keeps running after its last
+	// connection closes before it is frozen.
+	GracePeriod time.Duration `mapstructure:"grace_period"`
+	// StopAfter stops a frozen box after this long without activity,
+	// terminating its processes.
+	StopAfter time.Duration `mapstructure:"stop_after"`
+	// IdleTimeout ends a session after this long without keystrokes.
+	IdleTimeout time.Duration `mapstructure:"idle_timeout"`
+	// MaxSession ends a session after this long regardless of activity.
+	MaxSession time.Duration `mapstructure:"max_session"`
+	// ExpireAfter deletes a box after this long without activity.
+	ExpireAfter time.Duration `mapstructure:"expire_after"`
+	// HardExpiry deletes a box this long after it was created.
+	HardExpiry time.Duration `mapstructure:"hard_expiry"`
+	// HibernateAfter commits a stopped box to an image and removes its
+	// container after this long without activity.
+	HibernateAfter time.Duration `mapstructure:"hibernate_after"`
+	// HibernateRetention deletes a hibernated box and its image after
+	// this long without activity. It replaces ExpireAfter for hibernated
+	// boxes.
+	HibernateRetention time.Duration `mapstructure:"hibernate_retention"`
+	// WarnBefore is how long before a stop or delete the user is warned.
+	WarnBefore time.Duration `mapstructure:"warn_before"`
+	// QueuePriority orders the waiting room when the server is at
+	// capacity: higher tiers are admitted first, FIFO within a tier.
+	QueuePriority int `mapstructure:"queue_priority"`
+}
+
+// Policy returns the lifecycle policy for a tier, falling back to the
+// default tier.
+func (c BoxConfig) Policy(tier string) LifecyclePolicy {
+	if p, ok := c.Policies[tier]; ok {
+		return p
+	}
+	return c.Policies[c.DefaultTier]
+}
+
+// SecurityOptions returns the hardening for containers of a template.
+func (c BoxConfig) SecurityOptions(template string) domain.SecurityOptions {
+	profile := c.Templates[template].Security
+	if profile == "" {
+		profile = c.Security.Profile
+	}
+	return domain.SecurityOptions{
+		Profile:   profile,
+		Seccomp:   c.Security.Seccomp,
+		PidsLimit: c.Security.PidsLimit,
+	}
+}
+
 func LoadConfig() (*Config, error) {
 	viper.AutomaticEnv()
 
@@ -34,6 +369,67 @@ func LoadConfig() (*Config, error) {
 
 	viper.SetDefault("SERVER_PORT", "8010")
 	viper.SetDefault("ENVIRONMENT", "development")
+	viper.SetDefault("BOX_IMAGE", "gobox-base:latest")
+	viper.SetDefault("BOX_DEFAULT_TEMPLATE", "default")
+	viper.SetDefault("BOX_HOME_VOLUMES", false)
+	viper.SetDefault("BOX_SNAPSHOT_MAX_COUNT", 5)
+	viper.SetDefault("BOX_SNAPSHOT_MAX_BYTES", 2*1024*1024*1024)
+	viper.SetDefault("BOX_ARCHIVE_MAX_BYTES", 1024*1024*1024)
+	viper.SetDefault("BOX_DOTFILES_MAX_BYTES", 1024*1024)
+	viper.SetDefault("BOX_PROVISION_ATTEMPTS", 3)
+	viper.SetDefault("BOX_PROVISION_TIMEOUT", "10m")
+	viper.SetDefault("BOX_ACTIVITY_INTERVAL", "1m")
+	viper.SetDefault("BOX_SCHEDULER_INTERVAL", "5s")
+	viper.SetDefault("BOX_SWEEP_INTERVAL", "5m")
+	viper.SetDefault("BOX_DEFAULT_TIER", "default")
+	viper.SetDefault("BOX_GRACE_PERIOD", "5s")
+	viper.SetDefault("BOX_STOP_AFTER", "30m")
+	viper.SetDefault("BOX_IDLE_TIMEOUT", "30m")
+	viper.SetDefault("BOX_MAX_SESSION", "0")
+	viper.SetDefault("BOX_EXPIRE_AFTER", "24h")
+	viper.SetDefault("BOX_HARD_EXPIRY", "0")
+	viper.SetDefault("BOX_HIBERNATE_AFTER", "6h")
+	viper.SetDefault("BOX_HIBERNATE_RETENTION", "720h")
+	viper.SetDefault("BOX_WARN_BEFORE", "2m")
+	viper.SetDefault("BOX_SECURITY_PROFILE", domain.SecurityRootFriendly)
+	viper.SetDefault("BOX_SECCOMP_PROFILE", "./security/seccomp.json")
+	viper.SetDefault("BOX_PIDS_LIMIT", 512)
+	viper.SetDefault("BOX_USERNS_REMAP", false)
+	viper.SetDefault("BOX_RUNTIME_FALLBACK", RuntimeFallbackRefuse)
+	viper.SetDefault("SERVER_TRUST_PROXY", false)
+	viper.SetDefault("SERVER_ALLOWED_ORIGINS", "")
+	viper.SetDefault("SERVER_ALLOW_LOCALHOST", false)
+	viper.SetDefault("SERVER_TLS_SELF_SIGNED", false)
+	viper.SetDefault("SERVER_TLS_SELF_SIGNED_HOSTS", "localhost,127.0.0.1,::1")
+	viper.SetDefault("SERVER_HTTP_REDIRECT_PORT", "")
+	viper.SetDefault("SERVER_HSTS_MAX_AGE", "4320h")
+	viper.SetDefault("SERVER_SHUTDOWN_TIMEOUT", "30s")
+	viper.SetDefault("SERVER_ADMIN_ADDR", "127.0.0.1:8011")
+	viper.SetDefault("RATE_LIMIT_IP_PER_MINUTE", 10)
+	viper.SetDefault("RATE_LIMIT_IP_BURST", 20)
+	viper.SetDefault("RATE_LIMIT_OWNER_PER_MINUTE", 6)
+	viper.SetDefault("RATE_LIMIT_OWNER_BURST", 10)
+	viper.SetDefault("BOX_MAX_RUNNING", 0)
+	viper.SetDefault("BOX_QUEUE_MAX", 100)
+	viper.SetDefault("BOX_QUEUE_TIMEOUT", "10m")
+	viper.SetDefault("BOX_ABUSE_INTERVAL", "30s")
+	viper.SetDefault("BOX_ABUSE_CPU_THRESHOLD", 0.9)
+	viper.SetDefault("BOX_ABUSE_CPU_SUSTAIN", "10m")
+	viper.SetDefault("BOX_ABUSE_MAX_CONNECTIONS", 200)
+	viper.SetDefault("BOX_ABUSE_MINER_NAMES", "xmrig,xmr-stak,minerd,cpuminer,ccminer,cgminer,bfgminer,ethminer,nbminer,t-rex,lolminer,phoenixminer,nanominer,gminer,teamredminer,srbminer")
+	viper.SetDefault("BOX_ABUSE_COOLDOWN", "5m")
+	viper.SetDefault("BOX_ABUSE_WINDOW", "24h")
+	viper.SetDefault("BOX_ABUSE_BAN_DURATION", "168h")
+	viper.SetDefault("BOX_ABUSE_HOST_PROC", "/proc")
+	viper.SetDefault("BOX_POW_DIFFICULTY", 16)
+	viper.SetDefault("BOX_POW_MAX_DIFFICULTY", 24)
+	viper.SetDefault("BOX_POW_TTL", "2m")
+	viper.SetDefault("BOX_LEASE_TTL", "30s")
+	viper.SetDefault("BOX_REPLICAS", 1)
+	viper.SetDefault("NETWORK_SUBNET_POOLS", "10.128.0.0/14")
+	viper.SetDefault("NETWORK_SUBNET_BITS", 28)
+	viper.SetDefault("LAB_MAX_PER_OWNER", 2)
+	viper.SetDefault("LAB_TTL", "4h")
 
 	if err := viper.ReadInConfig(); err != nil {
 		if _, ok := err.(viper.ConfigFileNotFoundError); !ok {
@@ -43,7 +439,16 @@ func LoadConfig() (*Config, error) {
 
 	config := &Config{
 		Server: ServerConfig{
-			Port: viper.GetString("SERVER_PORT"),
+			Port:       viper.GetString("SERVER_PORT"),
+			TrustProxy: viper.GetBool("SERVER_TRUST_PROXY"),
+			RateLimit: RateLimitConfig{
+				IPPerMinute:    viper.GetFloat64("RATE_LIMIT_IP_PER_MINUTE"),
+				IPBurst:        viper.GetInt("RATE_LIMIT_IP_BURST"),
+				OwnerPerMinute: viper.GetFloat64("RATE_LIMIT_OWNER_PER_MINUTE"),
+				OwnerBurst:     viper.GetInt("RATE_LIMIT_OWNER_BURST"),
+			},
+			ShutdownTimeout: viper.GetDuration("SERVER_SHUTDOWN_TIMEOUT"),
+			AdminAddr:       viper.GetString("SERVER_ADMIN_ADDR"),
 		},
 		Database: DatabaseConfig{
 			Host:     viper.GetString("POSTGRES_HOST"),
@@ -53,8 +458,293 @@ func LoadConfig() (*Config, error) {
 			DBName:   viper.GetString("POSTGRES_DB"),
 			SSLMode:  viper.GetString("POSTGRES_SSLMODE"),
 		},
+		Box: BoxConfig{
+			Image:             viper.GetString("BOX_IMAGE"),
+			DefaultTemplate:   viper.GetString("BOX_DEFAULT_TEMPLATE"),
+			HomeVolumes:       viper.GetBool("BOX_HOME_VOLUMES"),
+			SnapshotMaxCount:  viper.GetInt64("BOX_SNAPSHOT_MAX_COUNT"),
+			SnapshotMaxBytes:  viper.GetInt64("BOX_SNAPSHOT_MAX_BYTES"),
+			ArchiveMaxBytes:   viper.GetInt64("BOX_ARCHIVE_MAX_BYTES"),
+			DotfilesMaxBytes:  viper.GetInt64("BOX_DOTFILES_MAX_BYTES"),
+			ProvisionAttempts: viper.GetInt("BOX_PROVISION_ATTEMPTS"),
+			ProvisionTimeout:  viper.GetDuration("BOX_PROVISION_TIMEOUT"),
+			ActivityInterval:  viper.GetDuration("BOX_ACTIVITY_INTERVAL"),
+			SchedulerInterval: viper.GetDuration("BOX_SCHEDULER_INTERVAL"),
+			SweepInterval:     viper.GetDuration("BOX_SWEEP_INTERVAL"),
+			DefaultTier:       viper.GetString("BOX_DEFAULT_TIER"),
+			RuntimeFallback:   viper.GetString("BOX_RUNTIME_FALLBACK"),
+			MaxRunning:        viper.GetInt("BOX_MAX_RUNNING"),
+			QueueMax:          viper.GetInt("BOX_QUEUE_MAX"),
+			QueueTimeout:      viper.GetDuration("BOX_QUEUE_TIMEOUT"),
+		},
 		Environment: viper.GetString("ENVIRONMENT"),
 	}
+	// both drive tickers, which need a positive interval
+	if config.Box.SchedulerInterval <= 0 {
+		return nil, fmt.Errorf("BOX_SCHEDULER_INTERVAL must be positive")
+	}
+	if config.Box.SweepInterval <= 0 {
+		return nil, fmt.Errorf("BOX_SWEEP_INTERVAL must be positive")
+	}
+	// a bucket holding no tokens would refuse every request
+	if limits := config.Server.RateLimit; limits.IPPerMinute > 0 && limits.IPBurst < 1 {
+		return nil, fmt.Errorf("RATE_LIMIT_IP_BURST must be at least 1")
+	}
+	if limits := config.Server.RateLimit; limits.OwnerPerMinute > 0 && limits.OwnerBurst < 1 {
+		return nil, fmt.Errorf("RATE_LIMIT_OWNER_BURST must be at least 1")
+	}
+
+	config.Server.TLS = TLSConfig{
+		CertFile:     viper.GetString("SERVER_TLS_CERT_FILE"),
+		KeyFile:      viper.GetString("SERVER_TLS_KEY_FILE"),
+		SelfSigned:   viper.GetBool("SERVER_TLS_SELF_SIGNED"),
+		RedirectPort: viper.GetString("SERVER_HTTP_REDIRECT_PORT"),
+		HSTSMaxAge:   viper.GetDuration("SERVER_HSTS_MAX_AGE"),
+	}
+	for _, host := range strings.Split(viper.GetString("SERVER_TLS_SELF_SIGNED_HOSTS"), ",") {
+		if host = strings.TrimSpace(host); host != "" {
+			config.Server.TLS.SelfSignedHosts = append(config.Server.TLS.SelfSignedHosts, host)
+		}
+	}
+	if tls := config.Server.TLS; tls.CertFile == "" {
+		if tls.KeyFile != "" || tls.SelfSigned || tls.RedirectPort != "" {
+			return nil, fmt.Errorf("SERVER_TLS_CERT_FILE is required to use TLS")
+		}
+	} else if tls.KeyFile == "" {
+		return nil, fmt.Errorf("SERVER_TLS_KEY_FILE is required with SERVER_TLS_CERT_FILE")
+	}
+	if config.Server.TLS.SelfSigned && config.Environment == "production" {
+		return nil, fmt.Errorf("SERVER_TLS_SELF_SIGNED cannot be used in production")
+	}
+
+	config.Server.AllowLocalhost = viper.GetBool("SERVER_ALLOW_LOCALHOST") || config.Environment == "development"
+	for _, origin := range strings.Split(viper.GetString("SERVER_ALLOWED_ORIGINS"), ",") {
+		if origin = strings.TrimSuffix(strings.TrimSpace(origin), "/"); origin == "" {
+			continue
+		}
+		u, err := url.Parse(origin)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || u.Path != "" {
+			return nil, fmt.Errorf("SERVER_ALLOWED_ORIGINS must be a comma-separated list of origins like https://gobox.example.com, got %q", origin)
+		}
+		config.Server.AllowedOrigins = append(config.Server.AllowedOrigins, origin)
+	}
+
+	policies, err := loadPolicies(viper.GetString("BOX_POLICY_FILE"))
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := policies[config.Box.DefaultTier]; !ok {
+		policies[config.Box.DefaultTier] = LifecyclePolicy{
+			GracePeriod:        viper.GetDuration("BOX_GRACE_PERIOD"),
+			StopAfter:          viper.GetDuration("BOX_STOP_AFTER"),
+			IdleTimeout:        viper.GetDuration("BOX_IDLE_TIMEOUT"),
+			MaxSession:         viper.GetDuration("BOX_MAX_SESSION"),
+			ExpireAfter:        viper.GetDuration("BOX_EXPIRE_AFTER"),
+			HardExpiry:         viper.GetDuration("BOX_HARD_EXPIRY"),
+			HibernateAfter:     viper.GetDuration("BOX_HIBERNATE_AFTER"),
+			HibernateRetention: viper.GetDuration("BOX_HIBERNATE_RETENTION"),
+			WarnBefore:         viper.GetDuration("BOX_WARN_BEFORE"),
+		}
+	}
+	config.Box.Policies = policies
+
+	if key := viper.GetString("BOX_SECRET_KEY"); key != "" {
+		config.Box.SecretKey, err = base64.StdEncoding.DecodeString(key)
+		if err != nil || len(config.Box.SecretKey) != 32 {
+			return nil, fmt.Errorf("BOX_SECRET_KEY must be 32 bytes encoded as base64")
+		}
+	}
+
+	if config.Box.RuntimeFallback != RuntimeFallbackRefuse && config.Box.RuntimeFallback != RuntimeFallbackDefault {
+		return nil, fmt.Errorf("BOX_RUNTIME_FALLBACK must be %q or %q", RuntimeFallbackRefuse, RuntimeFallbackDefault)
+	}
+
+	config.Box.Security = SecurityConfig{
+		Profile:     viper.GetString("BOX_SECURITY_PROFILE"),
+		PidsLimit:   viper.GetInt64("BOX_PIDS_LIMIT"),
+		UsernsRemap: viper.GetBool("BOX_USERNS_REMAP"),
+	}
+	if !validSecurityProfile(config.Box.Security.Profile) {
+		return nil, fmt.Errorf("BOX_SECURITY_PROFILE must be %q or %q", domain.SecurityStrict, domain.SecurityRootFriendly)
+	}
+	if path := viper.GetString("BOX_SECCOMP_PROFILE"); path != "" {
+		seccomp, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read seccomp profile: %w", err)
+		}
+		if !json.Valid(seccomp) {
+			return nil, fmt.Errorf("seccomp profile %s is not valid JSON", path)
+		}
+		config.Box.Security.Seccomp = string(seccomp)
+	}
+
+	config.Box.Abuse = AbuseConfig{
+		Interval:       viper.GetDuration("BOX_ABUSE_INTERVAL"),
+		CPUThreshold:   viper.GetFloat64("BOX_ABUSE_CPU_THRESHOLD"),
+		CPUSustain:     viper.GetDuration("BOX_ABUSE_CPU_SUSTAIN"),
+		MaxConnections: viper.GetInt("BOX_ABUSE_MAX_CONNECTIONS"),
+		Cooldown:       viper.GetDuration("BOX_ABUSE_COOLDOWN"),
+		Window:         viper.GetDuration("BOX_ABUSE_WINDOW"),
+		BanDuration:    viper.GetDuration("BOX_ABUSE_BAN_DURATION"),
+		HostProc:       viper.GetString("BOX_ABUSE_HOST_PROC"),
+	}
+	if config.Box.Abuse.Interval < 0 {
+		return nil, fmt.Errorf("BOX_ABUSE_INTERVAL must not be negative")
+	}
+	for _, name := range strings.Split(viper.GetString("BOX_ABUSE_MINER_NAMES"), ",") {
+		if name = strings.ToLower(strings.TrimSpace(name)); name != "" {
+			config.Box.Abuse.MinerNames = append(config.Box.Abuse.MinerNames, name)
+		}
+	}
+
+	config.Box.Pow = PowConfig{
+		Difficulty:    viper.GetInt("BOX_POW_DIFFICULTY"),
+		MaxDifficulty: viper.GetInt("BOX_POW_MAX_DIFFICULTY"),
+		TTL:           viper.GetDuration("BOX_POW_TTL"),
+	}
+	if config.Box.Pow.Difficulty < 0 || config.Box.Pow.MaxDifficulty < config.Box.Pow.Difficulty || config.Box.Pow.MaxDifficulty > 32 {
+		return nil, fmt.Errorf("BOX_POW_DIFFICULTY and BOX_POW_MAX_DIFFICULTY must satisfy 0 <= difficulty <= max <= 32")
+	}
+	config.Box.Replicas = viper.GetInt("BOX_REPLICAS")
+	if config.Box.Replicas < 1 {
+		return nil, fmt.Errorf("BOX_REPLICAS must be at least 1")
+	}
+	if secret := viper.GetString("BOX_POW_SECRET"); secret != "" {
+		config.Box.Pow.Secret, err = base64.StdEncoding.DecodeString(secret)
+		if err != nil || len(config.Box.Pow.Secret) < 32 {
+			return nil, fmt.Errorf("BOX_POW_SECRET must be at least 32 bytes encoded as base64")
+		}
+	} else if config.Box.Replicas > 1 && config.Box.Pow.Difficulty > 0 {
+		// each replica must accept the challenges the others issue
+		return nil, fmt.Errorf("BOX_POW_SECRET is required when BOX_REPLICAS is more than 1")
+	} else {
+		config.Box.Pow.Secret = make([]byte, 32)
+		if _, err := rand.Read(config.Box.Pow.Secret); err != nil {
+			return nil, fmt.Errorf("failed to generate proof-of-work secret: %w", err)
+		}
+	}
+
+	config.Box.LeaseTTL = viper.GetDuration("BOX_LEASE_TTL")
+	if config.Box.LeaseTTL < 3*time.Second {
+		return nil, fmt.Errorf("BOX_LEASE_TTL must be at least 3s")
+	}
+	config.Box.ReplicaID = viper.GetString("BOX_REPLICA_ID")
+	if config.Box.ReplicaID == "" {
+		config.Box.ReplicaID, err = os.Hostname()
+		if err != nil {
+			return nil, fmt.Errorf("BOX_REPLICA_ID is unset and the hostname is unavailable: %w", err)
+		}
+	}
+
+	config.Lab = LabConfig{
+		MaxPerOwner: viper.GetInt("LAB_MAX_PER_OWNER"),
+		TTL:         viper.GetDuration("LAB_TTL"),
+	}
+
+	config.Network.SubnetBits = viper.GetInt("NETWORK_SUBNET_BITS")
+	if config.Network.SubnetBits < 16 || config.Network.SubnetBits > 29 {
+		return nil, fmt.Errorf("NETWORK_SUBNET_BITS must be between 16 and 29")
+	}
+	for _, pool := range strings.Split(viper.GetString("NETWORK_SUBNET_POOLS"), ",") {
+		prefix, err := netip.ParsePrefix(strings.TrimSpace(pool))
+		if err != nil || !prefix.Addr().Is4() {
+			return nil, fmt.Errorf("NETWORK_SUBNET_POOLS must be a comma-separated list of IPv4 CIDR ranges")
+		}
+		config.Network.SubnetPools = append(config.Network.SubnetPools, prefix.Masked())
+	}
+
+	templates, sidecars, err := loadTemplates(viper.GetString("BOX_TEMPLATE_FILE"))
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := templates[config.Box.DefaultTemplate]; !ok {
+		templates[config.Box.DefaultTemplate] = TemplateConfig{Image: config.Box.Image}
+	}
+	for name, template := range templates {
+		if template.Security != "" && !validSecurityProfile(template.Security) {
+			return nil, fmt.Errorf("template %s: security must be %q or %q", name, domain.SecurityStrict, domain.SecurityRootFriendly)
+		}
+		// strict boxes have a read-only root filesystem, so /box must be
+		// on a volume
+		profile := template.Security
+		if profile == "" {
+			profile = config.Box.Security.Profile
+		}
+		if profile == domain.SecurityStrict && !config.Box.HomeVolumes {
+			return nil, fmt.Errorf("template %s: the %q security profile requires BOX_HOME_VOLUMES", name, domain.SecurityStrict)
+		}
+	}
+	for name, sidecar := range defaultSidecars {
+		if _, ok := sidecars[name]; !ok {
+			sidecars[name] = sidecar
+		}
+	}
+	config.Box.Templates = templates
+	config.Box.Sidecars = sidecars
 
 	return config, nil
 }
+
+func validSecurityProfile(profile string) bool {
+	return profile == domain.SecurityStrict || profile == domain.SecurityRootFriendly
+}
+
+// loadPolicies reads per-tier lifecycle policies from an optional YAML file:
+//
+//	tiers:
+//	  pro:
+//	    grace_period: 10m
+//	    idle_timeout: 2h
+func loadPolicies(path string) (map[string]LifecyclePolicy, error) {
+	policies := make(map[string]LifecyclePolicy)
+	if path == "" {
+		return policies, nil
+	}
+
+	v := viper.New()
+	v.SetConfigFile(path)
+	if err := v.ReadInConfig(); err != nil {
+		return nil, fmt.Errorf("failed to read policy file: %w", err)
+	}
+	if err := v.UnmarshalKey("tiers", &policies); err != nil {
+		return nil, fmt.Errorf("failed to parse policy file: %w", err)
+	}
+
+	return policies, nil
+}
+
+// loadTemplates reads box and sidecar templates from an optional YAML
+// file:
+//
+//	templates:
+//	  python:
+//	    image: gobox-python:latest
+//	    provision:
+//	      - apk add --no-cache python3 py3-pip
+//	  locked:
+//	    image: gobox-base:latest
+//	    security: strict
+//	sidecars:
+//	  mongo:
+//	    image: mongo:7
+func loadTemplates(path string) (map[string]TemplateConfig, map[string]SidecarConfig, error) {
+	templates := make(map[string]TemplateConfig)
+	sidecars := make(map[string]SidecarConfig)
+	if path == "" {
+		return templates, sidecars, nil
+	}
+
+	v := viper.New()
+	v.SetConfigFile(path)
+	if err := v.ReadInConfig(); err != nil {
+		return nil, nil, fmt.Errorf("failed to read template file: %w", err)
+	}
+	if err := v.UnmarshalKey("templates", &templates); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse template file: %w", err)
+	}
+	if err := v.UnmarshalKey("sidecars", &sidecars); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse template file: %w", err)
+	}
+
+	return templates, sidecars, nil
+}
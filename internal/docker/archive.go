@@ -0,0 +1,356 @@
+package docker
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/containerd/errdefs"
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/pkg/jsonmessage"
+	"github.com/faiyaz032/gobox/internal/domain"
+)
+
+const (
+	archiveManifestName = "manifest.json"
+	archiveRootfsName   = "rootfs.tar"
+	maxManifestSize     = 1 << 20 // 1MB
+)
+
+// tarDirectory writes every file under dir into tw, named relative to dir.
+func tarDirectory(tw *tar.Writer, dir string) error {
+	return filepath.Walk(dir, func(file string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		header, err := tar.FileInfoHeader(fi, file)
+		if err != nil {
+			return err
+		}
+
+		relPath, err := filepath.Rel(dir, file)
+		if err != nil {
+			return err
+		}
+
+		header.Name = relPath
+
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+
+		if fi.Mode().IsRegular() {
+			f, err := os.Open(file)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+
+			if _, err := io.Copy(tw, f); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// tarFile writes a single regular file entry into tw.
+func tarFile(tw *tar.Writer, name string, r io.Reader, size int64) error {
+	if err := tw.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     name,
+		Mode:     0644,
+		Size:     size,
+		ModTime:  time.Now(),
+	}); err != nil {
+		return err
+	}
+
+	_, err := io.Copy(tw, r)
+	return err
+}
+
+// spool copies r into a temporary file, refusing more than maxBytes, and
+// returns the rewound file with its size and SHA-256 digest. The caller
+// removes the file with discardSpool.
+func spool(r io.Reader, maxBytes int64) (*os.File, int64, string, error) {
+	f, err := os.CreateTemp("", "gobox-archive-*")
+	if err != nil {
+		return nil, 0, "", domain.NewInternalError("failed to create temporary file", err)
+	}
+
+	hash := sha256.New()
+	n, err := io.Copy(io.MultiWriter(f, hash), io.LimitReader(r, maxBytes+1))
+	if err != nil {
+		discardSpool(f)
+		return nil, 0, "", domain.NewInternalError("failed to spool archive", err)
+	}
+	if n > maxBytes {
+		discardSpool(f)
+		return nil, 0, "", domain.NewValidationError(fmt.Sprintf("box filesystem exceeds the archive limit of %d bytes", maxBytes))
+	}
+
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		discardSpool(f)
+		return nil, 0, "", domain.NewInternalError("failed to rewind spooled archive", err)
+	}
+	return f, n, hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+func discardSpool(f *os.File) {
+	_ = f.Close()
+	_ = os.Remove(f.Name())
+}
+
+// ExportArchive writes the container's filesystem to w as a tar.gz holding
+// manifest.json and rootfs.tar. The filesystem is spooled to disk first so
+// the manifest can carry its checksum and nothing is written to w if the
+// export fails or exceeds maxBytes.
+func (s *Svc) ExportArchive(ctx context.Context, containerID string, manifest domain.ArchiveManifest, w io.Writer, maxBytes int64) error {
+	inspect, err := s.client.ContainerInspect(ctx, containerID)
+	if err != nil {
+		if errdefs.IsNotFound(err) {
+			return domain.NewNotFoundError("container", containerID)
+		}
+		return domain.NewDockerError("inspect container", err)
+	}
+	// the image's variables only: the container's also hold the owner's,
+	// secrets included, which an import gets again from the database
+	env, err := s.baseEnv(ctx, inspect.Image)
+	if err != nil {
+		return err
+	}
+	manifest.Env = env
+	if inspect.Config != nil {
+		manifest.User = inspect.Config.User
+		manifest.WorkingDir = inspect.Config.WorkingDir
+		manifest.Cmd = inspect.Config.Cmd
+	}
+
+	rc, err := s.client.ContainerExport(ctx, containerID)
+	if err != nil {
+		return domain.NewDockerError("export container", err)
+	}
+	defer rc.Close()
+
+	rootfs, size, sum, err := spool(rc, maxBytes)
+	if err != nil {
+		return err
+	}
+	defer discardSpool(rootfs)
+
+	manifest.RootfsSize = size
+	manifest.RootfsSHA256 = sum
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return domain.NewInternalError("failed to encode archive manifest", err)
+	}
+
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	if err := tarFile(tw, archiveManifestName, bytes.NewReader(data), int64(len(data))); err != nil {
+		return domain.NewInternalError("failed to write archive manifest", err)
+	}
+	if err := tarFile(tw, archiveRootfsName, rootfs, size); err != nil {
+		return domain.NewInternalError("failed to write archive filesystem", err)
+	}
+	if err := tw.Close(); err != nil {
+		return domain.NewInternalError("failed to finish archive", err)
+	}
+	if err := gz.Close(); err != nil {
+		return domain.NewInternalError("failed to finish archive", err)
+	}
+	return nil
+}
+
+// ImportArchive verifies an archive written by ExportArchive and imports
+// its filesystem as the image ref, restoring the recorded image config.
+func (s *Svc) ImportArchive(ctx context.Context, r io.Reader, ref string, labels map[string]string, maxBytes int64) (*domain.ArchiveManifest, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, domain.NewValidationError("archive is not gzip-compressed")
+	}
+	defer gz.Close()
+
+	var (
+		manifest *domain.ArchiveManifest
+		rootfs   *os.File
+		size     int64
+		sum      string
+	)
+	defer func() {
+		if rootfs != nil {
+			discardSpool(rootfs)
+		}
+	}()
+
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, domain.NewValidationError("archive is not a valid tar file")
+		}
+
+		switch header.Name {
+		case archiveManifestName:
+			manifest = &domain.ArchiveManifest{}
+			if err := json.NewDecoder(io.LimitReader(tr, maxManifestSize)).Decode(manifest); err != nil {
+				return nil, domain.NewValidationError("archive manifest is not valid JSON")
+			}
+		case archiveRootfsName:
+			if rootfs != nil {
+				return nil, domain.NewValidationError("archive contains more than one " + archiveRootfsName)
+			}
+			rootfs, size, sum, err = spool(tr, maxBytes)
+			if err != nil {
+				return nil, err
+			}
+		default:
+			return nil, domain.NewValidationError(fmt.Sprintf("unexpected file %q in archive", header.Name))
+		}
+	}
+
+	if manifest == nil || rootfs == nil {
+		return nil, domain.NewValidationError("archive must contain " + archiveManifestName + " and " + archiveRootfsName)
+	}
+	if manifest.Version != domain.ArchiveVersion {
+		return nil, domain.NewValidationError(fmt.Sprintf("unsupported archive version %d", manifest.Version))
+	}
+	if strings.ContainsAny(manifest.User+manifest.WorkingDir+strings.Join(manifest.Env, "")+strings.Join(manifest.Cmd, ""), "\r\n") {
+		return nil, domain.NewValidationError("archive manifest contains line breaks in its image config")
+	}
+	if size != manifest.RootfsSize || sum != manifest.RootfsSHA256 {
+		return nil, domain.NewValidationError("archive checksum does not match its manifest")
+	}
+
+	resp, err := s.client.ImageImport(ctx, image.ImportSource{
+		Source:     rootfs,
+		SourceName: "-",
+	}, ref, image.ImportOptions{
+		Message: "imported by gobox",
+		Changes: importChanges(manifest, labels),
+	})
+	if err != nil {
+		return nil, domain.NewDockerError("import image", err)
+	}
+	defer resp.Close()
+
+	if err := jsonmessage.DisplayJSONMessagesStream(resp, io.Discard, 0, false, nil); err != nil {
+		return nil, domain.NewDockerError("import image", err)
+	}
+	return manifest, nil
+}
+
+// FlattenContainer saves the container's filesystem as a single-layer
+// image tagged ref and returns the image ID. Unlike CommitContainer it does
+// not stack on the container's image, so boxes committed again and again
+// do not grow their layer chain. The image config is restored as for an
+// imported archive, with the environment of the container's image.
+func (s *Svc) FlattenContainer(ctx context.Context, containerID, ref string, labels map[string]string) (string, error) {
+	inspect, err := s.client.ContainerInspect(ctx, containerID)
+	if err != nil {
+		if errdefs.IsNotFound(err) {
+			return "", domain.NewNotFoundError("container", containerID)
+		}
+		return "", domain.NewDockerError("inspect container", err)
+	}
+	env, err := s.baseEnv(ctx, inspect.Image)
+	if err != nil {
+		return "", err
+	}
+	config := &domain.ArchiveManifest{Env: env}
+	if inspect.Config != nil {
+		config.User = inspect.Config.User
+		config.WorkingDir = inspect.Config.WorkingDir
+		config.Cmd = inspect.Config.Cmd
+	}
+
+	// freeze a running container so the export is consistent, as a commit
+	// does
+	if inspect.State != nil && inspect.State.Running && !inspect.State.Paused {
+		if err := s.client.ContainerPause(ctx, containerID); err != nil {
+			return "", domain.NewDockerError("pause container", err)
+		}
+		defer func() {
+			_ = s.client.ContainerUnpause(context.Background(), containerID)
+		}()
+	}
+
+	rc, err := s.client.ContainerExport(ctx, containerID)
+	if err != nil {
+		return "", domain.NewDockerError("export container", err)
+	}
+	defer rc.Close()
+
+	changes := importChanges(config, labels)
+	if inspect.Config != nil && len(inspect.Config.Entrypoint) > 0 {
+		if entrypoint, err := json.Marshal(inspect.Config.Entrypoint); err == nil {
+			changes = append(changes, "ENTRYPOINT "+string(entrypoint))
+		}
+	}
+	resp, err := s.client.ImageImport(ctx, image.ImportSource{
+		Source:     rc,
+		SourceName: "-",
+	}, ref, image.ImportOptions{
+		Message: "flattened by gobox",
+		Changes: changes,
+	})
+	if err != nil {
+		return "", domain.NewDockerError("import image", err)
+	}
+	defer resp.Close()
+
+	if err := jsonmessage.DisplayJSONMessagesStream(resp, io.Discard, 0, false, nil); err != nil {
+		return "", domain.NewDockerError("import image", err)
+	}
+
+	img, err := s.client.ImageInspect(ctx, ref)
+	if err != nil {
+		return "", domain.NewDockerError("inspect image", err)
+	}
+	return img.ID, nil
+}
+
+// importChanges turns the manifest's image config into Dockerfile
+// instructions for ImageImport.
+func importChanges(manifest *domain.ArchiveManifest, labels map[string]string) []string {
+	var changes []string
+	for k, v := range labels {
+		changes = append(changes, fmt.Sprintf("LABEL %s=%q", k, v))
+	}
+	for _, env := range manifest.Env {
+		k, v, ok := strings.Cut(env, "=")
+		if !ok || k == "" {
+			continue
+		}
+		changes = append(changes, fmt.Sprintf("ENV %s=%q", k, v))
+	}
+	if manifest.User != "" {
+		changes = append(changes, "USER "+manifest.User)
+	}
+	if manifest.WorkingDir != "" {
+		changes = append(changes, "WORKDIR "+manifest.WorkingDir)
+	}
+	if len(manifest.Cmd) > 0 {
+		if cmd, err := json.Marshal(manifest.Cmd); err == nil {
+			changes = append(changes, "CMD "+string(cmd))
+		}
+	}
+	return changes
+}
@@ -0,0 +1,103 @@
+package docker
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/containerd/errdefs"
+	"github.com/docker/docker/api/types/container"
+	"github.com/faiyaz032/gobox/internal/domain"
+)
+
+// CopyFiles writes files into a container, owned by the container's user.
+// Missing parent directories below /box are created with the same owner.
+func (s *Svc) CopyFiles(ctx context.Context, containerID string, files []domain.SpecFile) error {
+	if len(files) == 0 {
+		return nil
+	}
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	dirs := make(map[string]bool)
+	now := time.Now()
+
+	for _, f := range files {
+		name := strings.TrimPrefix(path.Clean(f.Path), "/")
+		var parents []string
+		for dir := path.Dir(name); dir != "box" && dir != "." && !dirs[dir]; dir = path.Dir(dir) {
+			dirs[dir] = true
+			parents = append(parents, dir)
+		}
+		for i := len(parents) - 1; i >= 0; i-- {
+			dir := parents[i]
+			if err := tw.WriteHeader(&tar.Header{
+				Typeflag: tar.TypeDir,
+				Name:     dir + "/",
+				Mode:     0755,
+				ModTime:  now,
+			}); err != nil {
+				return domain.NewInternalError("failed to write files archive", err)
+			}
+		}
+
+		mode := int64(0644)
+		if f.Mode != "" {
+			m, err := strconv.ParseInt(f.Mode, 8, 32)
+			if err != nil {
+				return domain.NewValidationError("invalid file mode " + f.Mode)
+			}
+			mode = m
+		}
+		if err := tw.WriteHeader(&tar.Header{
+			Typeflag: tar.TypeReg,
+			Name:     name,
+			Mode:     mode,
+			Size:     int64(len(f.Content)),
+			ModTime:  now,
+		}); err != nil {
+			return domain.NewInternalError("failed to write files archive", err)
+		}
+		if _, err := tw.Write([]byte(f.Content)); err != nil {
+			return domain.NewInternalError("failed to write files archive", err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return domain.NewInternalError("failed to write files archive", err)
+	}
+
+	return s.CopyArchive(ctx, containerID, "/", &buf)
+}
+
+// CopyArchive extracts a tar archive into dir of a container, owned by the
+// container's user. The container may be stopped.
+func (s *Svc) CopyArchive(ctx context.Context, containerID, dir string, r io.Reader) error {
+	if err := s.client.CopyToContainer(ctx, containerID, dir, r, container.CopyToContainerOptions{
+		CopyUIDGID: true,
+	}); err != nil {
+		if errdefs.IsNotFound(err) {
+			return domain.NewNotFoundError("container", containerID)
+		}
+		return domain.NewDockerError("copy to container", err)
+	}
+	return nil
+}
+
+// startupEntrypoint wraps the container command in a shell that runs the
+// startup commands first. A failing command is reported but does not keep
+// the shell from starting.
+func startupEntrypoint(commands []string) []string {
+	var script strings.Builder
+	for _, cmd := range commands {
+		script.WriteString("{\n")
+		script.WriteString(cmd)
+		script.WriteString("\n} || echo \"gobox: startup command failed: $?\" >&2\n")
+	}
+	script.WriteString(`exec "$@"`)
+	return []string{"/bin/sh", "-c", script.String(), "gobox-startup"}
+}
@@ -0,0 +1,227 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+	"strings"
+	"time"
+
+	"github.com/containerd/errdefs"
+	"github.com/docker/docker/api/types/network"
+	"github.com/faiyaz032/gobox/internal/domain"
+)
+
+// staleAllocationAge is how old an allocation without a network must be
+// before it is released at startup; younger ones may belong to a network
+// another replica is still creating.
+const staleAllocationAge = 5 * time.Minute
+
+// SubnetStore persists subnet allocations so they survive restarts.
+type SubnetStore interface {
+	Create(ctx context.Context, allocation domain.SubnetAllocation) error
+	List(ctx context.Context) ([]domain.SubnetAllocation, error)
+	Delete(ctx context.Context, network string) error
+}
+
+// IPAM carves subnets for GoBox's networks out of configured parent
+// ranges.
+type IPAM struct {
+	store SubnetStore
+	pools []netip.Prefix
+	bits  int
+}
+
+// NewIPAM returns an allocator handing out subnets from pools; bits is the
+// prefix length used when a caller does not ask for one.
+func NewIPAM(store SubnetStore, pools []netip.Prefix, bits int) *IPAM {
+	return &IPAM{
+		store: store,
+		pools: pools,
+		bits:  bits,
+	}
+}
+
+// UseIPAM makes the service allocate the subnets of the networks it
+// creates from ipam.
+func (s *Svc) UseIPAM(ipam *IPAM) {
+	s.ipam = ipam
+}
+
+// CreateSubnetNetwork creates an internal bridge network on a free subnet
+// of the given prefix length, zero meaning the configured default, and
+// returns the subnet.
+func (s *Svc) CreateSubnetNetwork(ctx context.Context, name string, bits int, labels map[string]string) (netip.Prefix, error) {
+	return s.createSubnetNetwork(ctx, name, bits, true, labels)
+}
+
+// createSubnetNetwork creates a bridge network on a free subnet; an
+// internal one has no route to the outside world.
+func (s *Svc) createSubnetNetwork(ctx context.Context, name string, bits int, internal bool, labels map[string]string) (netip.Prefix, error) {
+	if s.ipam == nil {
+		return netip.Prefix{}, domain.NewInternalError("no subnet allocator configured", nil)
+	}
+	if bits == 0 {
+		bits = s.ipam.bits
+	}
+	if bits > 30 {
+		return netip.Prefix{}, domain.NewValidationError(fmt.Sprintf("a /%d subnet is too small", bits))
+	}
+
+	if _, err := s.client.NetworkInspect(ctx, name, network.InspectOptions{}); err == nil {
+		return netip.Prefix{}, domain.NewConflictError(fmt.Sprintf("network %s already exists", name))
+	} else if !errdefs.IsNotFound(err) {
+		return netip.Prefix{}, domain.NewDockerError("inspect network", err)
+	}
+
+	// a network created outside GoBox may take the subnet between the
+	// listing and the create
+	for attempt := 0; ; attempt++ {
+		subnet, err := s.allocateSubnet(ctx, name, bits)
+		if err != nil {
+			return netip.Prefix{}, err
+		}
+
+		_, err = s.client.NetworkCreate(ctx, name, network.CreateOptions{
+			Driver:   "bridge",
+			Internal: internal,
+			Labels:   labels,
+			IPAM: &network.IPAM{
+				Driver: "default",
+				Config: []network.IPAMConfig{{
+					Subnet:  subnet.String(),
+					Gateway: domain.SubnetGateway(subnet).String(),
+				}},
+			},
+		})
+		if err == nil {
+			return subnet, nil
+		}
+
+		s.releaseSubnet(ctx, name)
+		if strings.Contains(err.Error(), "overlaps") && attempt < 2 {
+			continue
+		}
+		if errdefs.IsConflict(err) {
+			return netip.Prefix{}, domain.NewConflictError(fmt.Sprintf("network %s already exists", name))
+		}
+		return netip.Prefix{}, domain.NewDockerError("create network", err)
+	}
+}
+
+// allocateSubnet records the first subnet of the prefix length that is
+// neither allocated nor used by a Docker network. The network must not
+// exist yet, so an allocation left under its name is stale and replaced.
+func (s *Svc) allocateSubnet(ctx context.Context, name string, bits int) (netip.Prefix, error) {
+	allocations, err := s.ipam.store.List(ctx)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	used, err := s.usedSubnets(ctx)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	for _, a := range allocations {
+		if a.Network == name {
+			if err := s.ipam.store.Delete(ctx, name); err != nil {
+				return netip.Prefix{}, err
+			}
+			continue
+		}
+		used = append(used, a.Subnet)
+	}
+
+	for _, pool := range s.ipam.pools {
+		if bits < pool.Bits() {
+			continue
+		}
+		for i := range 1 << (bits - pool.Bits()) {
+			subnet := domain.NthSubnet(pool, bits, i)
+			if overlapsAny(subnet, used) {
+				continue
+			}
+
+			err := s.ipam.store.Create(ctx, domain.SubnetAllocation{Network: name, Subnet: subnet})
+			if err == nil {
+				return subnet, nil
+			}
+			// allocated concurrently, possibly by another replica
+			if appErr, ok := domain.IsAppError(err); ok && appErr.IsType(domain.ErrorTypeConflict) {
+				used = append(used, subnet)
+				continue
+			}
+			return netip.Prefix{}, err
+		}
+	}
+
+	return netip.Prefix{}, domain.NewConflictError(fmt.Sprintf("no free /%d subnet left in the configured pools", bits))
+}
+
+// releaseSubnet frees the subnet allocated to a network, if any.
+func (s *Svc) releaseSubnet(ctx context.Context, name string) {
+	if s.ipam == nil {
+		return
+	}
+	_ = s.ipam.store.Delete(ctx, name)
+}
+
+// ReleaseStaleSubnets frees allocations whose network no longer exists,
+// e.g. after a crash between allocating and removing. It returns how many
+// it released.
+func (s *Svc) ReleaseStaleSubnets(ctx context.Context) (int, error) {
+	if s.ipam == nil {
+		return 0, nil
+	}
+
+	allocations, err := s.ipam.store.List(ctx)
+	if err != nil {
+		return 0, err
+	}
+	networks, err := s.client.NetworkList(ctx, network.ListOptions{})
+	if err != nil {
+		return 0, domain.NewDockerError("list networks", err)
+	}
+	existing := make(map[string]bool, len(networks))
+	for _, nw := range networks {
+		existing[nw.Name] = true
+	}
+
+	released := 0
+	for _, a := range allocations {
+		if existing[a.Network] || time.Since(a.AllocatedAt) < staleAllocationAge {
+			continue
+		}
+		if err := s.ipam.store.Delete(ctx, a.Network); err != nil {
+			return released, err
+		}
+		released++
+	}
+	return released, nil
+}
+
+// usedSubnets lists the IPv4 subnets of every Docker network.
+func (s *Svc) usedSubnets(ctx context.Context) ([]netip.Prefix, error) {
+	networks, err := s.client.NetworkList(ctx, network.ListOptions{})
+	if err != nil {
+		return nil, domain.NewDockerError("list networks", err)
+	}
+
+	var used []netip.Prefix
+	for _, nw := range networks {
+		for _, cfg := range nw.IPAM.Config {
+			if p, err := netip.ParsePrefix(cfg.Subnet); err == nil && p.Addr().Is4() {
+				used = append(used, p.Masked())
+			}
+		}
+	}
+	return used, nil
+}
+
+func overlapsAny(p netip.Prefix, others []netip.Prefix) bool {
+	for _, o := range others {
+		if p.Overlaps(o) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,104 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/containerd/errdefs"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/network"
+	"github.com/faiyaz032/gobox/internal/domain"
+	"github.com/google/uuid"
+)
+
+// CreateLabNode creates a lab node attached to its networks with static
+// addresses and starts it. Nodes are hardened like boxes of their template
+// but keep raw sockets for ping and traceroute; only routers may change
+// routes and they also forward packets between their interfaces.
+func (s *Svc) CreateLabNode(ctx context.Context, opts domain.LabNodeOptions) (string, error) {
+	var networkMode container.NetworkMode
+	endpoints := make(map[string]*network.EndpointSettings, len(opts.Addresses))
+	for name, addr := range opts.Addresses {
+		if networkMode == "" {
+			networkMode = container.NetworkMode(name)
+		}
+		endpoints[name] = &network.EndpointSettings{
+			IPAMConfig: &network.EndpointIPAMConfig{IPv4Address: addr.String()},
+			Aliases:    []string{opts.Hostname},
+		}
+	}
+	if networkMode == "" {
+		networkMode = network.NetworkNone
+	}
+
+	hostConfig := &container.HostConfig{
+		NetworkMode: networkMode,
+		Resources: container.Resources{
+			Memory:     opts.Resources.MemoryBytes,
+			MemorySwap: opts.Resources.MemoryBytes,
+			NanoCPUs:   opts.Resources.NanoCPUs,
+			CPUShares:  512,
+		},
+		StorageOpt: map[string]string{
+			"size": opts.Resources.StorageSize,
+		},
+	}
+	applySecurity(hostConfig, opts.Security, opts.Runtime)
+	hostConfig.CapAdd = append(hostConfig.CapAdd, "NET_RAW")
+	if opts.Router {
+		hostConfig.CapAdd = append(hostConfig.CapAdd, "NET_ADMIN")
+		hostConfig.Sysctls = map[string]string{"net.ipv4.ip_forward": "1"}
+	}
+
+	containerName := fmt.Sprintf("box-lab-%s", uuid.New().String())
+	resp, err := s.client.ContainerCreate(ctx, &container.Config{
+		Image:     opts.Image,
+		Cmd:       []string{"bash"},
+		Tty:       true,
+		OpenStdin: true,
+		Hostname:  opts.Hostname,
+		Labels:    opts.Labels,
+	}, hostConfig, &network.NetworkingConfig{
+		EndpointsConfig: endpoints,
+	}, nil, containerName)
+	if err != nil {
+		if errdefs.IsNotFound(err) {
+			return "", domain.NewNotFoundError("image", opts.Image)
+		}
+		return "", domain.NewDockerError("create lab node", err)
+	}
+
+	if err := s.client.ContainerStart(ctx, resp.ID, container.StartOptions{}); err != nil {
+		return resp.ID, domain.NewDockerError("start lab node", err)
+	}
+	return resp.ID, nil
+}
+
+// RemoveByLabel removes every container, then every network, carrying the
+// label, e.g. all parts of a lab including ones left half-created.
+func (s *Svc) RemoveByLabel(ctx context.Context, label string) error {
+	args := filters.NewArgs(filters.Arg("label", label))
+
+	containers, err := s.client.ContainerList(ctx, container.ListOptions{All: true, Filters: args})
+	if err != nil {
+		return domain.NewDockerError("list containers", err)
+	}
+	for _, c := range containers {
+		if err := s.client.ContainerRemove(ctx, c.ID, container.RemoveOptions{RemoveVolumes: true, Force: true}); err != nil && !errdefs.IsNotFound(err) {
+			return domain.NewDockerError("remove container", err)
+		}
+	}
+
+	networks, err := s.client.NetworkList(ctx, network.ListOptions{Filters: args})
+	if err != nil {
+		return domain.NewDockerError("list networks", err)
+	}
+	for _, nw := range networks {
+		if err := s.client.NetworkRemove(ctx, nw.ID); err != nil && !errdefs.IsNotFound(err) {
+			return domain.NewDockerError("remove network", err)
+		}
+		s.releaseSubnet(ctx, nw.Name)
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package docker
+
+import (
+	"context"
+
+	"github.com/containerd/errdefs"
+	"github.com/faiyaz032/gobox/internal/domain"
+)
+
+// Runtimes lists the OCI runtimes registered with the daemon.
+func (s *Svc) Runtimes(ctx context.Context) (map[string]bool, error) {
+	info, err := s.client.Info(ctx)
+	if err != nil {
+		return nil, domain.NewDockerError("get docker info", err)
+	}
+
+	runtimes := make(map[string]bool, len(info.Runtimes))
+	for name := range info.Runtimes {
+		runtimes[name] = true
+	}
+	return runtimes, nil
+}
+
+// ContainerRuntime returns the OCI runtime a container runs under.
+func (s *Svc) ContainerRuntime(ctx context.Context, containerID string) (string, error) {
+	inspect, err := s.client.ContainerInspect(ctx, containerID)
+	if err != nil {
+		if errdefs.IsNotFound(err) {
+			return "", domain.NewNotFoundError("container", containerID)
+		}
+		return "", domain.NewDockerError("inspect container", err)
+	}
+	if inspect.HostConfig.Runtime != "" {
+		return inspect.HostConfig.Runtime, nil
+	}
+
+	// containers created without one run under the daemon's default
+	info, err := s.client.Info(ctx)
+	if err != nil {
+		return "", domain.NewDockerError("get docker info", err)
+	}
+	return info.DefaultRuntime, nil
+}
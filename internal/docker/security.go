@@ -0,0 +1,63 @@
+package docker
+
+import (
+	"context"
+	"slices"
+	"strings"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/faiyaz032/gobox/internal/domain"
+)
+
+// strictCapabilities are all a strict box keeps, enough for root in
+// provisioning scripts and dotfile copies to manage files and drop to the
+// box user.
+var strictCapabilities = []string{"CHOWN", "DAC_OVERRIDE", "FOWNER", "KILL", "SETGID", "SETUID"}
+
+// rootFriendlyDrops are removed from Docker's default capability set:
+// raw sockets, device nodes and file capabilities are not needed in a
+// shell and widen the kernel attack surface.
+var rootFriendlyDrops = []string{"AUDIT_WRITE", "MKNOD", "NET_RAW", "SETFCAP", "SYS_CHROOT"}
+
+// applySecurity hardens a container's host config according to its
+// profile. Every container GoBox creates goes through it.
+func applySecurity(hc *container.HostConfig, sec domain.SecurityOptions, runtime string) {
+	hc.Runtime = runtime
+	if sec.Seccomp != "" {
+		hc.SecurityOpt = append(hc.SecurityOpt, "seccomp="+sec.Seccomp)
+	}
+	if sec.PidsLimit > 0 {
+		limit := sec.PidsLimit
+		hc.PidsLimit = &limit
+	}
+
+	switch sec.Profile {
+	case domain.SecurityStrict:
+		hc.CapDrop = []string{"ALL"}
+		hc.CapAdd = slices.Clone(strictCapabilities)
+		hc.SecurityOpt = append(hc.SecurityOpt, "no-new-privileges:true")
+		hc.ReadonlyRootfs = true
+		hc.Tmpfs = map[string]string{
+			"/tmp":     "rw,nosuid,size=64m",
+			"/var/tmp": "rw,nosuid,size=64m",
+			"/run":     "rw,nosuid,nodev,size=16m",
+		}
+	case domain.SecurityRootFriendly:
+		hc.CapDrop = slices.Clone(rootFriendlyDrops)
+	}
+}
+
+// UsernsRemapEnabled reports whether the daemon runs containers in a
+// remapped user namespace.
+func (s *Svc) UsernsRemapEnabled(ctx context.Context) (bool, error) {
+	info, err := s.client.Info(ctx)
+	if err != nil {
+		return false, domain.NewDockerError("get docker info", err)
+	}
+	for _, opt := range info.SecurityOptions {
+		if strings.Contains(opt, "name=userns") {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,131 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/containerd/errdefs"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/api/types/network"
+	"github.com/faiyaz032/gobox/internal/domain"
+	"github.com/google/uuid"
+)
+
+// EnsurePrivateNetwork creates an internal bridge network on a subnet of
+// its own unless one with the name exists. Members reach each other but
+// not the outside world.
+func (s *Svc) EnsurePrivateNetwork(ctx context.Context, name string, labels map[string]string) error {
+	return s.ensureSubnetNetwork(ctx, name, true, labels)
+}
+
+// EnsureEgressNetwork creates a bridge network on a subnet of its own
+// unless one with the name exists. Unlike a private network it routes to
+// the outside world, so a box can have outbound access without sharing a
+// network with other boxes.
+func (s *Svc) EnsureEgressNetwork(ctx context.Context, name string, labels map[string]string) error {
+	return s.ensureSubnetNetwork(ctx, name, false, labels)
+}
+
+func (s *Svc) ensureSubnetNetwork(ctx context.Context, name string, internal bool, labels map[string]string) error {
+	_, err := s.client.NetworkInspect(ctx, name, network.InspectOptions{})
+	if err == nil {
+		return nil
+	}
+	if !errdefs.IsNotFound(err) {
+		return domain.NewDockerError("inspect network", err)
+	}
+
+	if _, err := s.createSubnetNetwork(ctx, name, 0, internal, labels); err != nil {
+		// created concurrently by another connect
+		if appErr, ok := domain.IsAppError(err); ok && appErr.IsType(domain.ErrorTypeConflict) {
+			if _, err := s.client.NetworkInspect(ctx, name, network.InspectOptions{}); err == nil {
+				return nil
+			}
+		}
+		return err
+	}
+	return nil
+}
+
+// RemoveNetwork removes a network once its containers are gone and
+// releases its subnet.
+func (s *Svc) RemoveNetwork(ctx context.Context, name string) error {
+	if err := s.client.NetworkRemove(ctx, name); err != nil {
+		if errdefs.IsNotFound(err) {
+			s.releaseSubnet(ctx, name)
+			return domain.NewNotFoundError("network", name)
+		}
+		if errdefs.IsConflict(err) || errdefs.IsPermissionDenied(err) {
+			return domain.NewConflictError("network is still in use")
+		}
+		return domain.NewDockerError("remove network", err)
+	}
+	s.releaseSubnet(ctx, name)
+	return nil
+}
+
+// CreateSidecar creates a service container on a box's private network,
+// reachable there by its alias. The container is left stopped.
+func (s *Svc) CreateSidecar(ctx context.Context, opts domain.SidecarOptions) (string, error) {
+	// sidecar images come from a registry rather than being built here
+	if _, err := s.client.ImageInspect(ctx, opts.Image); err != nil {
+		if !errdefs.IsNotFound(err) {
+			return "", domain.NewDockerError("inspect image", err)
+		}
+		if err := s.pullImage(ctx, opts.Image); err != nil {
+			return "", err
+		}
+	}
+
+	hostConfig := &container.HostConfig{
+		NetworkMode: container.NetworkMode(opts.Network),
+		Resources: container.Resources{
+			Memory:     opts.Resources.MemoryBytes,
+			MemorySwap: opts.Resources.MemoryBytes,
+			NanoCPUs:   opts.Resources.NanoCPUs,
+			CPUShares:  512,
+		},
+		StorageOpt: map[string]string{
+			"size": opts.Resources.StorageSize,
+		},
+	}
+	applySecurity(hostConfig, opts.Security, opts.Runtime)
+
+	containerName := fmt.Sprintf("box-sidecar-%s", uuid.New().String())
+	resp, err := s.client.ContainerCreate(ctx, &container.Config{
+		Image:    opts.Image,
+		Hostname: opts.Alias,
+		Env:      opts.Env,
+		Labels:   opts.Labels,
+	}, hostConfig, &network.NetworkingConfig{
+		EndpointsConfig: map[string]*network.EndpointSettings{
+			opts.Network: {Aliases: []string{opts.Alias}},
+		},
+	}, nil, containerName)
+	if err != nil {
+		if errdefs.IsNotFound(err) {
+			return "", domain.NewNotFoundError("image", opts.Image)
+		}
+		return "", domain.NewDockerError("create sidecar", err)
+	}
+	return resp.ID, nil
+}
+
+func (s *Svc) pullImage(ctx context.Context, ref string) error {
+	rc, err := s.client.ImagePull(ctx, ref, image.PullOptions{})
+	if err != nil {
+		if errdefs.IsNotFound(err) {
+			return domain.NewNotFoundError("image", ref)
+		}
+		return domain.NewDockerError("pull image", err)
+	}
+	defer rc.Close()
+
+	// the pull only completes once its progress stream is drained
+	if _, err := io.Copy(io.Discard, rc); err != nil {
+		return domain.NewDockerError("pull image", err)
+	}
+	return nil
+}
@@ -7,19 +7,29 @@ import (
 	"fmt"
 	"io"
 	"os"
-	"path/filepath"
+	"path"
+	"strconv"
+	"strings"
 
 	"github.com/containerd/errdefs"
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/api/types/mount"
 	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/api/types/volume"
 	"github.com/docker/docker/client"
+	"github.com/docker/go-connections/nat"
 	"github.com/faiyaz032/gobox/internal/domain"
 	"github.com/google/uuid"
 )
 
 type Svc struct {
 	client *client.Client
+	ipam   *IPAM
+	// hostProc is where the host's /proc is mounted.
+	hostProc string
 }
 
 func NewSvc() (*Svc, error) {
@@ -27,7 +37,13 @@ func NewSvc() (*Svc, error) {
 	if err != nil {
 		return nil, domain.NewDockerError("initialize docker client", err)
 	}
-	return &Svc{client: cli}, nil
+	return &Svc{client: cli, hostProc: "/proc"}, nil
+}
+
+// UseHostProc makes the service read container processes from the host's
+// /proc mounted at path, for when the server itself runs in a container.
+func (s *Svc) UseHostProc(path string) {
+	s.hostProc = path
 }
 
 func (s *Svc) Close() error {
@@ -52,76 +68,12 @@ func (s *Svc) EnsureImage(ctx context.Context, imageName, dockerfilePath string)
 	return nil
 }
 
-func (s *Svc) EnsureNetwork(ctx context.Context, networkName, subnet string) (string, error) {
-	nwList, err := s.client.NetworkList(ctx, network.ListOptions{})
-	if err != nil {
-		return "", domain.NewDockerError("list networks", err)
-	}
-
-	for _, nw := range nwList {
-		if nw.Name == networkName {
-			return nw.ID, nil
-		}
-	}
-
-	resp, err := s.client.NetworkCreate(ctx, networkName, network.CreateOptions{
-		Driver: "bridge",
-		IPAM: &network.IPAM{
-			Driver: "default",
-			Config: []network.IPAMConfig{
-				{
-					Subnet: subnet,
-				},
-			},
-		},
-	})
-	if err != nil {
-		return "", domain.NewDockerError("create network", err)
-	}
-	return resp.ID, nil
-}
-
 func (s *Svc) BuildBaseImage(ctx context.Context, contextDir string, imageName string) error {
 	buf := new(bytes.Buffer)
 	tw := tar.NewWriter(buf)
 	defer tw.Close()
 
-	err := filepath.Walk(contextDir, func(file string, fi os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
-
-		header, err := tar.FileInfoHeader(fi, file)
-		if err != nil {
-			return err
-		}
-
-		relPath, err := filepath.Rel(contextDir, file)
-		if err != nil {
-			return err
-		}
-
-		header.Name = relPath
-
-		if err := tw.WriteHeader(header); err != nil {
-			return err
-		}
-
-		if fi.Mode().IsRegular() {
-			f, err := os.Open(file)
-			if err != nil {
-				return err
-			}
-			defer f.Close()
-
-			if _, err := io.Copy(tw, f); err != nil {
-				return err
-			}
-		}
-
-		return nil
-	})
-
+	err := tarDirectory(tw, contextDir)
 	if err != nil {
 		return domain.NewDockerError("prepare build context", err)
 	}
@@ -143,35 +95,84 @@ func (s *Svc) BuildBaseImage(ctx context.Context, contextDir string, imageName s
 	return nil
 }
 
-func (s *Svc) CreateContainer(ctx context.Context) (string, error) {
-	// generate container name
-	containerName := fmt.Sprintf("box-%s", uuid.New().String())
-	// create container with resource limits
-	resp, err := s.client.ContainerCreate(ctx, &container.Config{
-		Image:     "gobox-base:latest",
-		Cmd:       []string{"bash"},
-		Tty:       true,
-		OpenStdin: true,
-		Hostname:  "box",
-	}, &container.HostConfig{
-		AutoRemove: false,
+func (s *Svc) CreateContainer(ctx context.Context, opts domain.ContainerOptions) (string, error) {
+	var mounts []mount.Mount
+	if opts.HomeVolume != "" {
+		mounts = append(mounts, mount.Mount{
+			Type:   mount.TypeVolume,
+			Source: opts.HomeVolume,
+			Target: "/box",
+		})
+	}
+
+	var entrypoint []string
+	if len(opts.Startup) > 0 {
+		entrypoint = startupEntrypoint(opts.Startup)
+	}
+
+	exposed := nat.PortSet{}
+	bindings := nat.PortMap{}
+	for _, p := range opts.Ports {
+		port, err := nat.NewPort(p.Protocol, strconv.Itoa(p.Container))
+		if err != nil {
+			return "", domain.NewValidationError(fmt.Sprintf("invalid port %d/%s", p.Container, p.Protocol))
+		}
+		exposed[port] = struct{}{}
+		// an ephemeral host port on loopback, for a reverse proxy to reach
+		bindings[port] = []nat.PortBinding{{HostIP: "127.0.0.1"}}
+	}
+
+	networkMode := container.NetworkMode(network.NetworkNone)
+	var endpoints map[string]*network.EndpointSettings
+	if opts.Network != domain.NetworkNone && opts.EgressNetwork != "" {
+		networkMode = container.NetworkMode(opts.EgressNetwork)
+		endpoints = map[string]*network.EndpointSettings{
+			opts.EgressNetwork: {},
+		}
+		if opts.PrivateNetwork != "" {
+			endpoints[opts.PrivateNetwork] = &network.EndpointSettings{Aliases: []string{opts.PrivateAlias}}
+		}
+	}
+
+	hostConfig := &container.HostConfig{
+		AutoRemove:   false,
+		Mounts:       mounts,
+		NetworkMode:  networkMode,
+		PortBindings: bindings,
 		Resources: container.Resources{
-			Memory:            256 * 1024 * 1024, // 256MB
-			MemoryReservation: 128 * 1024 * 1024,
-			MemorySwap:        256 * 1024 * 1024,
-			NanoCPUs:          500000000, // 0.5 CPU cores
-			CPUShares:         512,       // Half of default priority
+			Memory:            opts.Resources.MemoryBytes,
+			MemoryReservation: opts.Resources.MemoryBytes / 2,
+			MemorySwap:        opts.Resources.MemoryBytes,
+			NanoCPUs:          opts.Resources.NanoCPUs,
+			CPUShares:         512, // Half of default priority
 			BlkioWeight:       300,
 		},
 		StorageOpt: map[string]string{
-			"size": "512MB", // 512MB
-		},
-	}, &network.NetworkingConfig{
-		EndpointsConfig: map[string]*network.EndpointSettings{
-			"gobox-c-network": {},
+			"size": opts.Resources.StorageSize,
 		},
+	}
+	applySecurity(hostConfig, opts.Security, opts.Runtime)
+
+	// generate container name
+	containerName := fmt.Sprintf("box-%s", uuid.New().String())
+	// create container with resource limits
+	resp, err := s.client.ContainerCreate(ctx, &container.Config{
+		Image:        opts.Image,
+		Entrypoint:   entrypoint,
+		Cmd:          []string{"bash"},
+		Tty:          true,
+		OpenStdin:    true,
+		Hostname:     "box",
+		Env:          opts.Env,
+		Labels:       opts.Labels,
+		ExposedPorts: exposed,
+	}, hostConfig, &network.NetworkingConfig{
+		EndpointsConfig: endpoints,
 	}, nil, containerName)
 	if err != nil {
+		if errdefs.IsNotFound(err) {
+			return "", domain.NewNotFoundError("image", opts.Image)
+		}
 		return "", domain.NewDockerError("create container", err)
 	}
 	return resp.ID, nil
@@ -195,6 +196,63 @@ func (s *Svc) AttachContainer(ctx context.Context, containerID string) (types.Hi
 	return attachResp, nil
 }
 
+// Exec runs cmd in a running container as user, or the image's user when
+// empty, and streams its combined output to w. It returns the command's
+// exit code.
+func (s *Svc) Exec(ctx context.Context, containerID, user string, cmd, env []string, w io.Writer) (int, error) {
+	created, err := s.client.ContainerExecCreate(ctx, containerID, container.ExecOptions{
+		User:         user,
+		Cmd:          cmd,
+		Env:          env,
+		Tty:          true,
+		AttachStdout: true,
+		AttachStderr: true,
+	})
+	if err != nil {
+		if errdefs.IsNotFound(err) {
+			return 0, domain.NewNotFoundError("container", containerID)
+		}
+		return 0, domain.NewDockerError("create exec", err)
+	}
+
+	resp, err := s.client.ContainerExecAttach(ctx, created.ID, container.ExecAttachOptions{Tty: true})
+	if err != nil {
+		return 0, domain.NewDockerError("attach to exec", err)
+	}
+	defer resp.Close()
+	// the hijacked connection ignores ctx; closing it stops the wait, though
+	// Docker cannot kill the exec'd process itself
+	stop := context.AfterFunc(ctx, resp.Close)
+	defer stop()
+
+	// with a TTY the output is a single raw stream
+	if _, err := io.Copy(w, resp.Reader); err != nil {
+		if ctx.Err() != nil {
+			return 0, domain.NewDockerError("exec", ctx.Err())
+		}
+		return 0, domain.NewDockerError("read exec output", err)
+	}
+
+	inspect, err := s.client.ContainerExecInspect(ctx, created.ID)
+	if err != nil {
+		return 0, domain.NewDockerError("inspect exec", err)
+	}
+	return inspect.ExitCode, nil
+}
+
+// ContainerRunning reports whether a container is running and not
+// paused.
+func (s *Svc) ContainerRunning(ctx context.Context, containerID string) (bool, error) {
+	inspect, err := s.client.ContainerInspect(ctx, containerID)
+	if err != nil {
+		if errdefs.IsNotFound(err) {
+			return false, domain.NewNotFoundError("container", containerID)
+		}
+		return false, domain.NewDockerError("inspect container", err)
+	}
+	return inspect.State.Running && !inspect.State.Paused, nil
+}
+
 func (s *Svc) StartIfNotRunning(ctx context.Context, containerID string) error {
 	inspect, err := s.client.ContainerInspect(ctx, containerID)
 	if err != nil {
@@ -204,6 +262,13 @@ func (s *Svc) StartIfNotRunning(ctx context.Context, containerID string) error {
 		return domain.NewDockerError("inspect container", err)
 	}
 
+	if inspect.State.Paused {
+		if err := s.client.ContainerUnpause(ctx, containerID); err != nil {
+			return domain.NewDockerError("unpause container", err)
+		}
+		return nil
+	}
+
 	if !inspect.State.Running {
 		if err := s.client.ContainerStart(ctx, containerID, container.StartOptions{}); err != nil {
 			return domain.NewDockerError("start container", err)
@@ -213,7 +278,35 @@ func (s *Svc) StartIfNotRunning(ctx context.Context, containerID string) error {
 	return nil
 }
 
+// PauseContainer freezes every process in the container using the cgroup
+// freezer, keeping their memory intact.
+func (s *Svc) PauseContainer(ctx context.Context, containerID string) error {
+	if err := s.client.ContainerPause(ctx, containerID); err != nil {
+		if errdefs.IsNotFound(err) {
+			return domain.NewNotFoundError("container", containerID)
+		}
+		return domain.NewDockerError("pause container", err)
+	}
+
+	return nil
+}
+
 func (s *Svc) StopContainer(ctx context.Context, containerID string) error {
+	inspect, err := s.client.ContainerInspect(ctx, containerID)
+	if err != nil {
+		if errdefs.IsNotFound(err) {
+			return domain.NewNotFoundError("container", containerID)
+		}
+		return domain.NewDockerError("inspect container", err)
+	}
+
+	// frozen processes cannot handle SIGTERM, so thaw them first
+	if inspect.State.Paused {
+		if err := s.client.ContainerUnpause(ctx, containerID); err != nil {
+			return domain.NewDockerError("unpause container", err)
+		}
+	}
+
 	timeout := 10
 	stopOptions := container.StopOptions{
 		Timeout: &timeout,
@@ -238,3 +331,223 @@ func (s *Svc) RemoveContainer(ctx context.Context, containerID string) error {
 
 	return nil
 }
+
+// CommitContainer saves the container's filesystem as an image tagged ref
+// and returns the image ID. The image keeps only the environment of the
+// image the container was created from; variables given at creation, such
+// as the owner's secrets, are blanked so they never outlive the container.
+func (s *Svc) CommitContainer(ctx context.Context, containerID, ref string, labels map[string]string) (string, error) {
+	changes := make([]string, 0, len(labels))
+	for k, v := range labels {
+		changes = append(changes, fmt.Sprintf("LABEL %s=%q", k, v))
+	}
+
+	env, err := s.imageEnv(ctx, containerID)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := s.client.ContainerCommit(ctx, containerID, container.CommitOptions{
+		Reference: ref,
+		Changes:   changes,
+		Pause:     true,
+		Config:    &container.Config{Env: env},
+	})
+	if err != nil {
+		if errdefs.IsNotFound(err) {
+			return "", domain.NewNotFoundError("container", containerID)
+		}
+		return "", domain.NewDockerError("commit container", err)
+	}
+	return resp.ID, nil
+}
+
+// imageEnv returns the container's variables with the values of its image.
+// A commit merges every variable of the container back into the image, so
+// the ones the image does not define are kept but emptied.
+func (s *Svc) imageEnv(ctx context.Context, containerID string) ([]string, error) {
+	inspect, err := s.client.ContainerInspect(ctx, containerID)
+	if err != nil {
+		if errdefs.IsNotFound(err) {
+			return nil, domain.NewNotFoundError("container", containerID)
+		}
+		return nil, domain.NewDockerError("inspect container", err)
+	}
+	imageEnv, err := s.baseEnv(ctx, inspect.Image)
+	if err != nil {
+		return nil, err
+	}
+
+	base := make(map[string]string)
+	for _, kv := range imageEnv {
+		name, _, _ := strings.Cut(kv, "=")
+		base[name] = kv
+	}
+
+	var env []string
+	if inspect.Config != nil {
+		for _, kv := range inspect.Config.Env {
+			name, _, _ := strings.Cut(kv, "=")
+			if imageKV, ok := base[name]; ok {
+				env = append(env, imageKV)
+			} else {
+				env = append(env, name+"=")
+			}
+		}
+	}
+	return env, nil
+}
+
+// baseEnv returns the variables defined by an image.
+func (s *Svc) baseEnv(ctx context.Context, ref string) ([]string, error) {
+	img, err := s.client.ImageInspect(ctx, ref)
+	if err != nil {
+		if errdefs.IsNotFound(err) {
+			return nil, domain.NewNotFoundError("image", ref)
+		}
+		return nil, domain.NewDockerError("inspect image", err)
+	}
+	if img.Config == nil {
+		return nil, nil
+	}
+	return img.Config.Env, nil
+}
+
+func (s *Svc) RemoveImage(ctx context.Context, ref string) error {
+	_, err := s.client.ImageRemove(ctx, ref, image.RemoveOptions{PruneChildren: true})
+	if err != nil {
+		if errdefs.IsNotFound(err) {
+			return domain.NewNotFoundError("image", ref)
+		}
+		if errdefs.IsConflict(err) {
+			return domain.NewConflictError("image is still in use")
+		}
+		return domain.NewDockerError("remove image", err)
+	}
+	return nil
+}
+
+// ImageLayers returns the number of filesystem layers of an image.
+func (s *Svc) ImageLayers(ctx context.Context, ref string) (int, error) {
+	img, err := s.client.ImageInspect(ctx, ref)
+	if err != nil {
+		if errdefs.IsNotFound(err) {
+			return 0, domain.NewNotFoundError("image", ref)
+		}
+		return 0, domain.NewDockerError("inspect image", err)
+	}
+	return len(img.RootFS.Layers), nil
+}
+
+// ImageSize returns the size of an image in bytes.
+func (s *Svc) ImageSize(ctx context.Context, ref string) (int64, error) {
+	inspect, err := s.client.ImageInspect(ctx, ref)
+	if err != nil {
+		if errdefs.IsNotFound(err) {
+			return 0, domain.NewNotFoundError("image", ref)
+		}
+		return 0, domain.NewDockerError("inspect image", err)
+	}
+	return inspect.Size, nil
+}
+
+// ListImages returns the images carrying the given label, e.g.
+// "gobox.kind=hibernated".
+func (s *Svc) ListImages(ctx context.Context, label string) ([]image.Summary, error) {
+	images, err := s.client.ImageList(ctx, image.ListOptions{
+		Filters: filters.NewArgs(filters.Arg("label", label)),
+	})
+	if err != nil {
+		return nil, domain.NewDockerError("list images", err)
+	}
+	return images, nil
+}
+
+// PruneImages removes dangling images carrying the given label and returns
+// the reclaimed space in bytes.
+func (s *Svc) PruneImages(ctx context.Context, label string) (uint64, error) {
+	report, err := s.client.ImagesPrune(ctx, filters.NewArgs(
+		filters.Arg("dangling", "true"),
+		filters.Arg("label", label),
+	))
+	if err != nil {
+		return 0, domain.NewDockerError("prune images", err)
+	}
+	return report.SpaceReclaimed, nil
+}
+
+// EnsureVolume creates a named volume unless it already exists.
+func (s *Svc) EnsureVolume(ctx context.Context, name string, labels map[string]string) error {
+	_, err := s.client.VolumeInspect(ctx, name)
+	if err == nil {
+		return nil
+	}
+	if !errdefs.IsNotFound(err) {
+		return domain.NewDockerError("inspect volume", err)
+	}
+
+	if _, err := s.client.VolumeCreate(ctx, volume.CreateOptions{
+		Name:   name,
+		Driver: "local",
+		Labels: labels,
+	}); err != nil {
+		return domain.NewDockerError("create volume", err)
+	}
+	return nil
+}
+
+// VolumeSize returns the disk space used by a named volume in bytes.
+func (s *Svc) VolumeSize(ctx context.Context, name string) (int64, error) {
+	usage, err := s.client.DiskUsage(ctx, types.DiskUsageOptions{
+		Types: []types.DiskUsageObject{types.VolumeObject},
+	})
+	if err != nil {
+		return 0, domain.NewDockerError("get disk usage", err)
+	}
+
+	for _, v := range usage.Volumes {
+		if v.Name != name {
+			continue
+		}
+		if v.UsageData == nil || v.UsageData.Size < 0 {
+			return 0, nil
+		}
+		return v.UsageData.Size, nil
+	}
+	return 0, domain.NewNotFoundError("volume", name)
+}
+
+func (s *Svc) RemoveVolume(ctx context.Context, name string) error {
+	if err := s.client.VolumeRemove(ctx, name, false); err != nil {
+		if errdefs.IsNotFound(err) {
+			return domain.NewNotFoundError("volume", name)
+		}
+		if errdefs.IsConflict(err) {
+			return domain.NewConflictError("volume is still in use")
+		}
+		return domain.NewDockerError("remove volume", err)
+	}
+	return nil
+}
+
+// CopyDirectory copies dir, including ownership, from one container into
+// another. Either container may be stopped; named volumes mounted at dir
+// are copied too.
+func (s *Svc) CopyDirectory(ctx context.Context, srcContainerID, dstContainerID, dir string) error {
+	content, _, err := s.client.CopyFromContainer(ctx, srcContainerID, dir)
+	if err != nil {
+		if errdefs.IsNotFound(err) {
+			return domain.NewNotFoundError("container path", dir)
+		}
+		return domain.NewDockerError("copy from container", err)
+	}
+	defer content.Close()
+
+	// the archive is rooted at the last element of dir
+	if err := s.client.CopyToContainer(ctx, dstContainerID, path.Dir(dir), content, container.CopyToContainerOptions{
+		CopyUIDGID: true,
+	}); err != nil {
+		return domain.NewDockerError("copy to container", err)
+	}
+	return nil
+}
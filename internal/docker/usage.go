@@ -0,0 +1,189 @@
+package docker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/containerd/errdefs"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/faiyaz032/gobox/internal/domain"
+)
+
+// CPUUsage returns the fraction of its CPU limit a container used over the
+// last second, e.g. 1 for a box saturating its half core.
+func (s *Svc) CPUUsage(ctx context.Context, containerID string) (float64, error) {
+	inspect, err := s.client.ContainerInspect(ctx, containerID)
+	if err != nil {
+		if errdefs.IsNotFound(err) {
+			return 0, domain.NewNotFoundError("container", containerID)
+		}
+		return 0, domain.NewDockerError("inspect container", err)
+	}
+
+	// without streaming Docker samples twice, filling in the previous
+	// reading
+	resp, err := s.client.ContainerStats(ctx, containerID, false)
+	if err != nil {
+		return 0, domain.NewDockerError("get container stats", err)
+	}
+	defer resp.Body.Close()
+
+	var stats container.StatsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&stats); err != nil {
+		return 0, domain.NewDockerError("decode container stats", err)
+	}
+
+	cpuDelta := float64(stats.CPUStats.CPUUsage.TotalUsage) - float64(stats.PreCPUStats.CPUUsage.TotalUsage)
+	systemDelta := float64(stats.CPUStats.SystemUsage) - float64(stats.PreCPUStats.SystemUsage)
+	onlineCPUs := float64(stats.CPUStats.OnlineCPUs)
+	if cpuDelta <= 0 || systemDelta <= 0 || onlineCPUs == 0 {
+		return 0, nil
+	}
+	cores := cpuDelta / systemDelta * onlineCPUs
+
+	limit := onlineCPUs
+	if nano := inspect.HostConfig.NanoCPUs; nano > 0 {
+		limit = float64(nano) / 1e9
+	}
+	return cores / limit, nil
+}
+
+// ContainerProcesses returns the names of every process in a container:
+// the command name and first argument each process reports, and the
+// binary it runs as the host sees it. ps runs on the host, so nothing in
+// the container can hide a process from it, and only the binary name
+// cannot be rewritten by the process itself.
+func (s *Svc) ContainerProcesses(ctx context.Context, containerID string) ([]string, error) {
+	// comm and args share the COMMAND header unless renamed
+	top, err := s.client.ContainerTop(ctx, containerID, []string{"-e", "-o", "pid=PID", "-o", "comm=NAME", "-o", "args=CMDLINE"})
+	if err != nil {
+		if errdefs.IsNotFound(err) {
+			return nil, domain.NewNotFoundError("container", containerID)
+		}
+		return nil, domain.NewDockerError("list container processes", err)
+	}
+
+	pid, comm, args := -1, -1, -1
+	for i, title := range top.Titles {
+		switch title {
+		case "PID":
+			pid = i
+		case "NAME":
+			comm = i
+		case "CMDLINE":
+			args = i
+		}
+	}
+
+	var names []string
+	for _, p := range top.Processes {
+		if comm >= 0 && comm < len(p) {
+			names = append(names, p[comm])
+		}
+		if args >= 0 && args < len(p) {
+			if fields := strings.Fields(p[args]); len(fields) > 0 {
+				names = append(names, path.Base(fields[0]))
+			}
+		}
+		// processes that exited since ps ran, or that the server may
+		// not inspect, are left to the other names
+		if pid >= 0 && pid < len(p) {
+			if exe, err := os.Readlink(filepath.Join(s.hostProc, p[pid], "exe")); err == nil {
+				names = append(names, path.Base(strings.TrimSuffix(exe, " (deleted)")))
+			}
+		}
+	}
+	return names, nil
+}
+
+// OutboundConnections counts a container's established TCP connections to
+// addresses other than loopback. The connection tables are read from the
+// host through the container's init process, so nothing in the container
+// can fake them.
+func (s *Svc) OutboundConnections(ctx context.Context, containerID string) (int, error) {
+	inspect, err := s.client.ContainerInspect(ctx, containerID)
+	if err != nil {
+		if errdefs.IsNotFound(err) {
+			return 0, domain.NewNotFoundError("container", containerID)
+		}
+		return 0, domain.NewDockerError("inspect container", err)
+	}
+	if inspect.State == nil || inspect.State.Pid == 0 {
+		return 0, domain.NewConflictError("container is not running")
+	}
+
+	count := 0
+	for _, table := range []string{"tcp", "tcp6"} {
+		data, err := os.ReadFile(filepath.Join(s.hostProc, strconv.Itoa(inspect.State.Pid), "net", table))
+		if err != nil {
+			// tcp6 is missing when IPv6 is disabled
+			if errors.Is(err, fs.ErrNotExist) && table == "tcp6" {
+				continue
+			}
+			return 0, domain.NewInternalError("read container connections", err)
+		}
+
+		for _, line := range strings.Split(string(data), "\n") {
+			// sl local_address rem_address st ...
+			fields := strings.Fields(line)
+			if len(fields) < 4 || fields[3] != "01" {
+				continue
+			}
+			remote, _, _ := strings.Cut(fields[2], ":")
+			if isLoopbackHex(remote) {
+				continue
+			}
+			count++
+		}
+	}
+	return count, nil
+}
+
+// isLoopbackHex reports whether a /proc/net/tcp address, an IPv4 address
+// in little-endian hex or an IPv6 one in 32-bit little-endian words, is
+// loopback.
+func isLoopbackHex(addr string) bool {
+	switch len(addr) {
+	case 8:
+		return strings.HasSuffix(addr, "7F")
+	case 32:
+		return addr == "00000000000000000000000001000000" ||
+			// IPv4-mapped ::ffff:127.x.x.x
+			strings.HasPrefix(addr, "0000000000000000FFFF0000") && strings.HasSuffix(addr, "7F")
+	}
+	return false
+}
+
+// CountRunning counts the running containers carrying label, paused ones
+// excluded.
+func (s *Svc) CountRunning(ctx context.Context, label string) (int, error) {
+	containers, err := s.client.ContainerList(ctx, container.ListOptions{
+		Filters: filters.NewArgs(filters.Arg("label", label), filters.Arg("status", "running")),
+	})
+	if err != nil {
+		return 0, domain.NewDockerError("list containers", err)
+	}
+	return len(containers), nil
+}
+
+// ThrottleContainer lowers a running container's CPU limit.
+func (s *Svc) ThrottleContainer(ctx context.Context, containerID string, nanoCPUs int64) error {
+	_, err := s.client.ContainerUpdate(ctx, containerID, container.UpdateConfig{
+		Resources: container.Resources{NanoCPUs: nanoCPUs},
+	})
+	if err != nil {
+		if errdefs.IsNotFound(err) {
+			return domain.NewNotFoundError("container", containerID)
+		}
+		return domain.NewDockerError("update container", err)
+	}
+	return nil
+}
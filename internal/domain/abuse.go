@@ -0,0 +1,40 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// AbuseKind is what the box watchdog flagged.
+type AbuseKind string
+
+const (
+	// AbuseCPU is CPU use held at the box's limit for too long.
+	AbuseCPU AbuseKind = "cpu"
+	// AbuseMiner is a process named like a known cryptocurrency miner.
+	AbuseMiner AbuseKind = "miner"
+	// AbuseConnections is an excessive number of outbound connections.
+	AbuseConnections AbuseKind = "connections"
+)
+
+// AbuseAction is how the watchdog responded, escalating with each offence.
+type AbuseAction string
+
+const (
+	AbuseWarn     AbuseAction = "warn"
+	AbuseThrottle AbuseAction = "throttle"
+	AbuseStop     AbuseAction = "stop"
+	AbuseBan      AbuseAction = "ban"
+)
+
+// AbuseEvent records abuse flagged on a box and the action taken.
+type AbuseEvent struct {
+	ID            uuid.UUID   `json:"id"`
+	FingerprintID string      `json:"fingerprint_id"`
+	ContainerID   string      `json:"container_id"`
+	Kind          AbuseKind   `json:"kind"`
+	Detail        string      `json:"detail"`
+	Action        AbuseAction `json:"action"`
+	CreatedAt     time.Time   `json:"created_at"`
+}
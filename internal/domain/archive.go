@@ -0,0 +1,28 @@
+package domain
+
+import "time"
+
+// ArchiveVersion is the current portable archive format.
+const ArchiveVersion = 1
+
+// ArchiveManifest describes a box exported as a portable archive. It is
+// stored as manifest.json next to rootfs.tar inside a tar.gz file.
+type ArchiveManifest struct {
+	Version int `json:"version"`
+	// Template is the image the box was created from.
+	Template  string          `json:"template"`
+	Tier      string          `json:"tier"`
+	Resources ResourceProfile `json:"resources"`
+	// Env, User, WorkingDir and Cmd restore the image config that a
+	// filesystem export drops. Env holds the image's variables only; the
+	// owner's are applied from the database when the box is created.
+	Env        []string          `json:"env"`
+	User       string            `json:"user"`
+	WorkingDir string            `json:"working_dir"`
+	Cmd        []string          `json:"cmd"`
+	Metadata   map[string]string `json:"metadata"`
+	ExportedAt time.Time         `json:"exported_at"`
+	// RootfsSHA256 and RootfsSize let an import verify rootfs.tar.
+	RootfsSHA256 string `json:"rootfs_sha256"`
+	RootfsSize   int64  `json:"rootfs_size"`
+}
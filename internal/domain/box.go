@@ -10,7 +10,26 @@ type BoxStatus string
 
 const (
 	StatusRunning BoxStatus = "running"
-	StatusPaused  BoxStatus = "paused"
+	// StatusPaused means the container is frozen with its processes intact.
+	StatusPaused BoxStatus = "paused"
+	// StatusStopped means the container's processes were terminated.
+	StatusStopped BoxStatus = "stopped"
+	// StatusHibernated means the container was committed to Image and
+	// removed; it is recreated on the next connect.
+	StatusHibernated BoxStatus = "hibernated"
+)
+
+// ProvisionStatus tracks the provisioning scripts run on a box's first
+// start.
+type ProvisionStatus string
+
+const (
+	ProvisionPending ProvisionStatus = "pending"
+	ProvisionRunning ProvisionStatus = "running"
+	ProvisionReady   ProvisionStatus = "ready"
+	// ProvisionFailed means a script kept failing after its retries; the
+	// box is usable but may be incomplete.
+	ProvisionFailed ProvisionStatus = "failed"
 )
 
 type Box struct {
@@ -19,4 +38,112 @@ type Box struct {
 	ContainerID   string    `json:"container_id"`
 	Status        BoxStatus `json:"status"`
 	LastActive    time.Time `json:"last_active"`
+	Tier          string    `json:"tier"`
+	CreatedAt     time.Time `json:"created_at"`
+	Image         string    `json:"image"`
+	Spec          BoxSpec   `json:"spec"`
+
+	ProvisionStatus ProvisionStatus `json:"provision_status"`
+	// EnvStale means the owner's variables changed since the container
+	// was created.
+	EnvStale bool `json:"env_stale"`
+}
+
+// BoxStatusReport is a box with details read from its container.
+type BoxStatusReport struct {
+	Box
+	// Runtime is the OCI runtime the container runs under, e.g. runc or
+	// runsc. It is empty while the box has no container.
+	Runtime string `json:"runtime,omitempty"`
+}
+
+// ResourceProfile is the memory, CPU and disk allotted to a box.
+type ResourceProfile struct {
+	MemoryBytes int64  `json:"memory_bytes"`
+	NanoCPUs    int64  `json:"nano_cpus"`
+	StorageSize string `json:"storage_size"`
+}
+
+// DefaultResources is the profile every box gets unless its spec picks
+// another one.
+var DefaultResources = ResourceProfile{
+	MemoryBytes: 256 * 1024 * 1024, // 256MB
+	NanoCPUs:    500000000,         // 0.5 CPU cores
+	StorageSize: "512MB",
+}
+
+// DefaultResourceProfile names DefaultResources in ResourceProfiles.
+const DefaultResourceProfile = "small"
+
+// ResourceProfiles are the resource profiles a box spec can choose from.
+var ResourceProfiles = map[string]ResourceProfile{
+	DefaultResourceProfile: DefaultResources,
+	"medium": {
+		MemoryBytes: 512 * 1024 * 1024, // 512MB
+		NanoCPUs:    1000000000,        // 1 CPU core
+		StorageSize: "1GB",
+	},
+	"large": {
+		MemoryBytes: 1024 * 1024 * 1024, // 1GB
+		NanoCPUs:    2000000000,         // 2 CPU cores
+		StorageSize: "2GB",
+	},
+}
+
+// ContainerOptions describes the container created for a box.
+type ContainerOptions struct {
+	Image     string
+	Resources ResourceProfile
+	Env       []string
+	// Startup commands run before the shell each time the container
+	// starts.
+	Startup []string
+	Ports   []SpecPort
+	// Network is a NetworkDefault or NetworkNone policy.
+	Network string
+	// EgressNetwork is the box's own network to the outside world. A box
+	// without one, or with NetworkNone, has no network.
+	EgressNetwork string
+	// PrivateNetwork is the box's internal network shared with its
+	// sidecars, joined in addition to the egress one; PrivateAlias is the
+	// box's hostname on it.
+	PrivateNetwork string
+	PrivateAlias   string
+	// HomeVolume is a named volume mounted at /box, or empty to keep
+	// files in the container's writable layer.
+	HomeVolume string
+	Security   SecurityOptions
+	// Runtime is the OCI runtime to run the container under, or empty for
+	// the daemon's default.
+	Runtime string
+	Labels  map[string]string
+}
+
+// Security profiles a template can run its boxes under.
+const (
+	// SecurityStrict drops all but a few capabilities, forbids privilege
+	// escalation, so sudo no longer works, and keeps the root filesystem
+	// read-only. Boxes need a home volume for /box to stay writable.
+	SecurityStrict = "strict"
+	// SecurityRootFriendly keeps sudo working but drops capabilities a
+	// shell never needs.
+	SecurityRootFriendly = "root-friendly"
+)
+
+// SecurityOptions hardens a container GoBox creates.
+type SecurityOptions struct {
+	// Profile is SecurityStrict or SecurityRootFriendly.
+	Profile string
+	// Seccomp is a seccomp profile in Docker's JSON format, or empty for
+	// Docker's default one.
+	Seccomp string
+	// PidsLimit caps the processes in the container, against fork bombs.
+	// Zero leaves it unlimited.
+	PidsLimit int64
+}
+
+// HomeVolume is an owner's persistent /box directory.
+type HomeVolume struct {
+	Name      string `json:"name"`
+	SizeBytes int64  `json:"size_bytes"`
 }
@@ -0,0 +1,14 @@
+package domain
+
+import "time"
+
+// Dotfiles are an owner's shell configuration, copied into /box of every
+// new container they get.
+type Dotfiles struct {
+	FingerprintID string `json:"fingerprint_id"`
+	// Archive is a tar of the files relative to /box.
+	Archive   []byte    `json:"-"`
+	Files     []string  `json:"files"`
+	SizeBytes int64     `json:"size_bytes"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
@@ -0,0 +1,47 @@
+package domain
+
+import "time"
+
+// EnvInjection selects where an owner's variable is made available.
+type EnvInjection string
+
+const (
+	// EnvAtCreate puts the variable in the container's Env, so it applies
+	// to the shell once the container is recreated.
+	EnvAtCreate EnvInjection = "create"
+	// EnvInExec passes the variable only to commands GoBox executes in the
+	// container, keeping it out of the container's configuration.
+	EnvInExec EnvInjection = "exec"
+)
+
+// Where a variable is kept.
+const (
+	// EnvScopeUser variables belong to the owner and outlive their box.
+	EnvScopeUser = "user"
+	// EnvScopeBox variables are part of the box's spec and override the
+	// owner's.
+	EnvScopeBox = "box"
+)
+
+// How a variable change reaches a box that already has a container.
+const (
+	// EnvApplyNextShell recreates the container on the next connect with
+	// no other terminal attached.
+	EnvApplyNextShell = "next_shell"
+	// EnvApplyRecreate recreates the container right away, closing
+	// attached terminals.
+	EnvApplyRecreate = "recreate"
+)
+
+// EnvVar is one of an owner's environment variables. The value of a secret
+// is only held sealed in Ciphertext and is never returned by the API.
+type EnvVar struct {
+	FingerprintID string       `json:"-"`
+	Name          string       `json:"name"`
+	Scope         string       `json:"scope"`
+	Value         string       `json:"value,omitempty"`
+	Secret        bool         `json:"secret"`
+	Ciphertext    []byte       `json:"-"`
+	Injection     EnvInjection `json:"injection"`
+	UpdatedAt     time.Time    `json:"updated_at"`
+}
@@ -3,7 +3,9 @@ package domain
 import (
 	"errors"
 	"fmt"
+	"math"
 	"net/http"
+	"time"
 )
 
 // ErrorType represents the category of error
@@ -17,6 +19,8 @@ const (
 	ErrorTypeDocker       ErrorType = "DOCKER"
 	ErrorTypeConflict     ErrorType = "CONFLICT"
 	ErrorTypeUnauthorized ErrorType = "UNAUTHORIZED"
+	ErrorTypeForbidden    ErrorType = "FORBIDDEN"
+	ErrorTypeRateLimited  ErrorType = "RATE_LIMITED"
 )
 
 // AppError represents a structured application error
@@ -25,6 +29,9 @@ type AppError struct {
 	Message    string
 	StatusCode int
 	Err        error // underlying error
+	// RetryAfter tells clients when to try again, e.g. after a rate
+	// limit. Zero omits the hint.
+	RetryAfter time.Duration
 }
 
 // Error implements the error interface
@@ -113,6 +120,26 @@ func NewUnauthorizedError(message string) *AppError {
 	}
 }
 
+// NewForbiddenError creates a forbidden error
+func NewForbiddenError(message string) *AppError {
+	return &AppError{
+		Type:       ErrorTypeForbidden,
+		Message:    message,
+		StatusCode: http.StatusForbidden,
+	}
+}
+
+// NewRateLimitError creates a too-many-requests error telling the client
+// when to retry
+func NewRateLimitError(message string, retryAfter time.Duration) *AppError {
+	return &AppError{
+		Type:       ErrorTypeRateLimited,
+		Message:    message,
+		StatusCode: http.StatusTooManyRequests,
+		RetryAfter: retryAfter,
+	}
+}
+
 // Helper functions
 
 // IsAppError checks if an error is an AppError
@@ -140,6 +167,16 @@ func GetErrorType(err error) ErrorType {
 	return ErrorTypeInternal
 }
 
+// GetRetryAfter extracts the retry hint from error, in whole seconds
+// rounded up as the Retry-After header expects. It is zero when there is
+// none.
+func GetRetryAfter(err error) int {
+	if appErr, ok := IsAppError(err); ok && appErr.RetryAfter > 0 {
+		return int(math.Ceil(appErr.RetryAfter.Seconds()))
+	}
+	return 0
+}
+
 // GetErrorMessage extracts user-friendly message from error
 func GetErrorMessage(err error) string {
 	if appErr, ok := IsAppError(err); ok {
@@ -0,0 +1,79 @@
+package domain
+
+import (
+	"net/netip"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// LabSpec declares a network lab: nodes joined by networks on subnets of
+// their own, e.g. two subnets connected by a router node.
+type LabSpec struct {
+	Name     string           `json:"name" yaml:"name"`
+	Networks []LabNetworkSpec `json:"networks" yaml:"networks"`
+	Nodes    []LabNodeSpec    `json:"nodes" yaml:"nodes"`
+}
+
+// LabNetworkSpec is a lab network. Prefix is the subnet's prefix length;
+// zero takes the server default.
+type LabNetworkSpec struct {
+	Name   string `json:"name" yaml:"name"`
+	Prefix int    `json:"prefix,omitempty" yaml:"prefix"`
+}
+
+// LabNodeSpec is a box in a lab.
+type LabNodeSpec struct {
+	Name string `json:"name" yaml:"name"`
+	// Template names a configured box template providing the image.
+	Template string `json:"template" yaml:"template"`
+	// Router enables IP forwarding between the node's interfaces.
+	Router     bool               `json:"router,omitempty" yaml:"router"`
+	Interfaces []LabInterfaceSpec `json:"interfaces" yaml:"interfaces"`
+}
+
+// LabInterfaceSpec attaches a node to a network. Host is the node's
+// host number in the subnet, e.g. 1 for .1 of a /24; zero picks the next
+// free one.
+type LabInterfaceSpec struct {
+	Network string `json:"network" yaml:"network"`
+	Host    int    `json:"host,omitempty" yaml:"host"`
+}
+
+// Lab is a running network lab.
+type Lab struct {
+	ID            uuid.UUID    `json:"id"`
+	FingerprintID string       `json:"fingerprint_id"`
+	Name          string       `json:"name"`
+	Spec          LabSpec      `json:"spec"`
+	Networks      []LabNetwork `json:"networks"`
+	Nodes         []LabNode    `json:"nodes"`
+	CreatedAt     time.Time    `json:"created_at"`
+}
+
+// LabNetwork is a lab network and the subnet allocated to it.
+type LabNetwork struct {
+	Name   string       `json:"name"`
+	Subnet netip.Prefix `json:"subnet"`
+}
+
+// LabNode is a lab node's container and its static addresses by network.
+type LabNode struct {
+	Name        string                `json:"name"`
+	ContainerID string                `json:"container_id"`
+	Addresses   map[string]netip.Addr `json:"addresses"`
+}
+
+// LabNodeOptions describes a lab node container to create.
+type LabNodeOptions struct {
+	Image     string
+	Hostname  string
+	Resources ResourceProfile
+	// Addresses maps Docker network names to the node's static address.
+	Addresses map[string]netip.Addr
+	Router    bool
+	Labels    map[string]string
+	// Security and Runtime are those of the node's template.
+	Security SecurityOptions
+	Runtime  string
+}
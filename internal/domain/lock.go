@@ -0,0 +1,13 @@
+package domain
+
+// LockKey identifies a Postgres advisory lock taken by background jobs
+// that must run on a single replica at a time.
+type LockKey int64
+
+// Keys share a prefix so they are unlikely to collide with locks taken by
+// other applications on the same database.
+const (
+	LockBoxSweep LockKey = 0x60b0_0000 + iota
+	LockAbuseWatchdog
+	LockLabReaper
+)
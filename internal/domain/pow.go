@@ -0,0 +1,20 @@
+package domain
+
+import "time"
+
+// PowChallenge is a proof-of-work puzzle a client solves before a box is
+// created for it. A zero Difficulty means no work is required.
+type PowChallenge struct {
+	Challenge  string    `json:"challenge,omitempty"`
+	Difficulty int       `json:"difficulty"`
+	ExpiresAt  time.Time `json:"expires_at,omitzero"`
+}
+
+// ProofOfWork is a client's solution to a PowChallenge.
+type ProofOfWork struct {
+	Challenge string
+	Solution  string
+	// ClientIP is the address the proof was presented from; creations are
+	// counted against it to raise its next difficulty.
+	ClientIP string
+}
@@ -0,0 +1,40 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Sidecar is a service container running next to a box on its private
+// network, reachable from the box by Name.
+type Sidecar struct {
+	ID          uuid.UUID `json:"id"`
+	BoxID       uuid.UUID `json:"box_id"`
+	Name        string    `json:"name"`
+	Template    string    `json:"template"`
+	ContainerID string    `json:"container_id"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// SidecarResources is the resource profile of every sidecar container.
+var SidecarResources = ResourceProfile{
+	MemoryBytes: 256 * 1024 * 1024, // 256MB
+	NanoCPUs:    500000000,         // 0.5 CPU cores
+	StorageSize: "1GB",
+}
+
+// SidecarOptions describes a sidecar container to create.
+type SidecarOptions struct {
+	Image     string
+	Resources ResourceProfile
+	Env       []string
+	// Network is the box's private network; Alias is the sidecar's
+	// hostname on it.
+	Network string
+	Alias   string
+	Labels  map[string]string
+	// Security and Runtime harden the sidecar under its box's runtime.
+	Security SecurityOptions
+	Runtime  string
+}
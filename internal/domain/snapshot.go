@@ -0,0 +1,24 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Snapshot is a committed image of a box that can be restored later.
+type Snapshot struct {
+	ID            uuid.UUID `json:"id"`
+	FingerprintID string    `json:"fingerprint_id"`
+	BoxID         uuid.UUID `json:"box_id"`
+	Name          string    `json:"name"`
+	Image         string    `json:"image"`
+	SizeBytes     int64     `json:"size_bytes"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// SnapshotUsage is an owner's consumption of the snapshot quota.
+type SnapshotUsage struct {
+	Count     int64
+	SizeBytes int64
+}
@@ -0,0 +1,77 @@
+package domain
+
+// Network policies a box spec can choose.
+const (
+	// NetworkDefault gives the box outbound access through its own egress
+	// network, plus a private network shared with its sidecars.
+	NetworkDefault = "default"
+	// NetworkNone gives the box a loopback interface only.
+	NetworkNone = "none"
+)
+
+// BoxSpec declares how a box is built. Empty fields take the server's
+// defaults.
+type BoxSpec struct {
+	// Template names a configured template providing the image.
+	Template string `json:"template" yaml:"template"`
+	// Resources names a resource profile from ResourceProfiles.
+	Resources string            `json:"resources" yaml:"resources"`
+	Env       map[string]string `json:"env,omitempty" yaml:"env"`
+	// Files are written into the container before its first start.
+	Files []SpecFile `json:"files,omitempty" yaml:"files"`
+	// Startup commands run through /bin/sh, in order, each time the
+	// container starts and before the shell is launched.
+	Startup []string `json:"startup,omitempty" yaml:"startup"`
+	// Provision scripts run once, after the template's own, when the box
+	// first starts.
+	Provision []string `json:"provision,omitempty" yaml:"provision"`
+	// SkipDotfiles keeps the owner's dotfiles out of this box.
+	SkipDotfiles bool       `json:"skip_dotfiles,omitempty" yaml:"skip_dotfiles"`
+	Ports        []SpecPort `json:"ports,omitempty" yaml:"ports"`
+	// Sidecars run next to the box on a private network.
+	Sidecars  []SpecSidecar `json:"sidecars,omitempty" yaml:"sidecars"`
+	Network   NetworkSpec   `json:"network" yaml:"network"`
+	Lifecycle LifecycleSpec `json:"lifecycle" yaml:"lifecycle"`
+}
+
+// SpecFile is a file seeded into a new box. Mode is an octal string such
+// as "0644".
+type SpecFile struct {
+	Path    string `json:"path" yaml:"path"`
+	Content string `json:"content" yaml:"content"`
+	Mode    string `json:"mode,omitempty" yaml:"mode"`
+}
+
+// SpecPort is a container port published on the host's loopback
+// interface.
+type SpecPort struct {
+	Container int    `json:"container" yaml:"container"`
+	Protocol  string `json:"protocol,omitempty" yaml:"protocol"`
+}
+
+// SpecSidecar is a sidecar started from a configured sidecar template. The
+// box reaches it at Name, which defaults to the template's name.
+type SpecSidecar struct {
+	Name     string            `json:"name" yaml:"name"`
+	Template string            `json:"template" yaml:"template"`
+	Env      map[string]string `json:"env,omitempty" yaml:"env"`
+}
+
+type NetworkSpec struct {
+	Policy string `json:"policy" yaml:"policy"`
+}
+
+// LifecycleSpec adjusts the lifecycle policy of the box's tier. A spec may
+// only make the policy stricter than the tier's, or keep the box out of
+// hibernation where the tier still deletes it once idle.
+type LifecycleSpec struct {
+	// Tier selects the lifecycle policy of the box. It is assigned by the
+	// server; specs submitted by clients leave it empty.
+	Tier string `json:"tier" yaml:"tier"`
+	// IdleTimeout ends a session after this long without keystrokes, as a
+	// duration such as "15m". It cannot exceed the tier's idle timeout.
+	IdleTimeout string `json:"idle_timeout,omitempty" yaml:"idle_timeout"`
+	// NoHibernate keeps the stopped box's container instead of committing
+	// it to an image, so it starts without being restored first.
+	NoHibernate bool `json:"no_hibernate,omitempty" yaml:"no_hibernate"`
+}
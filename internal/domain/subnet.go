@@ -0,0 +1,45 @@
+package domain
+
+import (
+	"net/netip"
+	"time"
+)
+
+// SubnetAllocation is a subnet handed out to a Docker network.
+type SubnetAllocation struct {
+	Network     string
+	Subnet      netip.Prefix
+	AllocatedAt time.Time
+}
+
+// SubnetSize is the number of addresses in an IPv4 subnet.
+func SubnetSize(p netip.Prefix) int {
+	return 1 << (32 - p.Bits())
+}
+
+// SubnetHost returns the nth address of an IPv4 subnet. Host numbers run
+// from 1 to SubnetSize-3: the last usable address is the Docker bridge's
+// gateway, leaving .1 free for a lab's own router.
+func SubnetHost(p netip.Prefix, n int) (netip.Addr, bool) {
+	if n < 1 || n > SubnetSize(p)-3 {
+		return netip.Addr{}, false
+	}
+	return subnetAddr(p, n), true
+}
+
+// SubnetGateway returns the address of the Docker bridge on a lab subnet.
+func SubnetGateway(p netip.Prefix) netip.Addr {
+	return subnetAddr(p, SubnetSize(p)-2)
+}
+
+// NthSubnet returns the ith subnet of the given prefix length in pool.
+func NthSubnet(pool netip.Prefix, bits, i int) netip.Prefix {
+	return netip.PrefixFrom(subnetAddr(pool, i<<(32-bits)), bits)
+}
+
+func subnetAddr(p netip.Prefix, n int) netip.Addr {
+	b := p.Masked().Addr().As4()
+	v := uint32(b[0])<<24 | uint32(b[1])<<16 | uint32(b[2])<<8 | uint32(b[3])
+	v += uint32(n)
+	return netip.AddrFrom4([4]byte{byte(v >> 24), byte(v >> 16), byte(v >> 8), byte(v)})
+}
@@ -8,6 +8,7 @@ package db
 import (
 	"context"
 
+	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
@@ -16,18 +17,26 @@ INSERT INTO box (
     fingerprint_id,
     container_id,
     status,
-    last_active
+    last_active,
+    tier,
+    image,
+    spec,
+    provision_status
 ) VALUES (
-    $1, $2, $3, $4
+    $1, $2, $3, $4, $5, $6, $7, $8
 )
-RETURNING id, fingerprint_id, container_id, status, last_active
+RETURNING id, fingerprint_id, container_id, status, last_active, tier, created_at, image, spec, provision_status, env_stale
 `
 
 type CreateBoxParams struct {
-	FingerprintID string           `db:"fingerprint_id" json:"fingerprint_id"`
-	ContainerID   string           `db:"container_id" json:"container_id"`
-	Status        string           `db:"status" json:"status"`
-	LastActive    pgtype.Timestamp `db:"last_active" json:"last_active"`
+	FingerprintID   string           `db:"fingerprint_id" json:"fingerprint_id"`
+	ContainerID     string           `db:"container_id" json:"container_id"`
+	Status          string           `db:"status" json:"status"`
+	LastActive      pgtype.Timestamp `db:"last_active" json:"last_active"`
+	Tier            string           `db:"tier" json:"tier"`
+	Image           string           `db:"image" json:"image"`
+	Spec            []byte           `db:"spec" json:"spec"`
+	ProvisionStatus string           `db:"provision_status" json:"provision_status"`
 }
 
 func (q *Queries) CreateBox(ctx context.Context, arg CreateBoxParams) (Box, error) {
@@ -36,6 +45,10 @@ func (q *Queries) CreateBox(ctx context.Context, arg CreateBoxParams) (Box, erro
 		arg.ContainerID,
 		arg.Status,
 		arg.LastActive,
+		arg.Tier,
+		arg.Image,
+		arg.Spec,
+		arg.ProvisionStatus,
 	)
 	var i Box
 	err := row.Scan(
@@ -44,10 +57,44 @@ func (q *Queries) CreateBox(ctx context.Context, arg CreateBoxParams) (Box, erro
 		&i.ContainerID,
 		&i.Status,
 		&i.LastActive,
+		&i.Tier,
+		&i.CreatedAt,
+		&i.Image,
+		&i.Spec,
+		&i.ProvisionStatus,
+		&i.EnvStale,
 	)
 	return i, err
 }
 
+const claimBoxProvision = `-- name: ClaimBoxProvision :execrows
+UPDATE box
+SET provision_status = 'running'
+WHERE fingerprint_id = $1 AND provision_status = 'pending'
+`
+
+// Moves a pending box to running so only one connection provisions it
+func (q *Queries) ClaimBoxProvision(ctx context.Context, fingerprintID string) (int64, error) {
+	result, err := q.db.Exec(ctx, claimBoxProvision, fingerprintID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const countBoxesByStatus = `-- name: CountBoxesByStatus :one
+SELECT COUNT(*) FROM box
+WHERE status = $1
+`
+
+// Used to cap the containers running at once
+func (q *Queries) CountBoxesByStatus(ctx context.Context, status string) (int64, error) {
+	row := q.db.QueryRow(ctx, countBoxesByStatus, status)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
 const deleteBox = `-- name: DeleteBox :exec
 DELETE FROM box
 WHERE fingerprint_id = $1
@@ -58,8 +105,34 @@ func (q *Queries) DeleteBox(ctx context.Context, fingerprintID string) error {
 	return err
 }
 
+const deleteBoxIfUnchanged = `-- name: DeleteBoxIfUnchanged :execrows
+DELETE FROM box
+WHERE fingerprint_id = $1 AND container_id = $2 AND image = $3 AND status = $4
+`
+
+type DeleteBoxIfUnchangedParams struct {
+	FingerprintID string `db:"fingerprint_id" json:"fingerprint_id"`
+	ContainerID   string `db:"container_id" json:"container_id"`
+	Image         string `db:"image" json:"image"`
+	Status        string `db:"status" json:"status"`
+}
+
+// Deletes a box only while it still has the container, image and status the caller read
+func (q *Queries) DeleteBoxIfUnchanged(ctx context.Context, arg DeleteBoxIfUnchangedParams) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteBoxIfUnchanged,
+		arg.FingerprintID,
+		arg.ContainerID,
+		arg.Image,
+		arg.Status,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
 const getBoxByContainerID = `-- name: GetBoxByContainerID :one
-SELECT id, fingerprint_id, container_id, status, last_active FROM box
+SELECT id, fingerprint_id, container_id, status, last_active, tier, created_at, image, spec, provision_status, env_stale FROM box
 WHERE container_id = $1 LIMIT 1
 `
 
@@ -72,12 +145,18 @@ func (q *Queries) GetBoxByContainerID(ctx context.Context, containerID string) (
 		&i.ContainerID,
 		&i.Status,
 		&i.LastActive,
+		&i.Tier,
+		&i.CreatedAt,
+		&i.Image,
+		&i.Spec,
+		&i.ProvisionStatus,
+		&i.EnvStale,
 	)
 	return i, err
 }
 
 const getBoxByFingerprint = `-- name: GetBoxByFingerprint :one
-SELECT id, fingerprint_id, container_id, status, last_active FROM box
+SELECT id, fingerprint_id, container_id, status, last_active, tier, created_at, image, spec, provision_status, env_stale FROM box
 WHERE fingerprint_id = $1 LIMIT 1
 `
 
@@ -90,16 +169,84 @@ func (q *Queries) GetBoxByFingerprint(ctx context.Context, fingerprintID string)
 		&i.ContainerID,
 		&i.Status,
 		&i.LastActive,
+		&i.Tier,
+		&i.CreatedAt,
+		&i.Image,
+		&i.Spec,
+		&i.ProvisionStatus,
+		&i.EnvStale,
+	)
+	return i, err
+}
+
+const getBoxByID = `-- name: GetBoxByID :one
+SELECT id, fingerprint_id, container_id, status, last_active, tier, created_at, image, spec, provision_status, env_stale FROM box
+WHERE id = $1 LIMIT 1
+`
+
+func (q *Queries) GetBoxByID(ctx context.Context, id uuid.UUID) (Box, error) {
+	row := q.db.QueryRow(ctx, getBoxByID, id)
+	var i Box
+	err := row.Scan(
+		&i.ID,
+		&i.FingerprintID,
+		&i.ContainerID,
+		&i.Status,
+		&i.LastActive,
+		&i.Tier,
+		&i.CreatedAt,
+		&i.Image,
+		&i.Spec,
+		&i.ProvisionStatus,
+		&i.EnvStale,
 	)
 	return i, err
 }
 
+const getBoxesCreatedBefore = `-- name: GetBoxesCreatedBefore :many
+SELECT id, fingerprint_id, container_id, status, last_active, tier, created_at, image, spec, provision_status, env_stale FROM box
+WHERE created_at < $1
+`
+
+// Used by the lifecycle scheduler's hard expiry sweep
+func (q *Queries) GetBoxesCreatedBefore(ctx context.Context, createdAt pgtype.Timestamp) ([]Box, error) {
+	rows, err := q.db.Query(ctx, getBoxesCreatedBefore, createdAt)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Box{}
+	for rows.Next() {
+		var i Box
+		if err := rows.Scan(
+			&i.ID,
+			&i.FingerprintID,
+			&i.ContainerID,
+			&i.Status,
+			&i.LastActive,
+			&i.Tier,
+			&i.CreatedAt,
+			&i.Image,
+			&i.Spec,
+			&i.ProvisionStatus,
+			&i.EnvStale,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const getExpiredBoxes = `-- name: GetExpiredBoxes :many
-SELECT id, fingerprint_id, container_id, status, last_active FROM box
+SELECT id, fingerprint_id, container_id, status, last_active, tier, created_at, image, spec, provision_status, env_stale FROM box
 WHERE last_active < $1
 `
 
-// Used by the 24h cleanup worker
+// Used by the lifecycle scheduler's inactivity sweep
 func (q *Queries) GetExpiredBoxes(ctx context.Context, lastActive pgtype.Timestamp) ([]Box, error) {
 	rows, err := q.db.Query(ctx, getExpiredBoxes, lastActive)
 	if err != nil {
@@ -115,6 +262,12 @@ func (q *Queries) GetExpiredBoxes(ctx context.Context, lastActive pgtype.Timesta
 			&i.ContainerID,
 			&i.Status,
 			&i.LastActive,
+			&i.Tier,
+			&i.CreatedAt,
+			&i.Image,
+			&i.Spec,
+			&i.ProvisionStatus,
+			&i.EnvStale,
 		); err != nil {
 			return nil, err
 		}
@@ -127,7 +280,7 @@ func (q *Queries) GetExpiredBoxes(ctx context.Context, lastActive pgtype.Timesta
 }
 
 const listBoxesByStatus = `-- name: ListBoxesByStatus :many
-SELECT id, fingerprint_id, container_id, status, last_active FROM box
+SELECT id, fingerprint_id, container_id, status, last_active, tier, created_at, image, spec, provision_status, env_stale FROM box
 WHERE status = $1
 `
 
@@ -146,6 +299,12 @@ func (q *Queries) ListBoxesByStatus(ctx context.Context, status string) ([]Box,
 			&i.ContainerID,
 			&i.Status,
 			&i.LastActive,
+			&i.Tier,
+			&i.CreatedAt,
+			&i.Image,
+			&i.Spec,
+			&i.ProvisionStatus,
+			&i.EnvStale,
 		); err != nil {
 			return nil, err
 		}
@@ -157,10 +316,57 @@ func (q *Queries) ListBoxesByStatus(ctx context.Context, status string) ([]Box,
 	return items, nil
 }
 
+const replaceBoxContainer = `-- name: ReplaceBoxContainer :exec
+UPDATE box
+SET container_id = $2,
+    image = $3,
+    status = $4,
+    spec = $5,
+    tier = $6
+WHERE fingerprint_id = $1
+`
+
+type ReplaceBoxContainerParams struct {
+	FingerprintID string `db:"fingerprint_id" json:"fingerprint_id"`
+	ContainerID   string `db:"container_id" json:"container_id"`
+	Image         string `db:"image" json:"image"`
+	Status        string `db:"status" json:"status"`
+	Spec          []byte `db:"spec" json:"spec"`
+	Tier          string `db:"tier" json:"tier"`
+}
+
+// Swaps a box's container together with the spec and tier it was created from
+func (q *Queries) ReplaceBoxContainer(ctx context.Context, arg ReplaceBoxContainerParams) error {
+	_, err := q.db.Exec(ctx, replaceBoxContainer,
+		arg.FingerprintID,
+		arg.ContainerID,
+		arg.Image,
+		arg.Status,
+		arg.Spec,
+		arg.Tier,
+	)
+	return err
+}
+
+const setBoxEnvStale = `-- name: SetBoxEnvStale :exec
+UPDATE box
+SET env_stale = $2
+WHERE fingerprint_id = $1
+`
+
+type SetBoxEnvStaleParams struct {
+	FingerprintID string `db:"fingerprint_id" json:"fingerprint_id"`
+	EnvStale      bool   `db:"env_stale" json:"env_stale"`
+}
+
+func (q *Queries) SetBoxEnvStale(ctx context.Context, arg SetBoxEnvStaleParams) error {
+	_, err := q.db.Exec(ctx, setBoxEnvStale, arg.FingerprintID, arg.EnvStale)
+	return err
+}
+
 const touchBox = `-- name: TouchBox :exec
 UPDATE box
-SET last_active = $2,
-    status = 'active'
+SET last_active = $2
 WHERE fingerprint_id = $1
 `
 
@@ -169,12 +375,70 @@ type TouchBoxParams struct {
 	LastActive    pgtype.Timestamp `db:"last_active" json:"last_active"`
 }
 
-// Updates last_active and ensures status is 'active'
+// Records terminal activity; status is owned by UpdateBoxStatus
 func (q *Queries) TouchBox(ctx context.Context, arg TouchBoxParams) error {
 	_, err := q.db.Exec(ctx, touchBox, arg.FingerprintID, arg.LastActive)
 	return err
 }
 
+const updateBoxContainer = `-- name: UpdateBoxContainer :exec
+UPDATE box
+SET container_id = $2,
+    image = $3,
+    status = $4
+WHERE fingerprint_id = $1
+`
+
+type UpdateBoxContainerParams struct {
+	FingerprintID string `db:"fingerprint_id" json:"fingerprint_id"`
+	ContainerID   string `db:"container_id" json:"container_id"`
+	Image         string `db:"image" json:"image"`
+	Status        string `db:"status" json:"status"`
+}
+
+// Used when a box's container is replaced, e.g. by hibernation
+func (q *Queries) UpdateBoxContainer(ctx context.Context, arg UpdateBoxContainerParams) error {
+	_, err := q.db.Exec(ctx, updateBoxContainer,
+		arg.FingerprintID,
+		arg.ContainerID,
+		arg.Image,
+		arg.Status,
+	)
+	return err
+}
+
+const updateBoxProvisionStatus = `-- name: UpdateBoxProvisionStatus :exec
+UPDATE box
+SET provision_status = $2
+WHERE fingerprint_id = $1
+`
+
+type UpdateBoxProvisionStatusParams struct {
+	FingerprintID   string `db:"fingerprint_id" json:"fingerprint_id"`
+	ProvisionStatus string `db:"provision_status" json:"provision_status"`
+}
+
+func (q *Queries) UpdateBoxProvisionStatus(ctx context.Context, arg UpdateBoxProvisionStatusParams) error {
+	_, err := q.db.Exec(ctx, updateBoxProvisionStatus, arg.FingerprintID, arg.ProvisionStatus)
+	return err
+}
+
+const updateBoxSpec = `-- name: UpdateBoxSpec :exec
+UPDATE box
+SET spec = $2
+WHERE fingerprint_id = $1
+`
+
+type UpdateBoxSpecParams struct {
+	FingerprintID string `db:"fingerprint_id" json:"fingerprint_id"`
+	Spec          []byte `db:"spec" json:"spec"`
+}
+
+func (q *Queries) UpdateBoxSpec(ctx context.Context, arg UpdateBoxSpecParams) error {
+	_, err := q.db.Exec(ctx, updateBoxSpec, arg.FingerprintID, arg.Spec)
+	return err
+}
+
 const updateBoxStatus = `-- name: UpdateBoxStatus :exec
 UPDATE box
 SET status = $2
@@ -5,14 +5,108 @@
 package db
 
 import (
+	"net/netip"
+
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
 type Box struct {
+	ID              uuid.UUID        `db:"id" json:"id"`
+	FingerprintID   string           `db:"fingerprint_id" json:"fingerprint_id"`
+	ContainerID     string           `db:"container_id" json:"container_id"`
+	Status          string           `db:"status" json:"status"`
+	LastActive      pgtype.Timestamp `db:"last_active" json:"last_active"`
+	Tier            string           `db:"tier" json:"tier"`
+	CreatedAt       pgtype.Timestamp `db:"created_at" json:"created_at"`
+	Image           string           `db:"image" json:"image"`
+	Spec            []byte           `db:"spec" json:"spec"`
+	ProvisionStatus string           `db:"provision_status" json:"provision_status"`
+	EnvStale        bool             `db:"env_stale" json:"env_stale"`
+}
+
+type BoxAbuseEvent struct {
 	ID            uuid.UUID        `db:"id" json:"id"`
 	FingerprintID string           `db:"fingerprint_id" json:"fingerprint_id"`
 	ContainerID   string           `db:"container_id" json:"container_id"`
-	Status        string           `db:"status" json:"status"`
-	LastActive    pgtype.Timestamp `db:"last_active" json:"last_active"`
+	Kind          string           `db:"kind" json:"kind"`
+	Detail        string           `db:"detail" json:"detail"`
+	Action        string           `db:"action" json:"action"`
+	CreatedAt     pgtype.Timestamp `db:"created_at" json:"created_at"`
+}
+
+type BoxConnectionLease struct {
+	FingerprintID string           `db:"fingerprint_id" json:"fingerprint_id"`
+	ReplicaID     string           `db:"replica_id" json:"replica_id"`
+	ExpiresAt     pgtype.Timestamp `db:"expires_at" json:"expires_at"`
+}
+
+type BoxSidecar struct {
+	ID          uuid.UUID        `db:"id" json:"id"`
+	BoxID       uuid.UUID        `db:"box_id" json:"box_id"`
+	Name        string           `db:"name" json:"name"`
+	Template    string           `db:"template" json:"template"`
+	ContainerID string           `db:"container_id" json:"container_id"`
+	CreatedAt   pgtype.Timestamp `db:"created_at" json:"created_at"`
+}
+
+type BoxSnapshot struct {
+	ID            uuid.UUID        `db:"id" json:"id"`
+	FingerprintID string           `db:"fingerprint_id" json:"fingerprint_id"`
+	BoxID         uuid.UUID        `db:"box_id" json:"box_id"`
+	Name          string           `db:"name" json:"name"`
+	Image         string           `db:"image" json:"image"`
+	SizeBytes     int64            `db:"size_bytes" json:"size_bytes"`
+	CreatedAt     pgtype.Timestamp `db:"created_at" json:"created_at"`
+}
+
+type Dotfile struct {
+	FingerprintID string           `db:"fingerprint_id" json:"fingerprint_id"`
+	Archive       []byte           `db:"archive" json:"archive"`
+	Files         []string         `db:"files" json:"files"`
+	SizeBytes     int64            `db:"size_bytes" json:"size_bytes"`
+	UpdatedAt     pgtype.Timestamp `db:"updated_at" json:"updated_at"`
+}
+
+type EnvVar struct {
+	FingerprintID string           `db:"fingerprint_id" json:"fingerprint_id"`
+	Name          string           `db:"name" json:"name"`
+	Value         pgtype.Text      `db:"value" json:"value"`
+	Ciphertext    []byte           `db:"ciphertext" json:"ciphertext"`
+	Injection     string           `db:"injection" json:"injection"`
+	UpdatedAt     pgtype.Timestamp `db:"updated_at" json:"updated_at"`
+}
+
+type Lab struct {
+	ID            uuid.UUID        `db:"id" json:"id"`
+	FingerprintID string           `db:"fingerprint_id" json:"fingerprint_id"`
+	Name          string           `db:"name" json:"name"`
+	Spec          []byte           `db:"spec" json:"spec"`
+	CreatedAt     pgtype.Timestamp `db:"created_at" json:"created_at"`
+}
+
+type LabNetwork struct {
+	LabID  uuid.UUID    `db:"lab_id" json:"lab_id"`
+	Name   string       `db:"name" json:"name"`
+	Subnet netip.Prefix `db:"subnet" json:"subnet"`
+}
+
+type LabNode struct {
+	LabID       uuid.UUID `db:"lab_id" json:"lab_id"`
+	Name        string    `db:"name" json:"name"`
+	ContainerID string    `db:"container_id" json:"container_id"`
+	Addresses   []byte    `db:"addresses" json:"addresses"`
+}
+
+type PowChallenge struct {
+	Nonce     string           `db:"nonce" json:"nonce"`
+	ClientIp  string           `db:"client_ip" json:"client_ip"`
+	ExpiresAt pgtype.Timestamp `db:"expires_at" json:"expires_at"`
+	CreatedAt pgtype.Timestamp `db:"created_at" json:"created_at"`
+}
+
+type SubnetAllocation struct {
+	Network     string           `db:"network" json:"network"`
+	Subnet      netip.Prefix     `db:"subnet" json:"subnet"`
+	AllocatedAt pgtype.Timestamp `db:"allocated_at" json:"allocated_at"`
 }
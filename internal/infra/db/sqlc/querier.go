@@ -7,20 +7,89 @@ package db
 import (
 	"context"
 
+	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
 type Querier interface {
+	AdvisoryUnlock(ctx context.Context, key int64) (bool, error)
+	// Moves a pending box to running so only one connection provisions it
+	ClaimBoxProvision(ctx context.Context, fingerprintID string) (int64, error)
+	// Used to escalate an owner's repeat offences
+	CountBoxAbuseEventsSince(ctx context.Context, arg CountBoxAbuseEventsSinceParams) (int64, error)
+	// Used to cap the containers running at once
+	CountBoxesByStatus(ctx context.Context, status string) (int64, error)
+	// Used before pausing or removing a box another replica may be serving
+	CountOtherBoxConnectionLeases(ctx context.Context, arg CountOtherBoxConnectionLeasesParams) (int64, error)
+	// Used to raise the difficulty for IPs creating many boxes
+	CountPowChallengesByIP(ctx context.Context, arg CountPowChallengesByIPParams) (int64, error)
 	CreateBox(ctx context.Context, arg CreateBoxParams) (Box, error)
+	CreateBoxAbuseEvent(ctx context.Context, arg CreateBoxAbuseEventParams) (BoxAbuseEvent, error)
+	CreateBoxSidecar(ctx context.Context, arg CreateBoxSidecarParams) (BoxSidecar, error)
+	CreateBoxSnapshot(ctx context.Context, arg CreateBoxSnapshotParams) (BoxSnapshot, error)
+	CreateLab(ctx context.Context, arg CreateLabParams) (Lab, error)
+	CreateLabNetwork(ctx context.Context, arg CreateLabNetworkParams) error
+	CreateLabNode(ctx context.Context, arg CreateLabNodeParams) error
+	CreateSubnetAllocation(ctx context.Context, arg CreateSubnetAllocationParams) error
 	DeleteBox(ctx context.Context, fingerprintID string) error
+	DeleteBoxConnectionLease(ctx context.Context, arg DeleteBoxConnectionLeaseParams) error
+	// Releases a replica's leases when it shuts down
+	DeleteBoxConnectionLeasesByReplica(ctx context.Context, replicaID string) error
+	// Deletes a box only while it still has the container, image and status the caller read
+	DeleteBoxIfUnchanged(ctx context.Context, arg DeleteBoxIfUnchangedParams) (int64, error)
+	DeleteBoxSidecar(ctx context.Context, id uuid.UUID) error
+	DeleteBoxSnapshot(ctx context.Context, id uuid.UUID) error
+	DeleteDotfiles(ctx context.Context, fingerprintID string) error
+	DeleteEnvVar(ctx context.Context, arg DeleteEnvVarParams) (int64, error)
+	// Drops the leases of replicas that stopped renewing them
+	DeleteExpiredBoxConnectionLeases(ctx context.Context, expiresAt pgtype.Timestamp) (int64, error)
+	// Drops challenges that expired and no longer count against their IP
+	DeleteExpiredPowChallenges(ctx context.Context, arg DeleteExpiredPowChallengesParams) (int64, error)
+	DeleteLab(ctx context.Context, id uuid.UUID) error
+	DeleteSubnetAllocation(ctx context.Context, network string) error
 	GetBoxByContainerID(ctx context.Context, containerID string) (Box, error)
 	GetBoxByFingerprint(ctx context.Context, fingerprintID string) (Box, error)
-	// Used by the 24h cleanup worker
+	GetBoxByID(ctx context.Context, id uuid.UUID) (Box, error)
+	GetBoxSnapshot(ctx context.Context, arg GetBoxSnapshotParams) (BoxSnapshot, error)
+	// Used for the per-owner snapshot quota
+	GetBoxSnapshotUsage(ctx context.Context, fingerprintID string) (GetBoxSnapshotUsageRow, error)
+	// Used by the lifecycle scheduler's hard expiry sweep
+	GetBoxesCreatedBefore(ctx context.Context, createdAt pgtype.Timestamp) ([]Box, error)
+	GetDotfiles(ctx context.Context, fingerprintID string) (Dotfile, error)
+	// Used by the lifecycle scheduler's inactivity sweep
 	GetExpiredBoxes(ctx context.Context, lastActive pgtype.Timestamp) ([]Box, error)
+	GetLatestBoxAbuseBan(ctx context.Context, fingerprintID string) (BoxAbuseEvent, error)
+	GetLab(ctx context.Context, arg GetLabParams) (Lab, error)
+	// Used to tell terminals on every replica what the watchdog did
+	ListBoxAbuseEventsSince(ctx context.Context, arg ListBoxAbuseEventsSinceParams) ([]BoxAbuseEvent, error)
+	ListBoxSidecarsByFingerprint(ctx context.Context, fingerprintID string) ([]BoxSidecar, error)
+	ListBoxSnapshotsByFingerprint(ctx context.Context, fingerprintID string) ([]BoxSnapshot, error)
 	ListBoxesByStatus(ctx context.Context, status string) ([]Box, error)
-	// Updates last_active and ensures status is 'active'
+	ListEnvVars(ctx context.Context, fingerprintID string) ([]EnvVar, error)
+	ListLabNetworks(ctx context.Context, labID uuid.UUID) ([]LabNetwork, error)
+	ListLabNodes(ctx context.Context, labID uuid.UUID) ([]LabNode, error)
+	ListLabsByFingerprint(ctx context.Context, fingerprintID string) ([]Lab, error)
+	// Used by the lab expiry sweep
+	ListLabsCreatedBefore(ctx context.Context, createdAt pgtype.Timestamp) ([]Lab, error)
+	ListSubnetAllocations(ctx context.Context) ([]SubnetAllocation, error)
+	// Swaps a box's container together with the spec and tier it was created from
+	ReplaceBoxContainer(ctx context.Context, arg ReplaceBoxContainerParams) error
+	SetBoxEnvStale(ctx context.Context, arg SetBoxEnvStaleParams) error
+	// Inserts nothing when the challenge was already spent
+	SpendPowChallenge(ctx context.Context, arg SpendPowChallengeParams) (int64, error)
+	// Records terminal activity; status is owned by UpdateBoxStatus
 	TouchBox(ctx context.Context, arg TouchBoxParams) error
+	// Session-level lock; it must be released on the same connection
+	TryAdvisoryLock(ctx context.Context, key int64) (bool, error)
+	// Used when a box's container is replaced, e.g. by hibernation
+	UpdateBoxContainer(ctx context.Context, arg UpdateBoxContainerParams) error
+	UpdateBoxProvisionStatus(ctx context.Context, arg UpdateBoxProvisionStatusParams) error
+	UpdateBoxSpec(ctx context.Context, arg UpdateBoxSpecParams) error
 	UpdateBoxStatus(ctx context.Context, arg UpdateBoxStatusParams) error
+	// Takes or renews the replica's leases on the given boxes
+	UpsertBoxConnectionLeases(ctx context.Context, arg UpsertBoxConnectionLeasesParams) error
+	UpsertDotfiles(ctx context.Context, arg UpsertDotfilesParams) (Dotfile, error)
+	UpsertEnvVar(ctx context.Context, arg UpsertEnvVarParams) (EnvVar, error)
 }
 
 var _ Querier = (*Queries)(nil)
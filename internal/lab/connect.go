@@ -0,0 +1,104 @@
+package lab
+
+import (
+	"context"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/faiyaz032/gobox/internal/domain"
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+	"go.uber.org/zap"
+)
+
+// Connect attaches a terminal to one node of the owner's lab. The
+// connection closes when the lab is torn down.
+func (s *Svc) Connect(ctx context.Context, conn *websocket.Conn, fingerprint string, id uuid.UUID, nodeName string) error {
+	if strings.TrimSpace(fingerprint) == "" {
+		return domain.NewValidationError("fingerprint cannot be empty")
+	}
+
+	lab, err := s.repo.Get(ctx, fingerprint, id)
+	if err != nil {
+		return err
+	}
+	var node *domain.LabNode
+	for i := range lab.Nodes {
+		if lab.Nodes[i].Name == nodeName {
+			node = &lab.Nodes[i]
+		}
+	}
+	if node == nil {
+		return domain.NewNotFoundError("lab node", nodeName)
+	}
+
+	// a node stopped, e.g. by a daemon restart, needs a slot to start
+	running, err := s.dockerSvc.ContainerRunning(ctx, node.ContainerID)
+	if err != nil {
+		return err
+	}
+	if !running {
+		release, err := s.admission.Reserve(ctx, 1)
+		if err != nil {
+			return err
+		}
+		err = s.dockerSvc.StartIfNotRunning(ctx, node.ContainerID)
+		release()
+		if err != nil {
+			return err
+		}
+	}
+	attachResp, err := s.dockerSvc.AttachContainer(ctx, node.ContainerID)
+	if err != nil {
+		return err
+	}
+	defer attachResp.Close()
+
+	if !s.trackTerminal(conn) {
+		return domain.NewRateLimitError("server restarting; reconnect shortly", 5*time.Second)
+	}
+	defer s.untrackTerminal(conn)
+
+	s.logger.Info("Attached to lab node",
+		zap.String("fingerprint", fingerprint),
+		zap.String("lab_id", id.String()),
+		zap.String("node", nodeName))
+
+	// container → websocket; the read loop below ends once the container
+	// output does, e.g. because the lab was deleted
+	go func() {
+		buf := make([]byte, 8192)
+		for {
+			n, err := attachResp.Reader.Read(buf)
+			if n > 0 {
+				if werr := conn.WriteMessage(websocket.BinaryMessage, buf[:n]); werr != nil {
+					break
+				}
+			}
+			if err != nil {
+				if err != io.EOF {
+					s.logger.Error("Lab node read error", zap.Error(err))
+				}
+				break
+			}
+		}
+		_ = conn.Close()
+	}()
+
+	// websocket input → container stdin
+	for {
+		_, msg, err := conn.ReadMessage()
+		if err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				s.logger.Info("Lab terminal closed", zap.String("node", nodeName), zap.Error(err))
+			}
+			return nil
+		}
+		if len(msg) > 0 {
+			if _, err := attachResp.Conn.Write(msg); err != nil {
+				return domain.NewInternalError("container write error", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,398 @@
+package lab
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/faiyaz032/gobox/internal/domain"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// Labels put on the containers and networks of a lab so teardown finds
+// every part, including ones left by a failed creation.
+const (
+	labelKind  = "gobox.kind"
+	labelOwner = "gobox.owner"
+	labelLab   = "gobox.lab"
+
+	kindLab = "lab"
+)
+
+// Limits on what a single lab spec may ask for.
+const (
+	maxLabNetworks    = 4
+	maxLabNodes       = 6
+	defaultLabPrefix  = 28
+	largestLabPrefix  = 24
+	smallestLabPrefix = 29
+)
+
+// names are DNS labels so nodes can reach each other by name
+var namePattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]{0,30}[a-z0-9])?$`)
+
+// CreateLab creates the networks and nodes of a lab spec. Each network gets
+// a subnet from the configured pool and each node a static address on its
+// networks. Like a box it requires proof, a solved challenge, and its
+// nodes count towards the running container cap. A lab that cannot be
+// fully created is torn down.
+func (s *Svc) CreateLab(ctx context.Context, fingerprint string, spec domain.LabSpec, proof domain.ProofOfWork) (*domain.Lab, error) {
+	if strings.TrimSpace(fingerprint) == "" {
+		return nil, domain.NewValidationError("fingerprint cannot be empty")
+	}
+
+	spec = s.resolveSpec(spec)
+	if err := s.validateSpec(spec); err != nil {
+		return nil, err
+	}
+
+	labs, err := s.repo.ListByFingerprint(ctx, fingerprint)
+	if err != nil {
+		return nil, err
+	}
+	if s.cfg.MaxPerOwner > 0 && len(labs) >= s.cfg.MaxPerOwner {
+		return nil, domain.NewConflictError(fmt.Sprintf("cannot run more than %d labs; delete one first", s.cfg.MaxPerOwner))
+	}
+
+	if err := s.admission.VerifyProof(ctx, fingerprint, proof); err != nil {
+		return nil, err
+	}
+	release, err := s.admission.Reserve(ctx, len(spec.Nodes))
+	if err != nil {
+		return nil, err
+	}
+	// until the nodes run they hold start slots
+	defer release()
+
+	lab, err := s.repo.Create(ctx, domain.Lab{
+		FingerprintID: fingerprint,
+		Name:          spec.Name,
+		Spec:          spec,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.build(ctx, lab); err != nil {
+		_ = s.teardown(context.Background(), *lab)
+		return nil, err
+	}
+
+	s.logger.Info("Created lab",
+		zap.String("fingerprint", fingerprint),
+		zap.String("lab_id", lab.ID.String()),
+		zap.Int("networks", len(spec.Networks)),
+		zap.Int("nodes", len(spec.Nodes)))
+	return s.repo.Get(ctx, fingerprint, lab.ID)
+}
+
+func (s *Svc) build(ctx context.Context, lab *domain.Lab) error {
+	labels := map[string]string{
+		labelKind:  kindLab,
+		labelOwner: lab.FingerprintID,
+		labelLab:   lab.ID.String(),
+	}
+
+	subnets := make(map[string]netip.Prefix, len(lab.Spec.Networks))
+	for _, n := range lab.Spec.Networks {
+		subnet, err := s.dockerSvc.CreateSubnetNetwork(ctx, networkName(lab.ID, n.Name), n.Prefix, labels)
+		if err != nil {
+			return err
+		}
+		if err := s.repo.AddNetwork(ctx, lab.ID, domain.LabNetwork{Name: n.Name, Subnet: subnet}); err != nil {
+			return err
+		}
+		subnets[n.Name] = subnet
+	}
+
+	for i, addresses := range assignHosts(lab.Spec) {
+		node := lab.Spec.Nodes[i]
+		byName := make(map[string]netip.Addr, len(addresses))
+		byNetwork := make(map[string]netip.Addr, len(addresses))
+		for network, host := range addresses {
+			addr, _ := domain.SubnetHost(subnets[network], host)
+			byName[network] = addr
+			byNetwork[networkName(lab.ID, network)] = addr
+		}
+
+		containerID, err := s.dockerSvc.CreateLabNode(ctx, domain.LabNodeOptions{
+			Image:     s.boxCfg.Templates[node.Template].Image,
+			Hostname:  node.Name,
+			Resources: domain.DefaultResources,
+			Addresses: byNetwork,
+			Router:    node.Router,
+			Labels:    labels,
+			Security:  s.boxCfg.SecurityOptions(node.Template),
+			Runtime:   s.boxCfg.Templates[node.Template].Runtime,
+		})
+		if err != nil {
+			return err
+		}
+		if err := s.repo.AddNode(ctx, lab.ID, domain.LabNode{
+			Name:        node.Name,
+			ContainerID: containerID,
+			Addresses:   byName,
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Svc) ListLabs(ctx context.Context, fingerprint string) ([]domain.Lab, error) {
+	if strings.TrimSpace(fingerprint) == "" {
+		return nil, domain.NewValidationError("fingerprint cannot be empty")
+	}
+	return s.repo.ListByFingerprint(ctx, fingerprint)
+}
+
+func (s *Svc) GetLab(ctx context.Context, fingerprint string, id uuid.UUID) (*domain.Lab, error) {
+	if strings.TrimSpace(fingerprint) == "" {
+		return nil, domain.NewValidationError("fingerprint cannot be empty")
+	}
+	return s.repo.Get(ctx, fingerprint, id)
+}
+
+// DeleteLab tears down one of the owner's labs, closing its terminals.
+func (s *Svc) DeleteLab(ctx context.Context, fingerprint string, id uuid.UUID) error {
+	if strings.TrimSpace(fingerprint) == "" {
+		return domain.NewValidationError("fingerprint cannot be empty")
+	}
+
+	lab, err := s.repo.Get(ctx, fingerprint, id)
+	if err != nil {
+		return err
+	}
+	if err := s.teardown(ctx, *lab); err != nil {
+		return err
+	}
+
+	s.logger.Info("Deleted lab",
+		zap.String("fingerprint", fingerprint),
+		zap.String("lab_id", id.String()))
+	return nil
+}
+
+// teardown removes every container and network of a lab, then its rows.
+func (s *Svc) teardown(ctx context.Context, lab domain.Lab) error {
+	if err := s.dockerSvc.RemoveByLabel(ctx, labelLab+"="+lab.ID.String()); err != nil {
+		s.logger.Error("Failed to remove lab resources",
+			zap.String("lab_id", lab.ID.String()),
+			zap.Error(err))
+		return err
+	}
+	return s.repo.Delete(ctx, lab.ID)
+}
+
+// runReaper deletes labs that outlived the configured TTL.
+func (s *Svc) runReaper() {
+	if s.cfg.TTL <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-s.stopping:
+			return
+		}
+
+		_, err := s.locker.TryWithLock(context.Background(), domain.LockLabReaper, s.reapExpired)
+		if err != nil {
+			s.logger.Error("failed to take the lab reaper lock", zap.Error(err))
+		}
+	}
+}
+
+// reapExpired tears down labs past their TTL. It runs on one replica at a
+// time.
+func (s *Svc) reapExpired() {
+	labs, err := s.repo.ListCreatedBefore(context.Background(), time.Now().Add(-s.cfg.TTL))
+	if err != nil {
+		s.logger.Error("failed to list expired labs", zap.Error(err))
+		return
+	}
+	for _, lab := range labs {
+		if err := s.teardown(context.Background(), lab); err == nil {
+			s.logger.Info("removed expired lab", zap.String("lab_id", lab.ID.String()))
+		}
+	}
+}
+
+// resolveSpec fills the fields a lab spec leaves empty with the server
+// defaults.
+func (s *Svc) resolveSpec(spec domain.LabSpec) domain.LabSpec {
+	networks := make([]domain.LabNetworkSpec, len(spec.Networks))
+	for i, n := range spec.Networks {
+		if n.Prefix == 0 {
+			n.Prefix = defaultLabPrefix
+		}
+		networks[i] = n
+	}
+	spec.Networks = networks
+
+	nodes := make([]domain.LabNodeSpec, len(spec.Nodes))
+	for i, n := range spec.Nodes {
+		if n.Template == "" {
+			n.Template = s.boxCfg.DefaultTemplate
+		}
+		nodes[i] = n
+	}
+	spec.Nodes = nodes
+	return spec
+}
+
+// validateSpec checks a resolved lab spec, reporting every problem with
+// the field it concerns.
+func (s *Svc) validateSpec(spec domain.LabSpec) error {
+	var problems []string
+	addf := func(field, format string, args ...any) {
+		problems = append(problems, field+": "+fmt.Sprintf(format, args...))
+	}
+
+	if !namePattern.MatchString(spec.Name) {
+		addf("name", "must be a lowercase hostname of up to 32 characters")
+	}
+
+	if len(spec.Networks) == 0 || len(spec.Networks) > maxLabNetworks {
+		addf("networks", "must have between 1 and %d entries", maxLabNetworks)
+	}
+	prefixes := make(map[string]int)
+	for i, n := range spec.Networks {
+		field := fmt.Sprintf("networks[%d]", i)
+		if !namePattern.MatchString(n.Name) {
+			addf(field+".name", "must be a lowercase hostname of up to 32 characters")
+		}
+		if _, ok := prefixes[n.Name]; ok {
+			addf(field+".name", "duplicate name %q", n.Name)
+		}
+		if n.Prefix < largestLabPrefix || n.Prefix > smallestLabPrefix {
+			addf(field+".prefix", "must be between %d and %d", largestLabPrefix, smallestLabPrefix)
+		}
+		prefixes[n.Name] = n.Prefix
+	}
+
+	if len(spec.Nodes) == 0 || len(spec.Nodes) > maxLabNodes {
+		addf("nodes", "must have between 1 and %d entries", maxLabNodes)
+	}
+	nodes := make(map[string]bool)
+	hosts := make(map[string]map[int]bool)
+	for i, n := range spec.Nodes {
+		field := fmt.Sprintf("nodes[%d]", i)
+		if !namePattern.MatchString(n.Name) {
+			addf(field+".name", "must be a lowercase hostname of up to 32 characters")
+		}
+		if nodes[n.Name] {
+			addf(field+".name", "duplicate name %q", n.Name)
+		}
+		nodes[n.Name] = true
+		if _, ok := s.boxCfg.Templates[n.Template]; !ok {
+			addf(field+".template", "unknown template %q", n.Template)
+		}
+		if len(n.Interfaces) == 0 {
+			addf(field+".interfaces", "must attach the node to a network")
+		}
+
+		attached := make(map[string]bool)
+		for j, iface := range n.Interfaces {
+			ifield := fmt.Sprintf("%s.interfaces[%d]", field, j)
+			prefix, ok := prefixes[iface.Network]
+			if !ok {
+				addf(ifield+".network", "unknown network %q", iface.Network)
+				continue
+			}
+			if attached[iface.Network] {
+				addf(ifield+".network", "node is already attached to %q", iface.Network)
+			}
+			attached[iface.Network] = true
+
+			if iface.Host == 0 {
+				continue
+			}
+			// host numbers only depend on the prefix length
+			if _, ok := domain.SubnetHost(netip.PrefixFrom(netip.IPv4Unspecified(), prefix), iface.Host); !ok {
+				addf(ifield+".host", "must be between 1 and %d on a /%d", (1<<(32-prefix))-3, prefix)
+			}
+			if hosts[iface.Network] == nil {
+				hosts[iface.Network] = make(map[int]bool)
+			}
+			if hosts[iface.Network][iface.Host] {
+				addf(ifield+".host", "host %d is already used on %q", iface.Host, iface.Network)
+			}
+			hosts[iface.Network][iface.Host] = true
+		}
+	}
+	if len(problems) == 0 {
+		for network, prefix := range prefixes {
+			if len(assignable(spec, network)) > (1<<(32-prefix))-3 {
+				addf("networks", "%q has more interfaces than a /%d holds", network, prefix)
+			}
+		}
+	}
+
+	if len(problems) == 0 {
+		return nil
+	}
+	return domain.NewValidationError("invalid lab spec: " + strings.Join(problems, "; "))
+}
+
+// assignable lists the interfaces attached to a network.
+func assignable(spec domain.LabSpec, network string) []domain.LabInterfaceSpec {
+	var ifaces []domain.LabInterfaceSpec
+	for _, n := range spec.Nodes {
+		for _, iface := range n.Interfaces {
+			if iface.Network == network {
+				ifaces = append(ifaces, iface)
+			}
+		}
+	}
+	return ifaces
+}
+
+// assignHosts returns, for each node, its host number on each of its
+// networks. Interfaces without a host get the lowest free one in node
+// order, so a spec always yields the same addresses.
+func assignHosts(spec domain.LabSpec) []map[string]int {
+	taken := make(map[string]map[int]bool)
+	for _, n := range spec.Nodes {
+		for _, iface := range n.Interfaces {
+			if taken[iface.Network] == nil {
+				taken[iface.Network] = make(map[int]bool)
+			}
+			if iface.Host != 0 {
+				taken[iface.Network][iface.Host] = true
+			}
+		}
+	}
+
+	next := make(map[string]int)
+	assigned := make([]map[string]int, len(spec.Nodes))
+	for i, n := range spec.Nodes {
+		assigned[i] = make(map[string]int, len(n.Interfaces))
+		for _, iface := range n.Interfaces {
+			host := iface.Host
+			if host == 0 {
+				host = next[iface.Network] + 1
+				for taken[iface.Network][host] {
+					host++
+				}
+				taken[iface.Network][host] = true
+				next[iface.Network] = host
+			}
+			assigned[i][iface.Network] = host
+		}
+	}
+	return assigned
+}
+
+// networkName is the Docker network of a lab network.
+func networkName(labID uuid.UUID, name string) string {
+	return "gobox-lab-" + labID.String() + "-" + name
+}
@@ -0,0 +1,42 @@
+package lab
+
+import (
+	"context"
+	"net/netip"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/faiyaz032/gobox/internal/domain"
+	"github.com/google/uuid"
+)
+
+type Repo interface {
+	Create(context.Context, domain.Lab) (*domain.Lab, error)
+	AddNetwork(context.Context, uuid.UUID, domain.LabNetwork) error
+	AddNode(context.Context, uuid.UUID, domain.LabNode) error
+	Get(context.Context, string, uuid.UUID) (*domain.Lab, error)
+	ListByFingerprint(context.Context, string) ([]domain.Lab, error)
+	ListCreatedBefore(context.Context, time.Time) ([]domain.Lab, error)
+	Delete(context.Context, uuid.UUID) error
+}
+
+// Admission is the box service's gate on new containers, shared so labs
+// count towards the same cap and need the same proof of work.
+type Admission interface {
+	Reserve(ctx context.Context, containers int) (func(), error)
+	VerifyProof(ctx context.Context, fingerprint string, proof domain.ProofOfWork) error
+}
+
+// Locker runs jobs that must not run on several replicas at once.
+type Locker interface {
+	TryWithLock(ctx context.Context, key domain.LockKey, fn func()) (bool, error)
+}
+
+type DockerSvc interface {
+	CreateSubnetNetwork(ctx context.Context, name string, bits int, labels map[string]string) (netip.Prefix, error)
+	CreateLabNode(ctx context.Context, opts domain.LabNodeOptions) (string, error)
+	ContainerRunning(ctx context.Context, containerID string) (bool, error)
+	StartIfNotRunning(ctx context.Context, containerID string) error
+	AttachContainer(ctx context.Context, containerID string) (types.HijackedResponse, error)
+	RemoveByLabel(ctx context.Context, label string) error
+}
@@ -0,0 +1,95 @@
+package lab
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/faiyaz032/gobox/internal/config"
+	"github.com/gorilla/websocket"
+	"go.uber.org/zap"
+)
+
+type Svc struct {
+	repo      Repo
+	locker    Locker
+	admission Admission
+	dockerSvc DockerSvc
+	cfg       config.LabConfig
+	boxCfg    config.BoxConfig
+	logger    *zap.Logger
+
+	// stopping is closed by Shutdown to end the reaper.
+	stopping chan struct{}
+	reaper   sync.WaitGroup
+	// terminals holds the attached node terminals, closed on shutdown.
+	mu        sync.Mutex
+	terminals map[*websocket.Conn]struct{}
+}
+
+func NewSvc(repo Repo, locker Locker, admission Admission, dockerSvc DockerSvc, cfg config.LabConfig, boxCfg config.BoxConfig, logger *zap.Logger) *Svc {
+	svc := &Svc{
+		repo:      repo,
+		locker:    locker,
+		admission: admission,
+		dockerSvc: dockerSvc,
+		cfg:       cfg,
+		boxCfg:    boxCfg,
+		logger:    logger,
+		stopping:  make(chan struct{}),
+		terminals: make(map[*websocket.Conn]struct{}),
+	}
+
+	svc.reaper.Go(svc.runReaper)
+
+	return svc
+}
+
+// Shutdown closes attached node terminals with a service restart code,
+// telling clients to reconnect, and waits for the reaper to finish the
+// teardown it is in.
+func (s *Svc) Shutdown(ctx context.Context) error {
+	close(s.stopping)
+
+	s.mu.Lock()
+	for conn := range s.terminals {
+		_ = conn.WriteControl(websocket.CloseMessage,
+			websocket.FormatCloseMessage(websocket.CloseServiceRestart, "server restarting, reconnect shortly"),
+			time.Now().Add(time.Second))
+		_ = conn.Close()
+	}
+	s.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		s.reaper.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// trackTerminal registers conn for Shutdown to close. It reports false
+// once shutdown has begun.
+func (s *Svc) trackTerminal(conn *websocket.Conn) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	select {
+	case <-s.stopping:
+		return false
+	default:
+	}
+	s.terminals[conn] = struct{}{}
+	return true
+}
+
+func (s *Svc) untrackTerminal(conn *websocket.Conn) {
+	s.mu.Lock()
+	delete(s.terminals, conn)
+	s.mu.Unlock()
+}
@@ -0,0 +1,185 @@
+package pow
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"math/bits"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/faiyaz032/gobox/internal/domain"
+)
+
+const (
+	// rateWindow is how long a creation raises the difficulty for its IP.
+	rateWindow = time.Hour
+	// loadBits is the extra difficulty at full capacity; the queue beyond
+	// it adds proportionally more.
+	loadBits = 4
+	// maxSolutionLen bounds the solution a client may present.
+	maxSolutionLen = 32
+	tokenVersion   = "v1"
+)
+
+// Issuer hands out signed challenges and verifies their solutions. A
+// challenge is bound to an owner, expires after its TTL and is accepted
+// once. Replicas sharing the key and store accept each other's challenges.
+type Issuer struct {
+	key   []byte
+	base  int
+	max   int
+	ttl   time.Duration
+	store Store
+}
+
+// NewIssuer returns an issuer signing with key whose difficulty, in
+// leading zero bits, ranges from base to max.
+func NewIssuer(key []byte, base, max int, ttl time.Duration, store Store) *Issuer {
+	return &Issuer{
+		key:   key,
+		base:  base,
+		max:   max,
+		ttl:   ttl,
+		store: store,
+	}
+}
+
+// Issue returns a challenge for the owner requesting from ip. load is the
+// share of box capacity in use, queued connects included; it and the IP's
+// recent creations raise the difficulty.
+func (i *Issuer) Issue(ctx context.Context, fingerprint, ip string, load float64) (domain.PowChallenge, error) {
+	now := time.Now()
+
+	var recent int64
+	if ip != "" {
+		var err error
+		recent, err = i.store.CountByIP(ctx, ip, now.Add(-rateWindow))
+		if err != nil {
+			return domain.PowChallenge{}, err
+		}
+	}
+
+	difficulty := i.base + int(load*loadBits) + bits.Len(uint(recent))
+	difficulty = min(difficulty, i.max)
+
+	nonce := make([]byte, 16)
+	_, _ = rand.Read(nonce)
+	expiresAt := now.Add(i.ttl)
+
+	payload := strings.Join([]string{
+		tokenVersion,
+		owner(fingerprint),
+		hex.EncodeToString(nonce),
+		strconv.Itoa(difficulty),
+		strconv.FormatInt(expiresAt.Unix(), 10),
+	}, "|")
+
+	return domain.PowChallenge{
+		Challenge:  encode([]byte(payload)) + "." + encode(i.sign(payload)),
+		Difficulty: difficulty,
+		ExpiresAt:  expiresAt,
+	}, nil
+}
+
+// Verify checks that proof solves a live challenge issued to the owner,
+// then spends the challenge and counts a creation against the proof's IP.
+func (i *Issuer) Verify(ctx context.Context, fingerprint string, proof domain.ProofOfWork) error {
+	if proof.Challenge == "" || proof.Solution == "" {
+		return domain.NewForbiddenError("a solved proof-of-work challenge is required to create a box")
+	}
+	if len(proof.Solution) > maxSolutionLen {
+		return domain.NewForbiddenError("invalid proof-of-work solution")
+	}
+
+	encodedPayload, encodedMAC, ok := strings.Cut(proof.Challenge, ".")
+	if !ok {
+		return domain.NewForbiddenError("invalid proof-of-work challenge")
+	}
+	payload, err := decode(encodedPayload)
+	if err != nil {
+		return domain.NewForbiddenError("invalid proof-of-work challenge")
+	}
+	mac, err := decode(encodedMAC)
+	if err != nil || !hmac.Equal(mac, i.sign(string(payload))) {
+		return domain.NewForbiddenError("invalid proof-of-work challenge")
+	}
+
+	fields := strings.Split(string(payload), "|")
+	if len(fields) != 5 || fields[0] != tokenVersion {
+		return domain.NewForbiddenError("invalid proof-of-work challenge")
+	}
+	if fields[1] != owner(fingerprint) {
+		return domain.NewForbiddenError("proof-of-work challenge was issued to another owner")
+	}
+	difficulty, err := strconv.Atoi(fields[3])
+	if err != nil {
+		return domain.NewForbiddenError("invalid proof-of-work challenge")
+	}
+	expiry, err := strconv.ParseInt(fields[4], 10, 64)
+	if err != nil {
+		return domain.NewForbiddenError("invalid proof-of-work challenge")
+	}
+	expiresAt := time.Unix(expiry, 0)
+
+	now := time.Now()
+	if now.After(expiresAt) {
+		return domain.NewForbiddenError("proof-of-work challenge expired; request a new one")
+	}
+	if leadingZeroBits(sha256.Sum256([]byte(proof.Challenge+":"+proof.Solution))) < difficulty {
+		return domain.NewForbiddenError("proof-of-work solution does not meet the challenge difficulty")
+	}
+
+	// spent challenges are kept while they count against their IP
+	if _, err := i.store.DeleteExpired(ctx, now, now.Add(-rateWindow)); err != nil {
+		return err
+	}
+	spent, err := i.store.Spend(ctx, fields[2], proof.ClientIP, expiresAt)
+	if err != nil {
+		return err
+	}
+	if !spent {
+		return domain.NewForbiddenError("proof-of-work challenge was already used; request a new one")
+	}
+	return nil
+}
+
+func (i *Issuer) sign(payload string) []byte {
+	mac := hmac.New(sha256.New, i.key)
+	mac.Write([]byte(payload))
+	return mac.Sum(nil)
+}
+
+// owner identifies the fingerprint in a challenge without revealing it.
+func owner(fingerprint string) string {
+	sum := sha256.Sum256([]byte(fingerprint))
+	return hex.EncodeToString(sum[:8])
+}
+
+func leadingZeroBits(sum [sha256.Size]byte) int {
+	n := 0
+	for _, b := range sum {
+		if b != 0 {
+			return n + bits.LeadingZeros8(b)
+		}
+		n += 8
+	}
+	return n
+}
+
+func encode(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decode(s string) ([]byte, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("decode challenge: %w", err)
+	}
+	return b, nil
+}
@@ -0,0 +1,14 @@
+package pow
+
+import (
+	"context"
+	"time"
+)
+
+// Store records the challenges accepted by any replica, so a challenge is
+// spent everywhere at once and an IP's creations count on every replica.
+type Store interface {
+	Spend(ctx context.Context, nonce, clientIP string, expiresAt time.Time) (bool, error)
+	CountByIP(ctx context.Context, clientIP string, since time.Time) (int64, error)
+	DeleteExpired(ctx context.Context, now, since time.Time) (int64, error)
+}
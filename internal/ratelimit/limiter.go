@@ -0,0 +1,78 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// minSweepInterval keeps fast-refilling limiters from scanning every
+// bucket on each request.
+const minSweepInterval = time.Minute
+
+type bucket struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// Limiter is a set of token buckets keyed by e.g. client IP or owner.
+type Limiter struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	limit     rate.Limit
+	burst     int
+	lastSweep time.Time
+	// idleAfter is how long a key's bucket is kept after its last use:
+	// the time an empty bucket takes to refill, so dropping it loses
+	// nothing.
+	idleAfter time.Duration
+}
+
+// New returns a limiter refilling perMinute tokens a minute into buckets
+// holding up to burst tokens. perMinute must be positive and burst at
+// least 1.
+func New(perMinute float64, burst int) *Limiter {
+	return &Limiter{
+		buckets:   make(map[string]*bucket),
+		limit:     rate.Limit(perMinute / 60),
+		burst:     burst,
+		lastSweep: time.Now(),
+		idleAfter: time.Duration(float64(burst) / perMinute * float64(time.Minute)),
+	}
+}
+
+// Allow takes a token from key's bucket. When the bucket is empty it
+// returns false and how long until a token is available.
+func (l *Limiter) Allow(key string) (bool, time.Duration) {
+	now := time.Now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if now.Sub(l.lastSweep) > max(l.idleAfter, minSweepInterval) {
+		for k, b := range l.buckets {
+			if now.Sub(b.lastSeen) > l.idleAfter {
+				delete(l.buckets, k)
+			}
+		}
+		l.lastSweep = now
+	}
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{limiter: rate.NewLimiter(l.limit, l.burst)}
+		l.buckets[key] = b
+	}
+	b.lastSeen = now
+
+	r := b.limiter.ReserveN(now, 1)
+	if !r.OK() {
+		return false, l.idleAfter
+	}
+	if delay := r.DelayFrom(now); delay > 0 {
+		r.CancelAt(now)
+		return false, delay
+	}
+	return true, 0
+}
@@ -0,0 +1,104 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/faiyaz032/gobox/internal/domain"
+	db "github.com/faiyaz032/gobox/internal/infra/db/sqlc"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+type AbuseRepo struct {
+	queries *db.Queries
+}
+
+func NewAbuseRepo(queries *db.Queries) *AbuseRepo {
+	return &AbuseRepo{
+		queries: queries,
+	}
+}
+
+func (r *AbuseRepo) Create(ctx context.Context, event domain.AbuseEvent) (*domain.AbuseEvent, error) {
+	dbEvent, err := r.queries.CreateBoxAbuseEvent(ctx, db.CreateBoxAbuseEventParams{
+		FingerprintID: event.FingerprintID,
+		ContainerID:   event.ContainerID,
+		Kind:          string(event.Kind),
+		Detail:        event.Detail,
+		Action:        string(event.Action),
+	})
+	if err != nil {
+		return nil, mapError(err, "create box abuse event")
+	}
+
+	return r.toDomain(dbEvent), nil
+}
+
+// CountSince counts the owner's events since the given time.
+func (r *AbuseRepo) CountSince(ctx context.Context, fingerprintID string, since time.Time) (int64, error) {
+	count, err := r.queries.CountBoxAbuseEventsSince(ctx, db.CountBoxAbuseEventsSinceParams{
+		FingerprintID: fingerprintID,
+		CreatedAt: pgtype.Timestamp{
+			Time:  since,
+			Valid: true,
+		},
+	})
+	if err != nil {
+		return 0, mapError(err, "count box abuse events")
+	}
+	return count, nil
+}
+
+// LatestBan returns the owner's most recent ban.
+func (r *AbuseRepo) LatestBan(ctx context.Context, fingerprintID string) (*domain.AbuseEvent, error) {
+	dbEvent, err := r.queries.GetLatestBoxAbuseBan(ctx, fingerprintID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, domain.NewNotFoundError("abuse ban", fingerprintID)
+		}
+		return nil, mapError(err, "get latest box abuse ban")
+	}
+
+	return r.toDomain(dbEvent), nil
+}
+
+// ListSince returns the events of the given owners after the given time,
+// oldest first.
+func (r *AbuseRepo) ListSince(ctx context.Context, fingerprintIDs []string, since time.Time) ([]domain.AbuseEvent, error) {
+	dbEvents, err := r.queries.ListBoxAbuseEventsSince(ctx, db.ListBoxAbuseEventsSinceParams{
+		FingerprintIds: fingerprintIDs,
+		CreatedAt: pgtype.Timestamp{
+			Time:  since,
+			Valid: true,
+		},
+	})
+	if err != nil {
+		return nil, mapError(err, "list box abuse events")
+	}
+
+	events := make([]domain.AbuseEvent, len(dbEvents))
+	for i, dbEvent := range dbEvents {
+		events[i] = *r.toDomain(dbEvent)
+	}
+
+	return events, nil
+}
+
+func (r *AbuseRepo) toDomain(dbEvent db.BoxAbuseEvent) *domain.AbuseEvent {
+	var createdAt time.Time
+	if dbEvent.CreatedAt.Valid {
+		createdAt = dbEvent.CreatedAt.Time
+	}
+
+	return &domain.AbuseEvent{
+		ID:            dbEvent.ID,
+		FingerprintID: dbEvent.FingerprintID,
+		ContainerID:   dbEvent.ContainerID,
+		Kind:          domain.AbuseKind(dbEvent.Kind),
+		Detail:        dbEvent.Detail,
+		Action:        domain.AbuseAction(dbEvent.Action),
+		CreatedAt:     createdAt,
+	}
+}
@@ -2,13 +2,14 @@ package repo
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"time"
 
 	"github.com/faiyaz032/gobox/internal/domain"
 	db "github.com/faiyaz032/gobox/internal/infra/db/sqlc"
+	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
-	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
@@ -23,6 +24,11 @@ func NewBoxRepo(queries *db.Queries) *BoxRepo {
 }
 
 func (r *BoxRepo) Create(ctx context.Context, box domain.Box) (*domain.Box, error) {
+	spec, err := json.Marshal(box.Spec)
+	if err != nil {
+		return nil, domain.NewInternalError("failed to encode box spec", err)
+	}
+
 	params := db.CreateBoxParams{
 		FingerprintID: box.FingerprintID,
 		ContainerID:   box.ContainerID,
@@ -31,11 +37,16 @@ func (r *BoxRepo) Create(ctx context.Context, box domain.Box) (*domain.Box, erro
 			Time:  box.LastActive,
 			Valid: true,
 		},
+		Tier:  box.Tier,
+		Image: box.Image,
+		Spec:  spec,
+
+		ProvisionStatus: string(box.ProvisionStatus),
 	}
 
 	dbBox, err := r.queries.CreateBox(ctx, params)
 	if err != nil {
-		return nil, r.mapError(err, "create box")
+		return nil, mapError(err, "create box")
 	}
 
 	return r.toDomain(dbBox), nil
@@ -47,7 +58,19 @@ func (r *BoxRepo) GetByFingerprint(ctx context.Context, fingerprintID string) (*
 		if errors.Is(err, pgx.ErrNoRows) {
 			return nil, domain.NewNotFoundError("box", fingerprintID)
 		}
-		return nil, r.mapError(err, "get box by fingerprint")
+		return nil, mapError(err, "get box by fingerprint")
+	}
+
+	return r.toDomain(dbBox), nil
+}
+
+func (r *BoxRepo) GetByID(ctx context.Context, id uuid.UUID) (*domain.Box, error) {
+	dbBox, err := r.queries.GetBoxByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, domain.NewNotFoundError("box", id.String())
+		}
+		return nil, mapError(err, "get box by ID")
 	}
 
 	return r.toDomain(dbBox), nil
@@ -59,7 +82,7 @@ func (r *BoxRepo) GetByContainerID(ctx context.Context, containerID string) (*do
 		if errors.Is(err, pgx.ErrNoRows) {
 			return nil, domain.NewNotFoundError("box", containerID)
 		}
-		return nil, r.mapError(err, "get box by container ID")
+		return nil, mapError(err, "get box by container ID")
 	}
 
 	return r.toDomain(dbBox), nil
@@ -76,7 +99,7 @@ func (r *BoxRepo) Touch(ctx context.Context, fingerprintID string) (*domain.Box,
 
 	err := r.queries.TouchBox(ctx, params)
 	if err != nil {
-		return nil, r.mapError(err, "touch box")
+		return nil, mapError(err, "touch box")
 	}
 
 	return r.GetByFingerprint(ctx, fingerprintID)
@@ -90,19 +113,124 @@ func (r *BoxRepo) UpdateStatus(ctx context.Context, fingerprintID string, status
 
 	err := r.queries.UpdateBoxStatus(ctx, params)
 	if err != nil {
-		return nil, r.mapError(err, "update box status")
+		return nil, mapError(err, "update box status")
+	}
+
+	return r.GetByFingerprint(ctx, fingerprintID)
+}
+
+func (r *BoxRepo) UpdateContainer(ctx context.Context, fingerprintID, containerID, image string, status domain.BoxStatus) (*domain.Box, error) {
+	params := db.UpdateBoxContainerParams{
+		FingerprintID: fingerprintID,
+		ContainerID:   containerID,
+		Image:         image,
+		Status:        string(status),
+	}
+
+	err := r.queries.UpdateBoxContainer(ctx, params)
+	if err != nil {
+		return nil, mapError(err, "update box container")
 	}
 
 	return r.GetByFingerprint(ctx, fingerprintID)
 }
 
+func (r *BoxRepo) UpdateSpec(ctx context.Context, fingerprintID string, spec domain.BoxSpec) (*domain.Box, error) {
+	data, err := json.Marshal(spec)
+	if err != nil {
+		return nil, domain.NewInternalError("failed to encode box spec", err)
+	}
+
+	err = r.queries.UpdateBoxSpec(ctx, db.UpdateBoxSpecParams{
+		FingerprintID: fingerprintID,
+		Spec:          data,
+	})
+	if err != nil {
+		return nil, mapError(err, "update box spec")
+	}
+
+	return r.GetByFingerprint(ctx, fingerprintID)
+}
+
+// ReplaceContainer records the container, image, status, spec and tier of
+// b in one update, so a replaced box never points at a half-applied mix.
+func (r *BoxRepo) ReplaceContainer(ctx context.Context, b domain.Box) (*domain.Box, error) {
+	data, err := json.Marshal(b.Spec)
+	if err != nil {
+		return nil, domain.NewInternalError("failed to encode box spec", err)
+	}
+
+	err = r.queries.ReplaceBoxContainer(ctx, db.ReplaceBoxContainerParams{
+		FingerprintID: b.FingerprintID,
+		ContainerID:   b.ContainerID,
+		Image:         b.Image,
+		Status:        string(b.Status),
+		Spec:          data,
+		Tier:          b.Tier,
+	})
+	if err != nil {
+		return nil, mapError(err, "replace box container")
+	}
+
+	return r.GetByFingerprint(ctx, b.FingerprintID)
+}
+
+func (r *BoxRepo) UpdateProvisionStatus(ctx context.Context, fingerprintID string, status domain.ProvisionStatus) error {
+	err := r.queries.UpdateBoxProvisionStatus(ctx, db.UpdateBoxProvisionStatusParams{
+		FingerprintID:   fingerprintID,
+		ProvisionStatus: string(status),
+	})
+	if err != nil {
+		return mapError(err, "update box provision status")
+	}
+	return nil
+}
+
+// ClaimProvision marks a pending box as running and reports whether the
+// caller won the claim.
+func (r *BoxRepo) ClaimProvision(ctx context.Context, fingerprintID string) (bool, error) {
+	n, err := r.queries.ClaimBoxProvision(ctx, fingerprintID)
+	if err != nil {
+		return false, mapError(err, "claim box provision")
+	}
+	return n > 0, nil
+}
+
+func (r *BoxRepo) SetEnvStale(ctx context.Context, fingerprintID string, stale bool) error {
+	err := r.queries.SetBoxEnvStale(ctx, db.SetBoxEnvStaleParams{
+		FingerprintID: fingerprintID,
+		EnvStale:      stale,
+	})
+	if err != nil {
+		return mapError(err, "set box env stale")
+	}
+	return nil
+}
+
 func (r *BoxRepo) GetExpiredBoxes(ctx context.Context, lastActive time.Time) ([]domain.Box, error) {
 	dbBoxes, err := r.queries.GetExpiredBoxes(ctx, pgtype.Timestamp{
 		Time:  lastActive,
 		Valid: true,
 	})
 	if err != nil {
-		return nil, r.mapError(err, "get expired boxes")
+		return nil, mapError(err, "get expired boxes")
+	}
+
+	boxes := make([]domain.Box, len(dbBoxes))
+	for i, dbBox := range dbBoxes {
+		boxes[i] = *r.toDomain(dbBox)
+	}
+
+	return boxes, nil
+}
+
+func (r *BoxRepo) GetBoxesCreatedBefore(ctx context.Context, createdAt time.Time) ([]domain.Box, error) {
+	dbBoxes, err := r.queries.GetBoxesCreatedBefore(ctx, pgtype.Timestamp{
+		Time:  createdAt,
+		Valid: true,
+	})
+	if err != nil {
+		return nil, mapError(err, "get boxes created before")
 	}
 
 	boxes := make([]domain.Box, len(dbBoxes))
@@ -113,19 +241,64 @@ func (r *BoxRepo) GetExpiredBoxes(ctx context.Context, lastActive time.Time) ([]
 	return boxes, nil
 }
 
+func (r *BoxRepo) ListByStatus(ctx context.Context, status domain.BoxStatus) ([]domain.Box, error) {
+	dbBoxes, err := r.queries.ListBoxesByStatus(ctx, string(status))
+	if err != nil {
+		return nil, mapError(err, "list boxes by status")
+	}
+
+	boxes := make([]domain.Box, len(dbBoxes))
+	for i, dbBox := range dbBoxes {
+		boxes[i] = *r.toDomain(dbBox)
+	}
+
+	return boxes, nil
+}
+
+func (r *BoxRepo) CountByStatus(ctx context.Context, status domain.BoxStatus) (int64, error) {
+	count, err := r.queries.CountBoxesByStatus(ctx, string(status))
+	if err != nil {
+		return 0, mapError(err, "count boxes by status")
+	}
+	return count, nil
+}
+
 func (r *BoxRepo) Delete(ctx context.Context, fingerprintID string) error {
 	err := r.queries.DeleteBox(ctx, fingerprintID)
 	if err != nil {
-		return r.mapError(err, "delete box")
+		return mapError(err, "delete box")
 	}
 	return nil
 }
 
+// DeleteIfUnchanged deletes the box unless its container, image or status
+// changed since b was read, and reports whether it was deleted.
+func (r *BoxRepo) DeleteIfUnchanged(ctx context.Context, b domain.Box) (bool, error) {
+	n, err := r.queries.DeleteBoxIfUnchanged(ctx, db.DeleteBoxIfUnchangedParams{
+		FingerprintID: b.FingerprintID,
+		ContainerID:   b.ContainerID,
+		Image:         b.Image,
+		Status:        string(b.Status),
+	})
+	if err != nil {
+		return false, mapError(err, "delete box")
+	}
+	return n > 0, nil
+}
+
 func (r *BoxRepo) toDomain(dbBox db.Box) *domain.Box {
 	var lastActive time.Time
 	if dbBox.LastActive.Valid {
 		lastActive = dbBox.LastActive.Time
 	}
+	var createdAt time.Time
+	if dbBox.CreatedAt.Valid {
+		createdAt = dbBox.CreatedAt.Time
+	}
+	// a spec that cannot be decoded is left empty so the box falls back
+	// to the server defaults
+	var spec domain.BoxSpec
+	_ = json.Unmarshal(dbBox.Spec, &spec)
 
 	return &domain.Box{
 		ID:            dbBox.ID,
@@ -133,37 +306,12 @@ func (r *BoxRepo) toDomain(dbBox db.Box) *domain.Box {
 		ContainerID:   dbBox.ContainerID,
 		Status:        domain.BoxStatus(dbBox.Status),
 		LastActive:    lastActive,
-	}
-}
-
-// converts database errors to AppError
-func (r *BoxRepo) mapError(err error, operation string) error {
-	if err == nil {
-		return nil
-	}
+		Tier:          dbBox.Tier,
+		CreatedAt:     createdAt,
+		Image:         dbBox.Image,
+		Spec:          spec,
 
-	var pgErr *pgconn.PgError
-	if errors.As(err, &pgErr) {
-		switch pgErr.Code {
-		case "23505": // unique_violation
-			return domain.NewConflictError("record already exists")
-		case "23503": // foreign_key_violation
-			return domain.NewValidationError("referenced record does not exist")
-		case "23514": // check_violation
-			return domain.NewValidationError("constraint violation: " + pgErr.Message)
-		default:
-			return domain.NewDatabaseError(operation, err)
-		}
-	}
-
-	// Handle connection errors
-	if errors.Is(err, context.DeadlineExceeded) {
-		return domain.NewDatabaseError(operation+" (timeout)", err)
+		ProvisionStatus: domain.ProvisionStatus(dbBox.ProvisionStatus),
+		EnvStale:        dbBox.EnvStale,
 	}
-
-	if errors.Is(err, context.Canceled) {
-		return domain.NewDatabaseError(operation+" (canceled)", err)
-	}
-
-	return domain.NewDatabaseError(operation, err)
 }
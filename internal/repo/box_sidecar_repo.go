@@ -0,0 +1,72 @@
+package repo
+
+import (
+	"context"
+	"time"
+
+	"github.com/faiyaz032/gobox/internal/domain"
+	db "github.com/faiyaz032/gobox/internal/infra/db/sqlc"
+	"github.com/google/uuid"
+)
+
+type BoxSidecarRepo struct {
+	queries *db.Queries
+}
+
+func NewBoxSidecarRepo(queries *db.Queries) *BoxSidecarRepo {
+	return &BoxSidecarRepo{
+		queries: queries,
+	}
+}
+
+func (r *BoxSidecarRepo) Create(ctx context.Context, sidecar domain.Sidecar) (*domain.Sidecar, error) {
+	dbSidecar, err := r.queries.CreateBoxSidecar(ctx, db.CreateBoxSidecarParams{
+		BoxID:       sidecar.BoxID,
+		Name:        sidecar.Name,
+		Template:    sidecar.Template,
+		ContainerID: sidecar.ContainerID,
+	})
+	if err != nil {
+		return nil, mapError(err, "create box sidecar")
+	}
+
+	return r.toDomain(dbSidecar), nil
+}
+
+func (r *BoxSidecarRepo) ListByFingerprint(ctx context.Context, fingerprintID string) ([]domain.Sidecar, error) {
+	dbSidecars, err := r.queries.ListBoxSidecarsByFingerprint(ctx, fingerprintID)
+	if err != nil {
+		return nil, mapError(err, "list box sidecars")
+	}
+
+	sidecars := make([]domain.Sidecar, len(dbSidecars))
+	for i, dbSidecar := range dbSidecars {
+		sidecars[i] = *r.toDomain(dbSidecar)
+	}
+
+	return sidecars, nil
+}
+
+func (r *BoxSidecarRepo) Delete(ctx context.Context, id uuid.UUID) error {
+	err := r.queries.DeleteBoxSidecar(ctx, id)
+	if err != nil {
+		return mapError(err, "delete box sidecar")
+	}
+	return nil
+}
+
+func (r *BoxSidecarRepo) toDomain(dbSidecar db.BoxSidecar) *domain.Sidecar {
+	var createdAt time.Time
+	if dbSidecar.CreatedAt.Valid {
+		createdAt = dbSidecar.CreatedAt.Time
+	}
+
+	return &domain.Sidecar{
+		ID:          dbSidecar.ID,
+		BoxID:       dbSidecar.BoxID,
+		Name:        dbSidecar.Name,
+		Template:    dbSidecar.Template,
+		ContainerID: dbSidecar.ContainerID,
+		CreatedAt:   createdAt,
+	}
+}
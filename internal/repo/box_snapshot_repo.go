@@ -0,0 +1,106 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/faiyaz032/gobox/internal/domain"
+	db "github.com/faiyaz032/gobox/internal/infra/db/sqlc"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+type BoxSnapshotRepo struct {
+	queries *db.Queries
+}
+
+func NewBoxSnapshotRepo(queries *db.Queries) *BoxSnapshotRepo {
+	return &BoxSnapshotRepo{
+		queries: queries,
+	}
+}
+
+func (r *BoxSnapshotRepo) Create(ctx context.Context, snapshot domain.Snapshot) (*domain.Snapshot, error) {
+	params := db.CreateBoxSnapshotParams{
+		ID:            snapshot.ID,
+		FingerprintID: snapshot.FingerprintID,
+		BoxID:         snapshot.BoxID,
+		Name:          snapshot.Name,
+		Image:         snapshot.Image,
+		SizeBytes:     snapshot.SizeBytes,
+	}
+
+	dbSnapshot, err := r.queries.CreateBoxSnapshot(ctx, params)
+	if err != nil {
+		return nil, mapError(err, "create box snapshot")
+	}
+
+	return r.toDomain(dbSnapshot), nil
+}
+
+func (r *BoxSnapshotRepo) Get(ctx context.Context, fingerprintID string, id uuid.UUID) (*domain.Snapshot, error) {
+	dbSnapshot, err := r.queries.GetBoxSnapshot(ctx, db.GetBoxSnapshotParams{
+		ID:            id,
+		FingerprintID: fingerprintID,
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, domain.NewNotFoundError("snapshot", id.String())
+		}
+		return nil, mapError(err, "get box snapshot")
+	}
+
+	return r.toDomain(dbSnapshot), nil
+}
+
+func (r *BoxSnapshotRepo) ListByFingerprint(ctx context.Context, fingerprintID string) ([]domain.Snapshot, error) {
+	dbSnapshots, err := r.queries.ListBoxSnapshotsByFingerprint(ctx, fingerprintID)
+	if err != nil {
+		return nil, mapError(err, "list box snapshots")
+	}
+
+	snapshots := make([]domain.Snapshot, len(dbSnapshots))
+	for i, dbSnapshot := range dbSnapshots {
+		snapshots[i] = *r.toDomain(dbSnapshot)
+	}
+
+	return snapshots, nil
+}
+
+func (r *BoxSnapshotRepo) Usage(ctx context.Context, fingerprintID string) (*domain.SnapshotUsage, error) {
+	row, err := r.queries.GetBoxSnapshotUsage(ctx, fingerprintID)
+	if err != nil {
+		return nil, mapError(err, "get box snapshot usage")
+	}
+
+	return &domain.SnapshotUsage{
+		Count:     row.Count,
+		SizeBytes: row.SizeBytes,
+	}, nil
+}
+
+func (r *BoxSnapshotRepo) Delete(ctx context.Context, id uuid.UUID) error {
+	err := r.queries.DeleteBoxSnapshot(ctx, id)
+	if err != nil {
+		return mapError(err, "delete box snapshot")
+	}
+	return nil
+}
+
+func (r *BoxSnapshotRepo) toDomain(dbSnapshot db.BoxSnapshot) *domain.Snapshot {
+	var createdAt time.Time
+	if dbSnapshot.CreatedAt.Valid {
+		createdAt = dbSnapshot.CreatedAt.Time
+	}
+
+	return &domain.Snapshot{
+		ID:            dbSnapshot.ID,
+		FingerprintID: dbSnapshot.FingerprintID,
+		BoxID:         dbSnapshot.BoxID,
+		Name:          dbSnapshot.Name,
+		Image:         dbSnapshot.Image,
+		SizeBytes:     dbSnapshot.SizeBytes,
+		CreatedAt:     createdAt,
+	}
+}
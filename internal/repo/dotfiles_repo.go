@@ -0,0 +1,69 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/faiyaz032/gobox/internal/domain"
+	db "github.com/faiyaz032/gobox/internal/infra/db/sqlc"
+	"github.com/jackc/pgx/v5"
+)
+
+type DotfilesRepo struct {
+	queries *db.Queries
+}
+
+func NewDotfilesRepo(queries *db.Queries) *DotfilesRepo {
+	return &DotfilesRepo{
+		queries: queries,
+	}
+}
+
+func (r *DotfilesRepo) Upsert(ctx context.Context, dotfiles domain.Dotfiles) (*domain.Dotfiles, error) {
+	dbDotfiles, err := r.queries.UpsertDotfiles(ctx, db.UpsertDotfilesParams{
+		FingerprintID: dotfiles.FingerprintID,
+		Archive:       dotfiles.Archive,
+		Files:         dotfiles.Files,
+		SizeBytes:     dotfiles.SizeBytes,
+	})
+	if err != nil {
+		return nil, mapError(err, "upsert dotfiles")
+	}
+
+	return r.toDomain(dbDotfiles), nil
+}
+
+func (r *DotfilesRepo) Get(ctx context.Context, fingerprintID string) (*domain.Dotfiles, error) {
+	dbDotfiles, err := r.queries.GetDotfiles(ctx, fingerprintID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, domain.NewNotFoundError("dotfiles", fingerprintID)
+		}
+		return nil, mapError(err, "get dotfiles")
+	}
+
+	return r.toDomain(dbDotfiles), nil
+}
+
+func (r *DotfilesRepo) Delete(ctx context.Context, fingerprintID string) error {
+	if err := r.queries.DeleteDotfiles(ctx, fingerprintID); err != nil {
+		return mapError(err, "delete dotfiles")
+	}
+	return nil
+}
+
+func (r *DotfilesRepo) toDomain(dbDotfiles db.Dotfile) *domain.Dotfiles {
+	var updatedAt time.Time
+	if dbDotfiles.UpdatedAt.Valid {
+		updatedAt = dbDotfiles.UpdatedAt.Time
+	}
+
+	return &domain.Dotfiles{
+		FingerprintID: dbDotfiles.FingerprintID,
+		Archive:       dbDotfiles.Archive,
+		Files:         dbDotfiles.Files,
+		SizeBytes:     dbDotfiles.SizeBytes,
+		UpdatedAt:     updatedAt,
+	}
+}
@@ -0,0 +1,87 @@
+package repo
+
+import (
+	"context"
+	"time"
+
+	"github.com/faiyaz032/gobox/internal/domain"
+	db "github.com/faiyaz032/gobox/internal/infra/db/sqlc"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+type EnvVarRepo struct {
+	queries *db.Queries
+}
+
+func NewEnvVarRepo(queries *db.Queries) *EnvVarRepo {
+	return &EnvVarRepo{
+		queries: queries,
+	}
+}
+
+// Upsert stores a variable; secrets are stored by their Ciphertext only.
+func (r *EnvVarRepo) Upsert(ctx context.Context, v domain.EnvVar) (*domain.EnvVar, error) {
+	params := db.UpsertEnvVarParams{
+		FingerprintID: v.FingerprintID,
+		Name:          v.Name,
+		Injection:     string(v.Injection),
+	}
+	if v.Secret {
+		params.Ciphertext = v.Ciphertext
+	} else {
+		params.Value = pgtype.Text{String: v.Value, Valid: true}
+	}
+
+	dbVar, err := r.queries.UpsertEnvVar(ctx, params)
+	if err != nil {
+		return nil, mapError(err, "upsert env var")
+	}
+
+	return r.toDomain(dbVar), nil
+}
+
+func (r *EnvVarRepo) List(ctx context.Context, fingerprintID string) ([]domain.EnvVar, error) {
+	dbVars, err := r.queries.ListEnvVars(ctx, fingerprintID)
+	if err != nil {
+		return nil, mapError(err, "list env vars")
+	}
+
+	vars := make([]domain.EnvVar, len(dbVars))
+	for i, dbVar := range dbVars {
+		vars[i] = *r.toDomain(dbVar)
+	}
+
+	return vars, nil
+}
+
+func (r *EnvVarRepo) Delete(ctx context.Context, fingerprintID, name string) error {
+	n, err := r.queries.DeleteEnvVar(ctx, db.DeleteEnvVarParams{
+		FingerprintID: fingerprintID,
+		Name:          name,
+	})
+	if err != nil {
+		return mapError(err, "delete env var")
+	}
+	if n == 0 {
+		return domain.NewNotFoundError("env var", name)
+	}
+	return nil
+}
+
+func (r *EnvVarRepo) toDomain(dbVar db.EnvVar) *domain.EnvVar {
+	var updatedAt time.Time
+	if dbVar.UpdatedAt.Valid {
+		updatedAt = dbVar.UpdatedAt.Time
+	}
+
+	return &domain.EnvVar{
+		FingerprintID: dbVar.FingerprintID,
+		Name:          dbVar.Name,
+		Scope:         domain.EnvScopeUser,
+		Value:         dbVar.Value.String,
+		Secret:        !dbVar.Value.Valid,
+		Ciphertext:    dbVar.Ciphertext,
+		Injection:     domain.EnvInjection(dbVar.Injection),
+		UpdatedAt:     updatedAt,
+	}
+}
@@ -0,0 +1,43 @@
+package repo
+
+import (
+	"context"
+	"errors"
+
+	"github.com/faiyaz032/gobox/internal/domain"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// converts database errors to AppError
+func mapError(err error, operation string) error {
+	if err == nil {
+		return nil
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		switch pgErr.Code {
+		case "23505": // unique_violation
+			return domain.NewConflictError("record already exists")
+		case "23503": // foreign_key_violation
+			return domain.NewValidationError("referenced record does not exist")
+		case "23P01": // exclusion_violation
+			return domain.NewConflictError("record overlaps an existing one")
+		case "23514": // check_violation
+			return domain.NewValidationError("constraint violation: " + pgErr.Message)
+		default:
+			return domain.NewDatabaseError(operation, err)
+		}
+	}
+
+	// Handle connection errors
+	if errors.Is(err, context.DeadlineExceeded) {
+		return domain.NewDatabaseError(operation+" (timeout)", err)
+	}
+
+	if errors.Is(err, context.Canceled) {
+		return domain.NewDatabaseError(operation+" (canceled)", err)
+	}
+
+	return domain.NewDatabaseError(operation, err)
+}
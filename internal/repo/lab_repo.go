@@ -0,0 +1,186 @@
+package repo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/netip"
+	"time"
+
+	"github.com/faiyaz032/gobox/internal/domain"
+	db "github.com/faiyaz032/gobox/internal/infra/db/sqlc"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+type LabRepo struct {
+	queries *db.Queries
+}
+
+func NewLabRepo(queries *db.Queries) *LabRepo {
+	return &LabRepo{
+		queries: queries,
+	}
+}
+
+func (r *LabRepo) Create(ctx context.Context, lab domain.Lab) (*domain.Lab, error) {
+	spec, err := json.Marshal(lab.Spec)
+	if err != nil {
+		return nil, domain.NewInternalError("failed to encode lab spec", err)
+	}
+
+	dbLab, err := r.queries.CreateLab(ctx, db.CreateLabParams{
+		FingerprintID: lab.FingerprintID,
+		Name:          lab.Name,
+		Spec:          spec,
+	})
+	if err != nil {
+		return nil, mapError(err, "create lab")
+	}
+
+	return r.toDomain(dbLab), nil
+}
+
+func (r *LabRepo) AddNetwork(ctx context.Context, labID uuid.UUID, network domain.LabNetwork) error {
+	err := r.queries.CreateLabNetwork(ctx, db.CreateLabNetworkParams{
+		LabID:  labID,
+		Name:   network.Name,
+		Subnet: network.Subnet,
+	})
+	if err != nil {
+		return mapError(err, "create lab network")
+	}
+	return nil
+}
+
+func (r *LabRepo) AddNode(ctx context.Context, labID uuid.UUID, node domain.LabNode) error {
+	addresses, err := json.Marshal(node.Addresses)
+	if err != nil {
+		return domain.NewInternalError("failed to encode lab node addresses", err)
+	}
+
+	err = r.queries.CreateLabNode(ctx, db.CreateLabNodeParams{
+		LabID:       labID,
+		Name:        node.Name,
+		ContainerID: node.ContainerID,
+		Addresses:   addresses,
+	})
+	if err != nil {
+		return mapError(err, "create lab node")
+	}
+	return nil
+}
+
+// Get returns one of the owner's labs with its networks and nodes.
+func (r *LabRepo) Get(ctx context.Context, fingerprintID string, id uuid.UUID) (*domain.Lab, error) {
+	dbLab, err := r.queries.GetLab(ctx, db.GetLabParams{
+		ID:            id,
+		FingerprintID: fingerprintID,
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, domain.NewNotFoundError("lab", id.String())
+		}
+		return nil, mapError(err, "get lab")
+	}
+
+	lab := r.toDomain(dbLab)
+	if err := r.loadMembers(ctx, lab); err != nil {
+		return nil, err
+	}
+	return lab, nil
+}
+
+func (r *LabRepo) ListByFingerprint(ctx context.Context, fingerprintID string) ([]domain.Lab, error) {
+	dbLabs, err := r.queries.ListLabsByFingerprint(ctx, fingerprintID)
+	if err != nil {
+		return nil, mapError(err, "list labs")
+	}
+
+	labs := make([]domain.Lab, len(dbLabs))
+	for i, dbLab := range dbLabs {
+		lab := r.toDomain(dbLab)
+		if err := r.loadMembers(ctx, lab); err != nil {
+			return nil, err
+		}
+		labs[i] = *lab
+	}
+
+	return labs, nil
+}
+
+// ListCreatedBefore returns labs without their members, for the expiry
+// sweep.
+func (r *LabRepo) ListCreatedBefore(ctx context.Context, createdAt time.Time) ([]domain.Lab, error) {
+	dbLabs, err := r.queries.ListLabsCreatedBefore(ctx, pgtype.Timestamp{
+		Time:  createdAt,
+		Valid: true,
+	})
+	if err != nil {
+		return nil, mapError(err, "list labs created before")
+	}
+
+	labs := make([]domain.Lab, len(dbLabs))
+	for i, dbLab := range dbLabs {
+		labs[i] = *r.toDomain(dbLab)
+	}
+
+	return labs, nil
+}
+
+func (r *LabRepo) Delete(ctx context.Context, id uuid.UUID) error {
+	err := r.queries.DeleteLab(ctx, id)
+	if err != nil {
+		return mapError(err, "delete lab")
+	}
+	return nil
+}
+
+func (r *LabRepo) loadMembers(ctx context.Context, lab *domain.Lab) error {
+	dbNetworks, err := r.queries.ListLabNetworks(ctx, lab.ID)
+	if err != nil {
+		return mapError(err, "list lab networks")
+	}
+	lab.Networks = make([]domain.LabNetwork, len(dbNetworks))
+	for i, dbNetwork := range dbNetworks {
+		lab.Networks[i] = domain.LabNetwork{
+			Name:   dbNetwork.Name,
+			Subnet: dbNetwork.Subnet,
+		}
+	}
+
+	dbNodes, err := r.queries.ListLabNodes(ctx, lab.ID)
+	if err != nil {
+		return mapError(err, "list lab nodes")
+	}
+	lab.Nodes = make([]domain.LabNode, len(dbNodes))
+	for i, dbNode := range dbNodes {
+		var addresses map[string]netip.Addr
+		_ = json.Unmarshal(dbNode.Addresses, &addresses)
+		lab.Nodes[i] = domain.LabNode{
+			Name:        dbNode.Name,
+			ContainerID: dbNode.ContainerID,
+			Addresses:   addresses,
+		}
+	}
+	return nil
+}
+
+func (r *LabRepo) toDomain(dbLab db.Lab) *domain.Lab {
+	var createdAt time.Time
+	if dbLab.CreatedAt.Valid {
+		createdAt = dbLab.CreatedAt.Time
+	}
+
+	var spec domain.LabSpec
+	_ = json.Unmarshal(dbLab.Spec, &spec)
+
+	return &domain.Lab{
+		ID:            dbLab.ID,
+		FingerprintID: dbLab.FingerprintID,
+		Name:          dbLab.Name,
+		Spec:          spec,
+		CreatedAt:     createdAt,
+	}
+}
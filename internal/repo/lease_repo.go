@@ -0,0 +1,77 @@
+package repo
+
+import (
+	"context"
+	"time"
+
+	db "github.com/faiyaz032/gobox/internal/infra/db/sqlc"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// LeaseRepo stores the connection leases a replica holds on boxes.
+type LeaseRepo struct {
+	queries *db.Queries
+}
+
+func NewLeaseRepo(queries *db.Queries) *LeaseRepo {
+	return &LeaseRepo{
+		queries: queries,
+	}
+}
+
+// Renew takes or extends the replica's leases on the boxes until
+// expiresAt.
+func (r *LeaseRepo) Renew(ctx context.Context, replicaID string, fingerprintIDs []string, expiresAt time.Time) error {
+	err := r.queries.UpsertBoxConnectionLeases(ctx, db.UpsertBoxConnectionLeasesParams{
+		FingerprintIds: fingerprintIDs,
+		ReplicaID:      replicaID,
+		ExpiresAt: pgtype.Timestamp{
+			Time:  expiresAt,
+			Valid: true,
+		},
+	})
+	return mapError(err, "renew box connection leases")
+}
+
+// Release drops the replica's lease on a box.
+func (r *LeaseRepo) Release(ctx context.Context, replicaID, fingerprintID string) error {
+	err := r.queries.DeleteBoxConnectionLease(ctx, db.DeleteBoxConnectionLeaseParams{
+		FingerprintID: fingerprintID,
+		ReplicaID:     replicaID,
+	})
+	return mapError(err, "release box connection lease")
+}
+
+// ReleaseAll drops every lease the replica holds.
+func (r *LeaseRepo) ReleaseAll(ctx context.Context, replicaID string) error {
+	err := r.queries.DeleteBoxConnectionLeasesByReplica(ctx, replicaID)
+	return mapError(err, "release box connection leases")
+}
+
+// CountOthers counts the live leases other replicas hold on a box.
+func (r *LeaseRepo) CountOthers(ctx context.Context, replicaID, fingerprintID string, now time.Time) (int64, error) {
+	count, err := r.queries.CountOtherBoxConnectionLeases(ctx, db.CountOtherBoxConnectionLeasesParams{
+		FingerprintID: fingerprintID,
+		ReplicaID:     replicaID,
+		ExpiresAt: pgtype.Timestamp{
+			Time:  now,
+			Valid: true,
+		},
+	})
+	if err != nil {
+		return 0, mapError(err, "count box connection leases")
+	}
+	return count, nil
+}
+
+// DeleteExpired drops leases that expired before now.
+func (r *LeaseRepo) DeleteExpired(ctx context.Context, now time.Time) (int64, error) {
+	count, err := r.queries.DeleteExpiredBoxConnectionLeases(ctx, pgtype.Timestamp{
+		Time:  now,
+		Valid: true,
+	})
+	if err != nil {
+		return 0, mapError(err, "delete expired box connection leases")
+	}
+	return count, nil
+}
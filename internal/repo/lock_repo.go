@@ -0,0 +1,50 @@
+package repo
+
+import (
+	"context"
+
+	"github.com/faiyaz032/gobox/internal/domain"
+	db "github.com/faiyaz032/gobox/internal/infra/db/sqlc"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// LockRepo takes Postgres advisory locks so a job runs on one replica at a
+// time.
+type LockRepo struct {
+	pool *pgxpool.Pool
+}
+
+func NewLockRepo(pool *pgxpool.Pool) *LockRepo {
+	return &LockRepo{
+		pool: pool,
+	}
+}
+
+// TryWithLock runs fn while holding the advisory lock key, or reports
+// false without running it when another replica holds the lock. The lock
+// is session-level, so one pooled connection is held until fn returns.
+func (r *LockRepo) TryWithLock(ctx context.Context, key domain.LockKey, fn func()) (bool, error) {
+	conn, err := r.pool.Acquire(ctx)
+	if err != nil {
+		return false, mapError(err, "acquire connection for advisory lock")
+	}
+	defer conn.Release()
+
+	queries := db.New(conn)
+	acquired, err := queries.TryAdvisoryLock(ctx, int64(key))
+	if err != nil {
+		return false, mapError(err, "take advisory lock")
+	}
+	if !acquired {
+		return false, nil
+	}
+	defer func() {
+		// an unlock failure leaves the lock on the connection, so drop it
+		if _, err := queries.AdvisoryUnlock(context.Background(), int64(key)); err != nil {
+			_ = conn.Conn().Close(context.Background())
+		}
+	}()
+
+	fn()
+	return true, nil
+}
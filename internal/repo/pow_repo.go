@@ -0,0 +1,72 @@
+package repo
+
+import (
+	"context"
+	"time"
+
+	db "github.com/faiyaz032/gobox/internal/infra/db/sqlc"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// PowRepo stores the proof-of-work challenges accepted by any replica.
+type PowRepo struct {
+	queries *db.Queries
+}
+
+func NewPowRepo(queries *db.Queries) *PowRepo {
+	return &PowRepo{
+		queries: queries,
+	}
+}
+
+// Spend records an accepted challenge. It reports false when the nonce was
+// already spent.
+func (r *PowRepo) Spend(ctx context.Context, nonce, clientIP string, expiresAt time.Time) (bool, error) {
+	rows, err := r.queries.SpendPowChallenge(ctx, db.SpendPowChallengeParams{
+		Nonce:    nonce,
+		ClientIp: clientIP,
+		ExpiresAt: pgtype.Timestamp{
+			Time:  expiresAt,
+			Valid: true,
+		},
+	})
+	if err != nil {
+		return false, mapError(err, "spend proof-of-work challenge")
+	}
+	return rows > 0, nil
+}
+
+// CountByIP counts the challenges accepted from the IP since the given
+// time.
+func (r *PowRepo) CountByIP(ctx context.Context, clientIP string, since time.Time) (int64, error) {
+	count, err := r.queries.CountPowChallengesByIP(ctx, db.CountPowChallengesByIPParams{
+		ClientIp: clientIP,
+		CreatedAt: pgtype.Timestamp{
+			Time:  since,
+			Valid: true,
+		},
+	})
+	if err != nil {
+		return 0, mapError(err, "count proof-of-work challenges")
+	}
+	return count, nil
+}
+
+// DeleteExpired drops the challenges that expired before now and were
+// accepted before since.
+func (r *PowRepo) DeleteExpired(ctx context.Context, now, since time.Time) (int64, error) {
+	rows, err := r.queries.DeleteExpiredPowChallenges(ctx, db.DeleteExpiredPowChallengesParams{
+		ExpiresAt: pgtype.Timestamp{
+			Time:  now,
+			Valid: true,
+		},
+		CreatedAt: pgtype.Timestamp{
+			Time:  since,
+			Valid: true,
+		},
+	})
+	if err != nil {
+		return 0, mapError(err, "delete expired proof-of-work challenges")
+	}
+	return rows, nil
+}
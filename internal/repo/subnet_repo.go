@@ -0,0 +1,62 @@
+package repo
+
+import (
+	"context"
+	"time"
+
+	"github.com/faiyaz032/gobox/internal/domain"
+	db "github.com/faiyaz032/gobox/internal/infra/db/sqlc"
+)
+
+type SubnetRepo struct {
+	queries *db.Queries
+}
+
+func NewSubnetRepo(queries *db.Queries) *SubnetRepo {
+	return &SubnetRepo{
+		queries: queries,
+	}
+}
+
+// Create records an allocation. It fails with a conflict when the subnet
+// overlaps one already allocated.
+func (r *SubnetRepo) Create(ctx context.Context, allocation domain.SubnetAllocation) error {
+	err := r.queries.CreateSubnetAllocation(ctx, db.CreateSubnetAllocationParams{
+		Network: allocation.Network,
+		Subnet:  allocation.Subnet,
+	})
+	if err != nil {
+		return mapError(err, "create subnet allocation")
+	}
+	return nil
+}
+
+func (r *SubnetRepo) List(ctx context.Context) ([]domain.SubnetAllocation, error) {
+	dbAllocations, err := r.queries.ListSubnetAllocations(ctx)
+	if err != nil {
+		return nil, mapError(err, "list subnet allocations")
+	}
+
+	allocations := make([]domain.SubnetAllocation, len(dbAllocations))
+	for i, dbAllocation := range dbAllocations {
+		var allocatedAt time.Time
+		if dbAllocation.AllocatedAt.Valid {
+			allocatedAt = dbAllocation.AllocatedAt.Time
+		}
+		allocations[i] = domain.SubnetAllocation{
+			Network:     dbAllocation.Network,
+			Subnet:      dbAllocation.Subnet,
+			AllocatedAt: allocatedAt,
+		}
+	}
+
+	return allocations, nil
+}
+
+func (r *SubnetRepo) Delete(ctx context.Context, network string) error {
+	err := r.queries.DeleteSubnetAllocation(ctx, network)
+	if err != nil {
+		return mapError(err, "delete subnet allocation")
+	}
+	return nil
+}
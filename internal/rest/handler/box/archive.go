@@ -0,0 +1,72 @@
+package boxhandler
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/faiyaz032/gobox/internal/domain"
+	"go.uber.org/zap"
+)
+
+// attachmentWriter sets the download headers on the first write, so an
+// error raised before any data is produced can still be sent as JSON
+type attachmentWriter struct {
+	w        http.ResponseWriter
+	filename string
+	started  bool
+}
+
+func (a *attachmentWriter) Write(p []byte) (int, error) {
+	if !a.started {
+		a.started = true
+		a.w.Header().Set("Content-Type", "application/gzip")
+		a.w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", a.filename))
+		a.w.WriteHeader(http.StatusOK)
+	}
+	return a.w.Write(p)
+}
+
+// ExportBox downloads the caller's box as a portable tar.gz archive
+func (h *Handler) ExportBox(w http.ResponseWriter, r *http.Request) {
+	fingerprint := r.URL.Query().Get("fingerprint")
+	if fingerprint == "" {
+		h.writeError(w, domain.NewValidationError("fingerprint query parameter is required"))
+		return
+	}
+
+	aw := &attachmentWriter{
+		w:        w,
+		filename: "gobox-" + time.Now().UTC().Format("20060102-150405") + ".tar.gz",
+	}
+	if err := h.svc.ExportBox(r.Context(), fingerprint, aw); err != nil {
+		h.logger.Error("Failed to export box",
+			zap.String("fingerprint", fingerprint),
+			zap.Error(err))
+		if !aw.started {
+			h.writeError(w, err)
+		}
+	}
+}
+
+// ImportBox creates the caller's box from an uploaded archive. An existing
+// box is only overwritten with ?replace=true
+func (h *Handler) ImportBox(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	fingerprint := q.Get("fingerprint")
+	if fingerprint == "" {
+		h.writeError(w, domain.NewValidationError("fingerprint query parameter is required"))
+		return
+	}
+
+	box, err := h.svc.ImportBox(r.Context(), fingerprint, r.Body, q.Get("replace") == "true")
+	if err != nil {
+		h.logger.Error("Failed to import box",
+			zap.String("fingerprint", fingerprint),
+			zap.Error(err))
+		h.writeError(w, err)
+		return
+	}
+
+	h.writeJSON(w, http.StatusCreated, box)
+}
@@ -0,0 +1,45 @@
+package boxhandler
+
+import (
+	"net"
+	"net/http"
+
+	"github.com/faiyaz032/gobox/internal/domain"
+)
+
+// GetChallenge issues the proof-of-work challenge solved before the
+// caller's box or lab is created
+func (h *Handler) GetChallenge(w http.ResponseWriter, r *http.Request) {
+	fingerprint := r.URL.Query().Get("fingerprint")
+	if fingerprint == "" {
+		h.writeError(w, domain.NewValidationError("fingerprint query parameter is required"))
+		return
+	}
+
+	challenge, err := h.svc.Challenge(r.Context(), fingerprint, clientIP(r))
+	if err != nil {
+		h.writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	h.writeJSON(w, http.StatusOK, challenge)
+}
+
+// proofFromRequest reads a solved challenge from the challenge and
+// solution query parameters
+func proofFromRequest(r *http.Request) domain.ProofOfWork {
+	return domain.ProofOfWork{
+		Challenge: r.URL.Query().Get("challenge"),
+		Solution:  r.URL.Query().Get("solution"),
+		ClientIP:  clientIP(r),
+	}
+}
+
+func clientIP(r *http.Request) string {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return ip
+}
@@ -0,0 +1,52 @@
+package boxhandler
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/faiyaz032/gobox/internal/domain"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+type cloneBoxRequest struct {
+	Owner string `json:"owner"`
+}
+
+// CloneBox copies the caller's box into a new box for the owner named in
+// the required owner field: another owner without a box, who gets their
+// own variables rather than the caller's, or the caller, whose box is
+// replaced by the copy
+func (h *Handler) CloneBox(w http.ResponseWriter, r *http.Request) {
+	fingerprint := r.URL.Query().Get("fingerprint")
+	if fingerprint == "" {
+		h.writeError(w, domain.NewValidationError("fingerprint query parameter is required"))
+		return
+	}
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		h.writeError(w, domain.NewValidationError("invalid box id"))
+		return
+	}
+
+	var req cloneBoxRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		h.writeError(w, domain.NewValidationError("invalid request body"))
+		return
+	}
+
+	box, err := h.svc.CloneBox(r.Context(), fingerprint, id, req.Owner)
+	if err != nil {
+		h.logger.Error("Failed to clone box",
+			zap.String("fingerprint", fingerprint),
+			zap.String("box_id", id.String()),
+			zap.Error(err))
+		h.writeError(w, err)
+		return
+	}
+
+	h.writeJSON(w, http.StatusCreated, box)
+}
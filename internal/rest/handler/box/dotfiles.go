@@ -0,0 +1,107 @@
+package boxhandler
+
+import (
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+
+	"github.com/faiyaz032/gobox/internal/domain"
+	"go.uber.org/zap"
+)
+
+// maxDotfilesRequestBytes bounds an upload before the service applies the
+// configured dotfiles limit.
+const maxDotfilesRequestBytes = 16 * 1024 * 1024
+
+// UploadDotfiles replaces the caller's dotfiles. The body is either a tar
+// or tar.gz archive, or a multipart form whose field names are the file
+// paths relative to /box
+func (h *Handler) UploadDotfiles(w http.ResponseWriter, r *http.Request) {
+	fingerprint := r.URL.Query().Get("fingerprint")
+	if fingerprint == "" {
+		h.writeError(w, domain.NewValidationError("fingerprint query parameter is required"))
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, maxDotfilesRequestBytes)
+
+	var dotfiles *domain.Dotfiles
+	var err error
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType == "multipart/form-data" {
+		var files []domain.SpecFile
+		files, err = readDotfilesForm(r)
+		if err == nil {
+			dotfiles, err = h.svc.SetDotfiles(r.Context(), fingerprint, files)
+		}
+	} else {
+		dotfiles, err = h.svc.UploadDotfiles(r.Context(), fingerprint, r.Body)
+	}
+	if err != nil {
+		h.logger.Error("Failed to save dotfiles",
+			zap.String("fingerprint", fingerprint),
+			zap.Error(err))
+		h.writeError(w, err)
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, dotfiles)
+}
+
+func readDotfilesForm(r *http.Request) ([]domain.SpecFile, error) {
+	mr, err := r.MultipartReader()
+	if err != nil {
+		return nil, domain.NewValidationError("invalid multipart body")
+	}
+
+	var files []domain.SpecFile
+	for {
+		part, err := mr.NextPart()
+		if errors.Is(err, io.EOF) {
+			return files, nil
+		}
+		if err != nil {
+			return nil, domain.NewValidationError("invalid multipart body")
+		}
+
+		content, err := io.ReadAll(part)
+		part.Close()
+		if err != nil {
+			return nil, domain.NewValidationError("invalid multipart body")
+		}
+		files = append(files, domain.SpecFile{Path: part.FormName(), Content: string(content)})
+	}
+}
+
+// GetDotfiles lists the caller's dotfiles
+func (h *Handler) GetDotfiles(w http.ResponseWriter, r *http.Request) {
+	fingerprint := r.URL.Query().Get("fingerprint")
+	if fingerprint == "" {
+		h.writeError(w, domain.NewValidationError("fingerprint query parameter is required"))
+		return
+	}
+
+	dotfiles, err := h.svc.GetDotfiles(r.Context(), fingerprint)
+	if err != nil {
+		h.writeError(w, err)
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, dotfiles)
+}
+
+// DeleteDotfiles removes the caller's dotfiles; existing boxes keep their
+// copies
+func (h *Handler) DeleteDotfiles(w http.ResponseWriter, r *http.Request) {
+	fingerprint := r.URL.Query().Get("fingerprint")
+	if fingerprint == "" {
+		h.writeError(w, domain.NewValidationError("fingerprint query parameter is required"))
+		return
+	}
+
+	if err := h.svc.DeleteDotfiles(r.Context(), fingerprint); err != nil {
+		h.writeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
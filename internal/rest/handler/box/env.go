@@ -0,0 +1,85 @@
+package boxhandler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/faiyaz032/gobox/internal/domain"
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+)
+
+type setEnvRequest struct {
+	Value     string              `json:"value"`
+	Secret    bool                `json:"secret"`
+	Scope     string              `json:"scope"`
+	Injection domain.EnvInjection `json:"injection"`
+	// Apply is "next_shell" (the default) or "recreate"
+	Apply string `json:"apply"`
+}
+
+// ListEnv lists the caller's variables; secret values are never returned
+func (h *Handler) ListEnv(w http.ResponseWriter, r *http.Request) {
+	fingerprint := r.URL.Query().Get("fingerprint")
+	if fingerprint == "" {
+		h.writeError(w, domain.NewValidationError("fingerprint query parameter is required"))
+		return
+	}
+
+	vars, err := h.svc.ListEnv(r.Context(), fingerprint)
+	if err != nil {
+		h.writeError(w, err)
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, vars)
+}
+
+// SetEnv creates or replaces one of the caller's variables
+func (h *Handler) SetEnv(w http.ResponseWriter, r *http.Request) {
+	fingerprint := r.URL.Query().Get("fingerprint")
+	if fingerprint == "" {
+		h.writeError(w, domain.NewValidationError("fingerprint query parameter is required"))
+		return
+	}
+
+	var req setEnvRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.writeError(w, domain.NewValidationError("invalid request body"))
+		return
+	}
+
+	v, err := h.svc.SetEnv(r.Context(), fingerprint, domain.EnvVar{
+		Name:      chi.URLParam(r, "name"),
+		Scope:     req.Scope,
+		Value:     req.Value,
+		Secret:    req.Secret,
+		Injection: req.Injection,
+	}, req.Apply)
+	if err != nil {
+		h.logger.Error("Failed to set env var",
+			zap.String("fingerprint", fingerprint),
+			zap.Error(err))
+		h.writeError(w, err)
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, v)
+}
+
+// DeleteEnv removes one of the caller's variables
+func (h *Handler) DeleteEnv(w http.ResponseWriter, r *http.Request) {
+	fingerprint := r.URL.Query().Get("fingerprint")
+	if fingerprint == "" {
+		h.writeError(w, domain.NewValidationError("fingerprint query parameter is required"))
+		return
+	}
+
+	q := r.URL.Query()
+	if err := h.svc.DeleteEnv(r.Context(), fingerprint, q.Get("scope"), chi.URLParam(r, "name"), q.Get("apply")); err != nil {
+		h.writeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -3,28 +3,29 @@ package boxhandler
 import (
 	"encoding/json"
 	"net/http"
+	"strconv"
 
 	"github.com/faiyaz032/gobox/internal/domain"
 	"github.com/gorilla/websocket"
 	"go.uber.org/zap"
 )
 
-var upgrader = websocket.Upgrader{
-	ReadBufferSize:  8192,
-	WriteBufferSize: 8192,
-	CheckOrigin: func(r *http.Request) bool {
-		return true
-	},
-}
-
 type Handler struct {
-	svc    Svc
-	logger *zap.Logger
+	svc      Svc
+	upgrader websocket.Upgrader
+	logger   *zap.Logger
 }
 
-func NewHandler(svc Svc, logger *zap.Logger) *Handler {
+// NewHandler returns a handler whose websocket upgrades are accepted when
+// checkOrigin allows the request's origin.
+func NewHandler(svc Svc, checkOrigin func(r *http.Request) bool, logger *zap.Logger) *Handler {
 	return &Handler{
-		svc:    svc,
+		svc: svc,
+		upgrader: websocket.Upgrader{
+			ReadBufferSize:  8192,
+			WriteBufferSize: 8192,
+			CheckOrigin:     checkOrigin,
+		},
 		logger: logger,
 	}
 }
@@ -36,7 +37,7 @@ func (h *Handler) Connect(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	conn, err := upgrader.Upgrade(w, r, nil)
+	conn, err := h.upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		h.logger.Error("Failed to upgrade connection",
 			zap.String("fingerprint", fingerprint),
@@ -49,14 +50,18 @@ func (h *Handler) Connect(w http.ResponseWriter, r *http.Request) {
 	h.logger.Info("WebSocket connection established",
 		zap.String("fingerprint", fingerprint))
 
-	if err := h.svc.Connect(r.Context(), conn, fingerprint); err != nil {
+	if err := h.svc.Connect(r.Context(), conn, fingerprint, proofFromRequest(r)); err != nil {
 		h.logger.Error("Connection error",
 			zap.String("fingerprint", fingerprint),
 			zap.Error(err))
 		
 		// Extract error message for websocket close
 		errorMsg := domain.GetErrorMessage(err)
-		conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseInternalServerErr, errorMsg))
+		closeCode := websocket.CloseInternalServerErr
+		if domain.GetErrorType(err) == domain.ErrorTypeRateLimited {
+			closeCode = websocket.CloseTryAgainLater
+		}
+		conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(closeCode, errorMsg))
 		return
 	}
 
@@ -64,6 +69,58 @@ func (h *Handler) Connect(w http.ResponseWriter, r *http.Request) {
 		zap.String("fingerprint", fingerprint))
 }
 
+// GetHomeData reports the size of the caller's persistent home volume
+func (h *Handler) GetHomeData(w http.ResponseWriter, r *http.Request) {
+	fingerprint := r.URL.Query().Get("fingerprint")
+	if fingerprint == "" {
+		h.writeError(w, domain.NewValidationError("fingerprint query parameter is required"))
+		return
+	}
+
+	volume, err := h.svc.HomeVolumeUsage(r.Context(), fingerprint)
+	if err != nil {
+		h.writeError(w, err)
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, volume)
+}
+
+// DeleteHomeData deletes the caller's box and persistent home volume
+func (h *Handler) DeleteHomeData(w http.ResponseWriter, r *http.Request) {
+	fingerprint := r.URL.Query().Get("fingerprint")
+	if fingerprint == "" {
+		h.writeError(w, domain.NewValidationError("fingerprint query parameter is required"))
+		return
+	}
+
+	if err := h.svc.DeleteHomeData(r.Context(), fingerprint); err != nil {
+		h.logger.Error("Failed to delete home data",
+			zap.String("fingerprint", fingerprint),
+			zap.Error(err))
+		h.writeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// writeJSON writes a JSON success response
+func (h *Handler) writeJSON(w http.ResponseWriter, statusCode int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+
+	if err := json.NewEncoder(w).Encode(data); err != nil {
+		h.logger.Error("Failed to encode response", zap.Error(err))
+	}
+}
+
+// WriteError writes an error response the way the box API does, for
+// middleware that refuses requests before they reach a handler.
+func (h *Handler) WriteError(w http.ResponseWriter, err error) {
+	h.writeError(w, err)
+}
+
 // writeError writes an error response using AppError
 func (h *Handler) writeError(w http.ResponseWriter, err error) {
 	statusCode := domain.GetStatusCode(err)
@@ -71,6 +128,9 @@ func (h *Handler) writeError(w http.ResponseWriter, err error) {
 	message := domain.GetErrorMessage(err)
 
 	w.Header().Set("Content-Type", "application/json")
+	if retryAfter := domain.GetRetryAfter(err); retryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+	}
 	w.WriteHeader(statusCode)
 	
 	response := map[string]interface{}{
@@ -2,10 +2,32 @@ package boxhandler
 
 import (
 	"context"
+	"io"
 
+	"github.com/faiyaz032/gobox/internal/domain"
+	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
 )
 
 type Svc interface {
-	Connect(ctx context.Context, conn *websocket.Conn, fingerprint string) error
+	Challenge(ctx context.Context, fingerprint, ip string) (*domain.PowChallenge, error)
+	Connect(ctx context.Context, conn *websocket.Conn, fingerprint string, proof domain.ProofOfWork) error
+	Status(ctx context.Context, fingerprint string) (*domain.BoxStatusReport, error)
+	CreateBox(ctx context.Context, fingerprint string, spec domain.BoxSpec, proof domain.ProofOfWork) (*domain.Box, error)
+	HomeVolumeUsage(ctx context.Context, fingerprint string) (*domain.HomeVolume, error)
+	DeleteHomeData(ctx context.Context, fingerprint string) error
+	CreateSnapshot(ctx context.Context, fingerprint, name string) (*domain.Snapshot, error)
+	ListSnapshots(ctx context.Context, fingerprint string) ([]domain.Snapshot, error)
+	RestoreSnapshot(ctx context.Context, fingerprint string, id uuid.UUID) (*domain.Box, error)
+	DeleteSnapshot(ctx context.Context, fingerprint string, id uuid.UUID) error
+	ExportBox(ctx context.Context, fingerprint string, w io.Writer) error
+	ImportBox(ctx context.Context, fingerprint string, r io.Reader, replace bool) (*domain.Box, error)
+	UploadDotfiles(ctx context.Context, fingerprint string, r io.Reader) (*domain.Dotfiles, error)
+	SetDotfiles(ctx context.Context, fingerprint string, files []domain.SpecFile) (*domain.Dotfiles, error)
+	GetDotfiles(ctx context.Context, fingerprint string) (*domain.Dotfiles, error)
+	DeleteDotfiles(ctx context.Context, fingerprint string) error
+	ListEnv(ctx context.Context, fingerprint string) ([]domain.EnvVar, error)
+	SetEnv(ctx context.Context, fingerprint string, v domain.EnvVar, apply string) (*domain.EnvVar, error)
+	DeleteEnv(ctx context.Context, fingerprint, scope, name, apply string) error
+	CloneBox(ctx context.Context, fingerprint string, id uuid.UUID, target string) (*domain.Box, error)
 }
@@ -1,9 +1,36 @@
 package boxhandler
 
-import "github.com/go-chi/chi/v5"
+import (
+	"net/http"
 
-func RegisterRoutes(r chi.Router, h *Handler) {
+	"github.com/go-chi/chi/v5"
+)
+
+// RegisterRoutes mounts the box API. admit guards the routes that create
+// or start containers.
+func RegisterRoutes(r chi.Router, h *Handler, admit func(http.Handler) http.Handler) {
 	r.Route("/api/v1/box", func(r chi.Router) {
-		r.Get("/connect", h.Connect)
+		r.With(admit).Post("/", h.CreateBox)
+		r.With(admit).Get("/connect", h.Connect)
+		r.Get("/challenge", h.GetChallenge)
+		r.Get("/status", h.GetStatus)
+		r.Get("/data", h.GetHomeData)
+		r.Delete("/data", h.DeleteHomeData)
+		r.Get("/export", h.ExportBox)
+		r.With(admit).Post("/import", h.ImportBox)
+		r.With(admit).Post("/{id}/clone", h.CloneBox)
+		r.Get("/dotfiles", h.GetDotfiles)
+		r.Put("/dotfiles", h.UploadDotfiles)
+		r.Delete("/dotfiles", h.DeleteDotfiles)
+		r.Get("/env", h.ListEnv)
+		r.Put("/env/{name}", h.SetEnv)
+		r.Delete("/env/{name}", h.DeleteEnv)
+
+		r.Route("/snapshots", func(r chi.Router) {
+			r.Post("/", h.CreateSnapshot)
+			r.Get("/", h.ListSnapshots)
+			r.Post("/{id}/restore", h.RestoreSnapshot)
+			r.Delete("/{id}", h.DeleteSnapshot)
+		})
 	})
 }
@@ -0,0 +1,111 @@
+package boxhandler
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/faiyaz032/gobox/internal/domain"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+type createSnapshotRequest struct {
+	Name string `json:"name"`
+}
+
+// CreateSnapshot checkpoints the caller's box
+func (h *Handler) CreateSnapshot(w http.ResponseWriter, r *http.Request) {
+	fingerprint := r.URL.Query().Get("fingerprint")
+	if fingerprint == "" {
+		h.writeError(w, domain.NewValidationError("fingerprint query parameter is required"))
+		return
+	}
+
+	var req createSnapshotRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		h.writeError(w, domain.NewValidationError("invalid request body"))
+		return
+	}
+
+	snapshot, err := h.svc.CreateSnapshot(r.Context(), fingerprint, req.Name)
+	if err != nil {
+		h.logger.Error("Failed to create snapshot",
+			zap.String("fingerprint", fingerprint),
+			zap.Error(err))
+		h.writeError(w, err)
+		return
+	}
+
+	h.writeJSON(w, http.StatusCreated, snapshot)
+}
+
+// ListSnapshots lists the caller's snapshots, newest first
+func (h *Handler) ListSnapshots(w http.ResponseWriter, r *http.Request) {
+	fingerprint := r.URL.Query().Get("fingerprint")
+	if fingerprint == "" {
+		h.writeError(w, domain.NewValidationError("fingerprint query parameter is required"))
+		return
+	}
+
+	snapshots, err := h.svc.ListSnapshots(r.Context(), fingerprint)
+	if err != nil {
+		h.writeError(w, err)
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, snapshots)
+}
+
+// RestoreSnapshot recreates the caller's box from a snapshot
+func (h *Handler) RestoreSnapshot(w http.ResponseWriter, r *http.Request) {
+	fingerprint := r.URL.Query().Get("fingerprint")
+	if fingerprint == "" {
+		h.writeError(w, domain.NewValidationError("fingerprint query parameter is required"))
+		return
+	}
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		h.writeError(w, domain.NewValidationError("invalid snapshot id"))
+		return
+	}
+
+	box, err := h.svc.RestoreSnapshot(r.Context(), fingerprint, id)
+	if err != nil {
+		h.logger.Error("Failed to restore snapshot",
+			zap.String("fingerprint", fingerprint),
+			zap.String("snapshot_id", id.String()),
+			zap.Error(err))
+		h.writeError(w, err)
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, box)
+}
+
+// DeleteSnapshot removes a snapshot and its image
+func (h *Handler) DeleteSnapshot(w http.ResponseWriter, r *http.Request) {
+	fingerprint := r.URL.Query().Get("fingerprint")
+	if fingerprint == "" {
+		h.writeError(w, domain.NewValidationError("fingerprint query parameter is required"))
+		return
+	}
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		h.writeError(w, domain.NewValidationError("invalid snapshot id"))
+		return
+	}
+
+	if err := h.svc.DeleteSnapshot(r.Context(), fingerprint, id); err != nil {
+		h.logger.Error("Failed to delete snapshot",
+			zap.String("fingerprint", fingerprint),
+			zap.String("snapshot_id", id.String()),
+			zap.Error(err))
+		h.writeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
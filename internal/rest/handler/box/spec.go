@@ -0,0 +1,44 @@
+package boxhandler
+
+import (
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/faiyaz032/gobox/internal/domain"
+	"go.uber.org/zap"
+	"go.yaml.in/yaml/v3"
+)
+
+// maxSpecBytes bounds the request body of CreateBox; seeded files make up
+// most of a spec.
+const maxSpecBytes = 2 * 1024 * 1024
+
+// CreateBox creates the caller's box from a YAML or JSON spec
+func (h *Handler) CreateBox(w http.ResponseWriter, r *http.Request) {
+	fingerprint := r.URL.Query().Get("fingerprint")
+	if fingerprint == "" {
+		h.writeError(w, domain.NewValidationError("fingerprint query parameter is required"))
+		return
+	}
+
+	// JSON is valid YAML, so one decoder handles both
+	var spec domain.BoxSpec
+	dec := yaml.NewDecoder(http.MaxBytesReader(w, r.Body, maxSpecBytes))
+	dec.KnownFields(true)
+	if err := dec.Decode(&spec); err != nil && !errors.Is(err, io.EOF) {
+		h.writeError(w, domain.NewValidationError("invalid box spec: "+err.Error()))
+		return
+	}
+
+	box, err := h.svc.CreateBox(r.Context(), fingerprint, spec, proofFromRequest(r))
+	if err != nil {
+		h.logger.Error("Failed to create box",
+			zap.String("fingerprint", fingerprint),
+			zap.Error(err))
+		h.writeError(w, err)
+		return
+	}
+
+	h.writeJSON(w, http.StatusCreated, box)
+}
@@ -0,0 +1,24 @@
+package boxhandler
+
+import (
+	"net/http"
+
+	"github.com/faiyaz032/gobox/internal/domain"
+)
+
+// GetStatus reports the caller's box, including its container runtime
+func (h *Handler) GetStatus(w http.ResponseWriter, r *http.Request) {
+	fingerprint := r.URL.Query().Get("fingerprint")
+	if fingerprint == "" {
+		h.writeError(w, domain.NewValidationError("fingerprint query parameter is required"))
+		return
+	}
+
+	status, err := h.svc.Status(r.Context(), fingerprint)
+	if err != nil {
+		h.writeError(w, err)
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, status)
+}
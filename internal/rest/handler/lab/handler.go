@@ -0,0 +1,212 @@
+package labhandler
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+
+	"github.com/faiyaz032/gobox/internal/domain"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+	"go.uber.org/zap"
+	"go.yaml.in/yaml/v3"
+)
+
+// maxSpecBytes bounds the request body of CreateLab
+const maxSpecBytes = 64 * 1024
+
+type Handler struct {
+	svc      Svc
+	upgrader websocket.Upgrader
+	logger   *zap.Logger
+}
+
+// NewHandler returns a handler whose websocket upgrades are accepted when
+// checkOrigin allows the request's origin.
+func NewHandler(svc Svc, checkOrigin func(r *http.Request) bool, logger *zap.Logger) *Handler {
+	return &Handler{
+		svc: svc,
+		upgrader: websocket.Upgrader{
+			ReadBufferSize:  8192,
+			WriteBufferSize: 8192,
+			CheckOrigin:     checkOrigin,
+		},
+		logger: logger,
+	}
+}
+
+// CreateLab creates a lab for the caller from a YAML or JSON spec. Like a
+// box connect it takes a solved challenge in the challenge and solution
+// query parameters
+func (h *Handler) CreateLab(w http.ResponseWriter, r *http.Request) {
+	fingerprint := r.URL.Query().Get("fingerprint")
+	if fingerprint == "" {
+		h.writeError(w, domain.NewValidationError("fingerprint query parameter is required"))
+		return
+	}
+
+	// JSON is valid YAML, so one decoder handles both
+	var spec domain.LabSpec
+	dec := yaml.NewDecoder(http.MaxBytesReader(w, r.Body, maxSpecBytes))
+	dec.KnownFields(true)
+	if err := dec.Decode(&spec); err != nil && !errors.Is(err, io.EOF) {
+		h.writeError(w, domain.NewValidationError("invalid lab spec: "+err.Error()))
+		return
+	}
+
+	lab, err := h.svc.CreateLab(r.Context(), fingerprint, spec, proofFromRequest(r))
+	if err != nil {
+		h.logger.Error("Failed to create lab",
+			zap.String("fingerprint", fingerprint),
+			zap.Error(err))
+		h.writeError(w, err)
+		return
+	}
+
+	h.writeJSON(w, http.StatusCreated, lab)
+}
+
+// ListLabs lists the caller's labs, newest first
+func (h *Handler) ListLabs(w http.ResponseWriter, r *http.Request) {
+	fingerprint := r.URL.Query().Get("fingerprint")
+	if fingerprint == "" {
+		h.writeError(w, domain.NewValidationError("fingerprint query parameter is required"))
+		return
+	}
+
+	labs, err := h.svc.ListLabs(r.Context(), fingerprint)
+	if err != nil {
+		h.writeError(w, err)
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, labs)
+}
+
+// GetLab returns one of the caller's labs with its subnets and addresses
+func (h *Handler) GetLab(w http.ResponseWriter, r *http.Request) {
+	fingerprint := r.URL.Query().Get("fingerprint")
+	if fingerprint == "" {
+		h.writeError(w, domain.NewValidationError("fingerprint query parameter is required"))
+		return
+	}
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		h.writeError(w, domain.NewValidationError("invalid lab id"))
+		return
+	}
+
+	lab, err := h.svc.GetLab(r.Context(), fingerprint, id)
+	if err != nil {
+		h.writeError(w, err)
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, lab)
+}
+
+// DeleteLab tears down one of the caller's labs
+func (h *Handler) DeleteLab(w http.ResponseWriter, r *http.Request) {
+	fingerprint := r.URL.Query().Get("fingerprint")
+	if fingerprint == "" {
+		h.writeError(w, domain.NewValidationError("fingerprint query parameter is required"))
+		return
+	}
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		h.writeError(w, domain.NewValidationError("invalid lab id"))
+		return
+	}
+
+	if err := h.svc.DeleteLab(r.Context(), fingerprint, id); err != nil {
+		h.logger.Error("Failed to delete lab",
+			zap.String("fingerprint", fingerprint),
+			zap.String("lab_id", id.String()),
+			zap.Error(err))
+		h.writeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Connect attaches a terminal to one node of a lab
+func (h *Handler) Connect(w http.ResponseWriter, r *http.Request) {
+	fingerprint := r.URL.Query().Get("fingerprint")
+	if fingerprint == "" {
+		h.writeError(w, domain.NewValidationError("fingerprint query parameter is required"))
+		return
+	}
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		h.writeError(w, domain.NewValidationError("invalid lab id"))
+		return
+	}
+	node := chi.URLParam(r, "node")
+
+	conn, err := h.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		h.logger.Error("Failed to upgrade connection",
+			zap.String("fingerprint", fingerprint),
+			zap.Error(err))
+		return
+	}
+	defer conn.Close()
+
+	if err := h.svc.Connect(r.Context(), conn, fingerprint, id, node); err != nil {
+		h.logger.Error("Lab connection error",
+			zap.String("fingerprint", fingerprint),
+			zap.String("lab_id", id.String()),
+			zap.String("node", node),
+			zap.Error(err))
+		_ = conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseInternalServerErr, domain.GetErrorMessage(err)))
+	}
+}
+
+// proofFromRequest reads a solved challenge, issued by the box API, from
+// the challenge and solution query parameters
+func proofFromRequest(r *http.Request) domain.ProofOfWork {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+	return domain.ProofOfWork{
+		Challenge: r.URL.Query().Get("challenge"),
+		Solution:  r.URL.Query().Get("solution"),
+		ClientIP:  ip,
+	}
+}
+
+// writeJSON writes a JSON success response
+func (h *Handler) writeJSON(w http.ResponseWriter, statusCode int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+
+	if err := json.NewEncoder(w).Encode(data); err != nil {
+		h.logger.Error("Failed to encode response", zap.Error(err))
+	}
+}
+
+// writeError writes an error response using AppError
+func (h *Handler) writeError(w http.ResponseWriter, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	if retryAfter := domain.GetRetryAfter(err); retryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+	}
+	w.WriteHeader(domain.GetStatusCode(err))
+
+	response := map[string]interface{}{
+		"error": map[string]interface{}{
+			"type":    domain.GetErrorType(err),
+			"message": domain.GetErrorMessage(err),
+		},
+	}
+
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		h.logger.Error("Failed to encode error response", zap.Error(err))
+	}
+}
@@ -0,0 +1,17 @@
+package labhandler
+
+import (
+	"context"
+
+	"github.com/faiyaz032/gobox/internal/domain"
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+)
+
+type Svc interface {
+	CreateLab(ctx context.Context, fingerprint string, spec domain.LabSpec, proof domain.ProofOfWork) (*domain.Lab, error)
+	ListLabs(ctx context.Context, fingerprint string) ([]domain.Lab, error)
+	GetLab(ctx context.Context, fingerprint string, id uuid.UUID) (*domain.Lab, error)
+	DeleteLab(ctx context.Context, fingerprint string, id uuid.UUID) error
+	Connect(ctx context.Context, conn *websocket.Conn, fingerprint string, id uuid.UUID, node string) error
+}
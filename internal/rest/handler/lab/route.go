@@ -0,0 +1,19 @@
+package labhandler
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// RegisterRoutes mounts the lab API. admit guards the routes that create
+// or start containers.
+func RegisterRoutes(r chi.Router, h *Handler, admit func(http.Handler) http.Handler) {
+	r.Route("/api/v1/lab", func(r chi.Router) {
+		r.With(admit).Post("/", h.CreateLab)
+		r.Get("/", h.ListLabs)
+		r.Get("/{id}", h.GetLab)
+		r.Delete("/{id}", h.DeleteLab)
+		r.With(admit).Get("/{id}/nodes/{node}/connect", h.Connect)
+	})
+}
@@ -0,0 +1,48 @@
+package middleware
+
+import (
+	"net"
+	"net/http"
+
+	"github.com/faiyaz032/gobox/internal/domain"
+	"github.com/faiyaz032/gobox/internal/ratelimit"
+	"go.uber.org/zap"
+)
+
+// Admission rate limits requests that create or start containers, by
+// client IP and by the owner named in the fingerprint query parameter.
+// Refused requests are answered through writeError, so they carry the same
+// error body as the API behind the middleware. A nil limiter lets every
+// request through on that key.
+func Admission(perIP, perOwner *ratelimit.Limiter, writeError func(http.ResponseWriter, error), logger *zap.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ip, _, err := net.SplitHostPort(r.RemoteAddr)
+			if err != nil {
+				ip = r.RemoteAddr
+			}
+			fingerprint := r.URL.Query().Get("fingerprint")
+
+			if perIP != nil {
+				if ok, retryAfter := perIP.Allow(ip); !ok {
+					logger.Warn("Rate limited client",
+						zap.String("ip", ip),
+						zap.String("path", r.URL.Path))
+					writeError(w, domain.NewRateLimitError("too many requests from this address", retryAfter))
+					return
+				}
+			}
+			if perOwner != nil && fingerprint != "" {
+				if ok, retryAfter := perOwner.Allow(fingerprint); !ok {
+					logger.Warn("Rate limited owner",
+						zap.String("fingerprint", fingerprint),
+						zap.String("path", r.URL.Path))
+					writeError(w, domain.NewRateLimitError("too many requests for this box", retryAfter))
+					return
+				}
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
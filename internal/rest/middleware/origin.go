@@ -0,0 +1,108 @@
+package middleware
+
+import (
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// Origins decides which browser origins may call the API and open
+// terminals. Requests without an Origin header and same-origin requests,
+// such as those from the bundled frontend, are always allowed.
+type Origins struct {
+	allowed        map[string]bool
+	allowLocalhost bool
+	logger         *zap.Logger
+}
+
+// NewOrigins allows the listed origins, e.g. "https://gobox.example.com".
+// allowLocalhost additionally allows any localhost origin, for frontends
+// served by a development server.
+func NewOrigins(allowed []string, allowLocalhost bool, logger *zap.Logger) *Origins {
+	o := &Origins{
+		allowed:        make(map[string]bool, len(allowed)),
+		allowLocalhost: allowLocalhost,
+		logger:         logger,
+	}
+	for _, origin := range allowed {
+		o.allowed[strings.ToLower(origin)] = true
+	}
+	return o
+}
+
+// Allowed reports whether the request's origin may use the API.
+func (o *Origins) Allowed(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil || u.Host == "" {
+		return false
+	}
+	if strings.EqualFold(u.Host, r.Host) || o.allowed[strings.ToLower(origin)] {
+		return true
+	}
+	if o.allowLocalhost {
+		host := u.Hostname()
+		if host == "localhost" {
+			return true
+		}
+		if ip := net.ParseIP(host); ip != nil && ip.IsLoopback() {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckOrigin is a websocket.Upgrader CheckOrigin that logs rejected
+// upgrades.
+func (o *Origins) CheckOrigin(r *http.Request) bool {
+	if o.Allowed(r) {
+		return true
+	}
+	o.logger.Warn("Rejected websocket upgrade from disallowed origin",
+		zap.String("origin", r.Header.Get("Origin")),
+		zap.String("path", r.URL.Path),
+		zap.String("remote_addr", r.RemoteAddr))
+	return false
+}
+
+// CORS answers preflight requests and grants allowed cross-origin callers
+// access to responses. Disallowed origins get no CORS headers, so
+// browsers withhold the response.
+func (o *Origins) CORS(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Add("Vary", "Origin")
+		allowed := o.Allowed(r)
+		if allowed {
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+			w.Header().Set("Access-Control-Expose-Headers", "Retry-After, Content-Disposition")
+		}
+
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			if allowed {
+				w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE")
+				w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+				w.Header().Set("Access-Control-Max-Age", "600")
+			} else {
+				o.logger.Warn("Rejected CORS preflight from disallowed origin",
+					zap.String("origin", origin),
+					zap.String("path", r.URL.Path))
+			}
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
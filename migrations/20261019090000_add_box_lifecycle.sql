@@ -0,0 +1,17 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE box
+    ADD COLUMN tier       TEXT NOT NULL DEFAULT 'default',
+    ADD COLUMN created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP;
+
+-- Index for the hard expiry sweep
+CREATE INDEX idx_box_created_at ON box(created_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_box_created_at;
+ALTER TABLE box
+    DROP COLUMN IF EXISTS created_at,
+    DROP COLUMN IF EXISTS tier;
+-- +goose StatementEnd
@@ -0,0 +1,15 @@
+-- +goose NO TRANSACTION
+-- +goose Up
+ALTER TYPE box_status ADD VALUE IF NOT EXISTS 'stopped';
+
+-- +goose Down
+-- +goose StatementBegin
+-- Postgres cannot drop enum values, so rebuild the type without 'stopped'
+UPDATE box SET status = 'paused' WHERE status = 'stopped';
+ALTER TABLE box ALTER COLUMN status DROP DEFAULT;
+ALTER TYPE box_status RENAME TO box_status_old;
+CREATE TYPE box_status AS ENUM ('running', 'paused');
+ALTER TABLE box ALTER COLUMN status TYPE box_status USING status::text::box_status;
+ALTER TABLE box ALTER COLUMN status SET DEFAULT 'running';
+DROP TYPE box_status_old;
+-- +goose StatementEnd
@@ -0,0 +1,23 @@
+-- +goose NO TRANSACTION
+-- +goose Up
+ALTER TYPE box_status ADD VALUE IF NOT EXISTS 'hibernated';
+
+-- +goose StatementBegin
+-- Image the box's container is created from; hibernation points it at
+-- the committed snapshot of the container
+ALTER TABLE box
+    ADD COLUMN image TEXT NOT NULL DEFAULT 'gobox-base:latest';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+-- Hibernated boxes have no container left to fall back to
+DELETE FROM box WHERE status = 'hibernated';
+ALTER TABLE box DROP COLUMN IF EXISTS image;
+ALTER TABLE box ALTER COLUMN status DROP DEFAULT;
+ALTER TYPE box_status RENAME TO box_status_old;
+CREATE TYPE box_status AS ENUM ('running', 'paused', 'stopped');
+ALTER TABLE box ALTER COLUMN status TYPE box_status USING status::text::box_status;
+ALTER TABLE box ALTER COLUMN status SET DEFAULT 'running';
+DROP TYPE box_status_old;
+-- +goose StatementEnd
@@ -0,0 +1,22 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Snapshots belong to the owner rather than a box row, so they survive the
+-- box being expired or recreated
+CREATE TABLE box_snapshot (
+    id             UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    fingerprint_id TEXT NOT NULL,
+    box_id         UUID NOT NULL,
+    name           TEXT NOT NULL,
+    image          TEXT NOT NULL,
+    size_bytes     BIGINT NOT NULL DEFAULT 0,
+    created_at     TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Index for listing and quota checks per owner
+CREATE INDEX idx_box_snapshot_fingerprint_id ON box_snapshot(fingerprint_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS box_snapshot;
+-- +goose StatementEnd
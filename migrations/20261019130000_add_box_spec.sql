@@ -0,0 +1,12 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Declarative spec the box was created from; '{}' for boxes predating
+-- specs, which use the server defaults
+ALTER TABLE box
+    ADD COLUMN spec JSONB NOT NULL DEFAULT '{}';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE box DROP COLUMN IF EXISTS spec;
+-- +goose StatementEnd
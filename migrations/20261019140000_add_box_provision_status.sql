@@ -0,0 +1,13 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Progress of the box's provisioning scripts; existing boxes have nothing
+-- left to run
+ALTER TABLE box
+    ADD COLUMN provision_status TEXT NOT NULL DEFAULT 'ready'
+        CHECK (provision_status IN ('pending', 'running', 'ready', 'failed'));
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE box DROP COLUMN IF EXISTS provision_status;
+-- +goose StatementEnd
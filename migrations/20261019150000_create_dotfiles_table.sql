@@ -0,0 +1,17 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Per-owner dotfiles, kept as a normalized tar archive that is copied into
+-- /box of every new container
+CREATE TABLE dotfiles (
+    fingerprint_id TEXT PRIMARY KEY,
+    archive        BYTEA NOT NULL,
+    files          TEXT[] NOT NULL,
+    size_bytes     BIGINT NOT NULL DEFAULT 0,
+    updated_at     TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS dotfiles;
+-- +goose StatementEnd
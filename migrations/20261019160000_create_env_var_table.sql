@@ -0,0 +1,27 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Per-owner environment variables. Secrets keep only the AES-GCM sealed
+-- value in ciphertext; plain variables keep value
+CREATE TABLE env_var (
+    fingerprint_id TEXT NOT NULL,
+    name           TEXT NOT NULL,
+    value          TEXT,
+    ciphertext     BYTEA,
+    injection      TEXT NOT NULL DEFAULT 'create'
+        CHECK (injection IN ('create', 'exec')),
+    updated_at     TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (fingerprint_id, name),
+    CHECK ((value IS NULL) <> (ciphertext IS NULL))
+);
+
+-- Set when variables changed and the box's container should be recreated
+-- on the next shell
+ALTER TABLE box
+    ADD COLUMN env_stale BOOLEAN NOT NULL DEFAULT false;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE box DROP COLUMN IF EXISTS env_stale;
+DROP TABLE IF EXISTS env_var;
+-- +goose StatementEnd
@@ -0,0 +1,19 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Sidecar containers running next to a box on its private network. Rows go
+-- with their box; the containers are removed by the service first
+CREATE TABLE box_sidecar (
+    id           UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    box_id       UUID NOT NULL REFERENCES box(id) ON DELETE CASCADE,
+    name         TEXT NOT NULL,
+    template     TEXT NOT NULL,
+    container_id TEXT NOT NULL,
+    created_at   TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (box_id, name)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS box_sidecar;
+-- +goose StatementEnd
@@ -0,0 +1,39 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Network labs: several boxes of one owner joined by networks on subnets
+-- of their own
+CREATE TABLE lab (
+    id             UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    fingerprint_id TEXT NOT NULL,
+    name           TEXT NOT NULL,
+    spec           JSONB NOT NULL,
+    created_at     TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE lab_network (
+    lab_id UUID NOT NULL REFERENCES lab(id) ON DELETE CASCADE,
+    name   TEXT NOT NULL,
+    subnet CIDR NOT NULL,
+    PRIMARY KEY (lab_id, name)
+);
+
+CREATE TABLE lab_node (
+    lab_id       UUID NOT NULL REFERENCES lab(id) ON DELETE CASCADE,
+    name         TEXT NOT NULL,
+    container_id TEXT NOT NULL,
+    addresses    JSONB NOT NULL DEFAULT '{}',
+    PRIMARY KEY (lab_id, name)
+);
+
+-- Index for listing and quota checks per owner
+CREATE INDEX idx_lab_fingerprint_id ON lab(fingerprint_id);
+-- Index for the expiry sweep
+CREATE INDEX idx_lab_created_at ON lab(created_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS lab_node;
+DROP TABLE IF EXISTS lab_network;
+DROP TABLE IF EXISTS lab;
+-- +goose StatementEnd
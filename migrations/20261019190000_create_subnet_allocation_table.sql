@@ -0,0 +1,17 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Subnets carved from the configured pools for per-box and per-lab
+-- networks. The exclusion constraint keeps concurrent servers from handing
+-- out overlapping subnets
+CREATE TABLE subnet_allocation (
+    network      TEXT PRIMARY KEY,
+    subnet       CIDR NOT NULL,
+    allocated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    EXCLUDE USING gist (subnet inet_ops WITH &&)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS subnet_allocation;
+-- +goose StatementEnd
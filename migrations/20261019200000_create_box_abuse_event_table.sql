@@ -0,0 +1,21 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Abuse flagged by the box watchdog and the action taken, for admins to
+-- review. Rows outlive the box so bans keep applying to its owner
+CREATE TABLE box_abuse_event (
+    id             UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    fingerprint_id TEXT NOT NULL,
+    container_id   TEXT NOT NULL,
+    kind           TEXT NOT NULL,
+    detail         TEXT NOT NULL,
+    action         TEXT NOT NULL,
+    created_at     TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_box_abuse_event_fingerprint ON box_abuse_event(fingerprint_id, created_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS box_abuse_event;
+-- +goose StatementEnd
@@ -0,0 +1,19 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Terminals a server replica holds open to a box, renewed by heartbeat.
+-- A box is only paused or removed when no other replica holds a live
+-- lease on it
+CREATE TABLE box_connection_lease (
+    fingerprint_id TEXT NOT NULL,
+    replica_id     TEXT NOT NULL,
+    expires_at     TIMESTAMP NOT NULL,
+    PRIMARY KEY (fingerprint_id, replica_id)
+);
+
+CREATE INDEX idx_box_connection_lease_replica ON box_connection_lease(replica_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS box_connection_lease;
+-- +goose StatementEnd
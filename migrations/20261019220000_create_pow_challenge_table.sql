@@ -0,0 +1,19 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Proof-of-work challenges accepted by any replica. A row keeps its
+-- challenge from being accepted again until it expires and counts a
+-- creation against the client IP
+CREATE TABLE pow_challenge (
+    nonce      TEXT PRIMARY KEY,
+    client_ip  TEXT NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_pow_challenge_client_ip ON pow_challenge(client_ip, created_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS pow_challenge;
+-- +goose StatementEnd
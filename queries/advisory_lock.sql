@@ -0,0 +1,6 @@
+-- name: TryAdvisoryLock :one
+-- Session-level lock; it must be released on the same connection
+SELECT pg_try_advisory_lock(@key::bigint);
+
+-- name: AdvisoryUnlock :one
+SELECT pg_advisory_unlock(@key::bigint);
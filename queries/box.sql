@@ -3,9 +3,13 @@ INSERT INTO box (
     fingerprint_id,
     container_id,
     status,
-    last_active
+    last_active,
+    tier,
+    image,
+    spec,
+    provision_status
 ) VALUES (
-    $1, $2, $3, $4
+    $1, $2, $3, $4, $5, $6, $7, $8
 )
 RETURNING *;
 
@@ -13,6 +17,10 @@ RETURNING *;
 SELECT * FROM box
 WHERE fingerprint_id = $1 LIMIT 1;
 
+-- name: GetBoxByID :one
+SELECT * FROM box
+WHERE id = $1 LIMIT 1;
+
 -- name: GetBoxByContainerID :one
 SELECT * FROM box
 WHERE container_id = $1 LIMIT 1;
@@ -22,22 +30,75 @@ UPDATE box
 SET status = $2
 WHERE fingerprint_id = $1;
 
+-- name: UpdateBoxContainer :exec
+-- Used when a box's container is replaced, e.g. by hibernation
+UPDATE box
+SET container_id = $2,
+    image = $3,
+    status = $4
+WHERE fingerprint_id = $1;
+
+-- name: UpdateBoxSpec :exec
+UPDATE box
+SET spec = $2
+WHERE fingerprint_id = $1;
+
+-- name: ReplaceBoxContainer :exec
+-- Swaps a box's container together with the spec and tier it was created from
+UPDATE box
+SET container_id = $2,
+    image = $3,
+    status = $4,
+    spec = $5,
+    tier = $6
+WHERE fingerprint_id = $1;
+
+-- name: UpdateBoxProvisionStatus :exec
+UPDATE box
+SET provision_status = $2
+WHERE fingerprint_id = $1;
+
+-- name: ClaimBoxProvision :execrows
+-- Moves a pending box to running so only one connection provisions it
+UPDATE box
+SET provision_status = 'running'
+WHERE fingerprint_id = $1 AND provision_status = 'pending';
+
+-- name: SetBoxEnvStale :exec
+UPDATE box
+SET env_stale = $2
+WHERE fingerprint_id = $1;
+
 -- name: TouchBox :exec
--- Updates last_active and ensures status is 'active'
+-- Records terminal activity; status is owned by UpdateBoxStatus
 UPDATE box
-SET last_active = $2,
-    status = 'active'
+SET last_active = $2
 WHERE fingerprint_id = $1;
 
 -- name: DeleteBox :exec
 DELETE FROM box
 WHERE fingerprint_id = $1;
 
+-- name: DeleteBoxIfUnchanged :execrows
+-- Deletes a box only while it still has the container, image and status the caller read
+DELETE FROM box
+WHERE fingerprint_id = $1 AND container_id = $2 AND image = $3 AND status = $4;
+
 -- name: GetExpiredBoxes :many
--- Used by the 24h cleanup worker
+-- Used by the lifecycle scheduler's inactivity sweep
 SELECT * FROM box
 WHERE last_active < $1;
 
+-- name: GetBoxesCreatedBefore :many
+-- Used by the lifecycle scheduler's hard expiry sweep
+SELECT * FROM box
+WHERE created_at < $1;
+
 -- name: ListBoxesByStatus :many
 SELECT * FROM box
 WHERE status = $1;
+
+-- name: CountBoxesByStatus :one
+-- Used to cap the containers running at once
+SELECT COUNT(*) FROM box
+WHERE status = $1;
@@ -0,0 +1,28 @@
+-- name: CreateBoxAbuseEvent :one
+INSERT INTO box_abuse_event (
+    fingerprint_id,
+    container_id,
+    kind,
+    detail,
+    action
+) VALUES (
+    $1, $2, $3, $4, $5
+)
+RETURNING *;
+
+-- name: CountBoxAbuseEventsSince :one
+-- Used to escalate an owner's repeat offences
+SELECT COUNT(*) FROM box_abuse_event
+WHERE fingerprint_id = $1 AND created_at >= $2;
+
+-- name: GetLatestBoxAbuseBan :one
+SELECT * FROM box_abuse_event
+WHERE fingerprint_id = $1 AND action = 'ban'
+ORDER BY created_at DESC
+LIMIT 1;
+
+-- name: ListBoxAbuseEventsSince :many
+-- Used to tell terminals on every replica what the watchdog did
+SELECT * FROM box_abuse_event
+WHERE fingerprint_id = ANY(@fingerprint_ids::text[]) AND created_at > @created_at
+ORDER BY created_at;
@@ -0,0 +1,28 @@
+-- name: UpsertBoxConnectionLeases :exec
+-- Takes or renews the replica's leases on the given boxes
+INSERT INTO box_connection_lease (
+    fingerprint_id,
+    replica_id,
+    expires_at
+)
+SELECT unnest(@fingerprint_ids::text[]), @replica_id::text, @expires_at::timestamp
+ON CONFLICT (fingerprint_id, replica_id) DO UPDATE SET expires_at = EXCLUDED.expires_at;
+
+-- name: CountOtherBoxConnectionLeases :one
+-- Used before pausing or removing a box another replica may be serving
+SELECT COUNT(*) FROM box_connection_lease
+WHERE fingerprint_id = $1 AND replica_id <> $2 AND expires_at > $3;
+
+-- name: DeleteBoxConnectionLease :exec
+DELETE FROM box_connection_lease
+WHERE fingerprint_id = $1 AND replica_id = $2;
+
+-- name: DeleteBoxConnectionLeasesByReplica :exec
+-- Releases a replica's leases when it shuts down
+DELETE FROM box_connection_lease
+WHERE replica_id = $1;
+
+-- name: DeleteExpiredBoxConnectionLeases :execrows
+-- Drops the leases of replicas that stopped renewing them
+DELETE FROM box_connection_lease
+WHERE expires_at < $1;
@@ -0,0 +1,20 @@
+-- name: CreateBoxSidecar :one
+INSERT INTO box_sidecar (
+    box_id,
+    name,
+    template,
+    container_id
+) VALUES (
+    $1, $2, $3, $4
+)
+RETURNING *;
+
+-- name: ListBoxSidecarsByFingerprint :many
+SELECT box_sidecar.* FROM box_sidecar
+JOIN box ON box.id = box_sidecar.box_id
+WHERE box.fingerprint_id = $1
+ORDER BY box_sidecar.name;
+
+-- name: DeleteBoxSidecar :exec
+DELETE FROM box_sidecar
+WHERE id = $1;
@@ -0,0 +1,32 @@
+-- name: CreateBoxSnapshot :one
+INSERT INTO box_snapshot (
+    id,
+    fingerprint_id,
+    box_id,
+    name,
+    image,
+    size_bytes
+) VALUES (
+    $1, $2, $3, $4, $5, $6
+)
+RETURNING *;
+
+-- name: GetBoxSnapshot :one
+SELECT * FROM box_snapshot
+WHERE id = $1 AND fingerprint_id = $2 LIMIT 1;
+
+-- name: ListBoxSnapshotsByFingerprint :many
+SELECT * FROM box_snapshot
+WHERE fingerprint_id = $1
+ORDER BY created_at DESC;
+
+-- name: GetBoxSnapshotUsage :one
+-- Used for the per-owner snapshot quota
+SELECT COUNT(*) AS count,
+       COALESCE(SUM(size_bytes), 0)::BIGINT AS size_bytes
+FROM box_snapshot
+WHERE fingerprint_id = $1;
+
+-- name: DeleteBoxSnapshot :exec
+DELETE FROM box_snapshot
+WHERE id = $1;
@@ -0,0 +1,23 @@
+-- name: UpsertDotfiles :one
+INSERT INTO dotfiles (
+    fingerprint_id,
+    archive,
+    files,
+    size_bytes
+) VALUES (
+    $1, $2, $3, $4
+)
+ON CONFLICT (fingerprint_id) DO UPDATE
+SET archive = EXCLUDED.archive,
+    files = EXCLUDED.files,
+    size_bytes = EXCLUDED.size_bytes,
+    updated_at = CURRENT_TIMESTAMP
+RETURNING *;
+
+-- name: GetDotfiles :one
+SELECT * FROM dotfiles
+WHERE fingerprint_id = $1 LIMIT 1;
+
+-- name: DeleteDotfiles :exec
+DELETE FROM dotfiles
+WHERE fingerprint_id = $1;
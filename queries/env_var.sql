@@ -0,0 +1,25 @@
+-- name: UpsertEnvVar :one
+INSERT INTO env_var (
+    fingerprint_id,
+    name,
+    value,
+    ciphertext,
+    injection
+) VALUES (
+    $1, $2, $3, $4, $5
+)
+ON CONFLICT (fingerprint_id, name) DO UPDATE
+SET value = EXCLUDED.value,
+    ciphertext = EXCLUDED.ciphertext,
+    injection = EXCLUDED.injection,
+    updated_at = CURRENT_TIMESTAMP
+RETURNING *;
+
+-- name: ListEnvVars :many
+SELECT * FROM env_var
+WHERE fingerprint_id = $1
+ORDER BY name;
+
+-- name: DeleteEnvVar :execrows
+DELETE FROM env_var
+WHERE fingerprint_id = $1 AND name = $2;
@@ -0,0 +1,56 @@
+-- name: CreateLab :one
+INSERT INTO lab (
+    fingerprint_id,
+    name,
+    spec
+) VALUES (
+    $1, $2, $3
+)
+RETURNING *;
+
+-- name: GetLab :one
+SELECT * FROM lab
+WHERE id = $1 AND fingerprint_id = $2 LIMIT 1;
+
+-- name: ListLabsByFingerprint :many
+SELECT * FROM lab
+WHERE fingerprint_id = $1
+ORDER BY created_at DESC;
+
+-- name: ListLabsCreatedBefore :many
+-- Used by the lab expiry sweep
+SELECT * FROM lab
+WHERE created_at < $1;
+
+-- name: DeleteLab :exec
+DELETE FROM lab
+WHERE id = $1;
+
+-- name: CreateLabNetwork :exec
+INSERT INTO lab_network (
+    lab_id,
+    name,
+    subnet
+) VALUES (
+    $1, $2, $3
+);
+
+-- name: ListLabNetworks :many
+SELECT * FROM lab_network
+WHERE lab_id = $1
+ORDER BY name;
+
+-- name: CreateLabNode :exec
+INSERT INTO lab_node (
+    lab_id,
+    name,
+    container_id,
+    addresses
+) VALUES (
+    $1, $2, $3, $4
+);
+
+-- name: ListLabNodes :many
+SELECT * FROM lab_node
+WHERE lab_id = $1
+ORDER BY name;
@@ -0,0 +1,20 @@
+-- name: SpendPowChallenge :execrows
+-- Inserts nothing when the challenge was already spent
+INSERT INTO pow_challenge (
+    nonce,
+    client_ip,
+    expires_at
+) VALUES (
+    $1, $2, $3
+)
+ON CONFLICT (nonce) DO NOTHING;
+
+-- name: CountPowChallengesByIP :one
+-- Used to raise the difficulty for IPs creating many boxes
+SELECT COUNT(*) FROM pow_challenge
+WHERE client_ip = $1 AND created_at >= $2;
+
+-- name: DeleteExpiredPowChallenges :execrows
+-- Drops challenges that expired and no longer count against their IP
+DELETE FROM pow_challenge
+WHERE expires_at < $1 AND created_at < $2;
@@ -0,0 +1,15 @@
+-- name: CreateSubnetAllocation :exec
+INSERT INTO subnet_allocation (
+    network,
+    subnet
+) VALUES (
+    $1, $2
+);
+
+-- name: ListSubnetAllocations :many
+SELECT * FROM subnet_allocation
+ORDER BY subnet;
+
+-- name: DeleteSubnetAllocation :exec
+DELETE FROM subnet_allocation
+WHERE network = $1;